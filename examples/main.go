@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	micsummarybot "github.com/kotet/mic-summary-bot/mic_summary_bot"
@@ -24,12 +29,30 @@ func main() {
 
 	ctx := context.Background()
 
+	if len(os.Args) > 1 && os.Args[1] == "setup" {
+		// setup [instance URL]
+		if err := setupMastodon(ctx, "config.yaml", os.Args[2:]); err != nil {
+			slog.Error("Failed to set up Mastodon credentials", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	config, err := micsummarybot.LoadConfig("config.yaml")
 	if err != nil {
 		slog.Error("Failed to load config", "error", err)
 		os.Exit(1)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate-status" {
+		// Migrations are applied when the bot is created, so the status is printed before that
+		if err := printMigrationStatus(ctx, &config.Database); err != nil {
+			slog.Error("Failed to get migration status", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	bot, err := micsummarybot.NewMICSummaryBot(config)
 	if err != nil {
 		slog.Error("Failed to create MICSummaryBot", "error", err)
@@ -51,6 +74,40 @@ func main() {
 			if err := bot.PostSummary(ctx); err != nil {
 				slog.Error("Failed to pick and post item", "error", err)
 			}
+		case "digest":
+			if err := bot.PostDigest(ctx); err != nil {
+				slog.Error("Failed to post digest", "error", err)
+			}
+		case "respond":
+			if err := bot.RespondToMentions(ctx); err != nil {
+				slog.Error("Failed to respond to mentions", "error", err)
+			}
+		case "sweep":
+			if err := bot.SweepRetryLimitExceeded(ctx); err != nil {
+				slog.Error("Failed to sweep items over retry limit", "error", err)
+			}
+		case "resummarize", "delete", "reply":
+			// resummarize <item ID>, delete <item ID>, reply <item ID> <text>
+			if len(os.Args) < 3 || (command == "reply" && len(os.Args) < 4) {
+				slog.Error("Missing arguments", "command", command)
+				os.Exit(1)
+			}
+			itemID, err := strconv.Atoi(os.Args[2])
+			if err != nil {
+				slog.Error("Invalid item ID", "item_id", os.Args[2], "error", err)
+				os.Exit(1)
+			}
+			switch command {
+			case "resummarize":
+				err = bot.ResummarizeItem(ctx, itemID)
+			case "delete":
+				err = bot.DeleteItemPosts(ctx, itemID)
+			case "reply":
+				err = bot.ReplyToItem(ctx, itemID, os.Args[3])
+			}
+			if err != nil {
+				slog.Error("Failed to run command", "command", command, "item_id", itemID, "error", err)
+			}
 		default:
 			slog.Error("Unknown command", "command", command)
 		}
@@ -68,3 +125,71 @@ func main() {
 		}
 	}
 }
+
+// setupMastodon registers the bot on the Mastodon instance, asks the user to authorize it,
+// and writes the credentials into the config file.
+func setupMastodon(ctx context.Context, configPath string, args []string) error {
+	stdin := bufio.NewScanner(os.Stdin)
+	prompt := func(message string) (string, error) {
+		fmt.Print(message)
+		if !stdin.Scan() {
+			if err := stdin.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("no input")
+		}
+		return strings.TrimSpace(stdin.Text()), nil
+	}
+
+	instanceURL := ""
+	if len(args) > 0 {
+		instanceURL = args[0]
+	} else if config, err := micsummarybot.LoadConfig(configPath); err == nil {
+		instanceURL = config.Mastodon.InstanceURL
+	}
+	if instanceURL == "" {
+		var err error
+		if instanceURL, err = prompt("Mastodon instance URL: "); err != nil {
+			return err
+		}
+	}
+
+	app, err := micsummarybot.RegisterMastodonApp(ctx, instanceURL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Open the following URL, authorize the application and paste the code shown.\n%s\n", app.AuthorizationURL)
+	code, err := prompt("Authorization code: ")
+	if err != nil {
+		return err
+	}
+
+	accessToken, account, err := micsummarybot.AuthorizeMastodonApp(ctx, app, code)
+	if err != nil {
+		return err
+	}
+	if err := micsummarybot.SaveMastodonCredentials(configPath, app, accessToken); err != nil {
+		return err
+	}
+	fmt.Printf("Authorized as @%s. Credentials are written to %s.\n", account, configPath)
+	return nil
+}
+
+// printMigrationStatus prints the applied and pending migrations. Pending migrations are applied on the next start.
+func printMigrationStatus(ctx context.Context, database *micsummarybot.DatabaseConfig) error {
+	statuses, err := micsummarybot.GetMigrationStatus(ctx, database)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if s.Applied {
+			fmt.Printf("%04d_%s\tapplied at %s\n", s.Version, s.Name, s.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%04d_%s\tpending\n", s.Version, s.Name)
+			pending++
+		}
+	}
+	fmt.Printf("%d pending migration(s)\n", pending)
+	return nil
+}
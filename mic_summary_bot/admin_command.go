@@ -0,0 +1,213 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// adminCommandUsage は不明なコマンドを受け取った場合に返信する使い方
+const adminCommandUsage = `使えるコマンド:
+status: 一時停止中かとステータスごとのアイテム数、再試行をあきらめたアイテム数
+retry <URL>: アイテムをスクリーニングからやり直す
+skip <URL>: アイテムを投稿せずに処理済みにする
+resummarize <URL>: 要約し直して投稿を編集する
+post <URL>: 投稿の時間帯と頻度の制限によらず、すぐに要約して投稿する
+history <URL>: アイテムのステータスの遷移を表示する
+pause: スクリーニングと投稿を一時停止する
+resume: 一時停止を解除する`
+
+// itemStatusNames はステータスの表示名
+var itemStatusNames = map[ItemStatus]string{
+	StatusUnprocessed: "未処理",
+	StatusDeferred:    "先送り",
+	StatusPending:     "処理待ち",
+	StatusProcessed:   "処理済み",
+}
+
+// giveUpReasonNames は再試行をあきらめて処理済みにした理由の表示名
+var giveUpReasonNames = map[ItemReasonCode]string{
+	ReasonRetryLimitExceeded: "再試行の上限超過",
+	ReasonMaxAgeExceeded:     "再試行の期限切れ",
+}
+
+// isAdminMessage はメンションが管理者からのDMかを返します。送信者はacctではなくアカウントIDで確認します。
+func (b *MICSummaryBot) isAdminMessage(mention *IncomingMention) bool {
+	return b.config.Admin.Enabled && mention.Visibility == "direct" && mention.AccountID != "" &&
+		slices.Contains(b.config.Admin.AccountIDs, mention.AccountID)
+}
+
+// handleAdminMessage は管理者からのDMをコマンドとして実行し、結果をDMで返信します。
+func (b *MICSummaryBot) handleAdminMessage(ctx context.Context, mention *IncomingMention) (*MentionRecord, error) {
+	record := &MentionRecord{
+		NotificationID: mention.NotificationID,
+		StatusID:       mention.StatusID,
+		Account:        mention.Account,
+		Result:         MentionCommand,
+	}
+	if b.config.DryRun.Enabled {
+		pkgLogger.Info("Dry run: admin command is not executed", "account", mention.Account, "command", mention.Text)
+		record.Result = MentionIgnored
+		return record, nil
+	}
+	// 初回の実行や長く停止していた後に、古いコマンドを実行しないようにする
+	if time.Since(mention.CreatedAt) > mentionMaxAge {
+		pkgLogger.Info("Admin command is too old to execute", "account", mention.Account, "command", mention.Text, "created_at", mention.CreatedAt)
+		record.Result = MentionIgnored
+		return record, nil
+	}
+
+	pkgLogger.Info("Executing admin command", "account", mention.Account, "account_id", mention.AccountID, "command", mention.Text)
+	reply, itemID := b.executeAdminCommand(ctx, mention.Text)
+	record.ItemID = itemID
+	if err := b.mastodonClient.SendDirectMessage(ctx, mention, reply); err != nil {
+		// コマンドは実行済みのため、返信に失敗しても記録してやり直さない
+		pkgLogger.Error("Failed to reply to admin command", "account", mention.Account, "error", err)
+	}
+	return record, nil
+}
+
+// executeAdminCommand は管理者のコマンドを実行し、返信するテキストと対象のアイテムIDを返します。
+// 失敗した場合も、エラーの内容を返信するテキストとして返します。
+func (b *MICSummaryBot) executeAdminCommand(ctx context.Context, text string) (string, int) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return adminCommandUsage, 0
+	}
+	command := strings.ToLower(fields[0])
+
+	switch command {
+	case "status":
+		reply, err := b.statusReport(ctx)
+		if err != nil {
+			return "エラー: " + err.Error(), 0
+		}
+		return reply, 0
+	case "pause", "resume":
+		value, reply := "true", "一時停止しました。スクリーニングと投稿を行いません。"
+		if command == "resume" {
+			value, reply = "false", "再開しました。"
+		}
+		if err := b.itemRepository.SetSetting(ctx, SettingPaused, value); err != nil {
+			return "エラー: " + err.Error(), 0
+		}
+		return reply, 0
+	case "retry", "skip", "resummarize", "post", "history":
+		if len(fields) < 2 {
+			return fmt.Sprintf("%s にはアイテムのURLを指定してください。", command), 0
+		}
+		item, err := b.itemRepository.GetItemByURL(ctx, fields[1])
+		if err != nil {
+			return "エラー: " + err.Error(), 0
+		}
+		if item == nil {
+			return "アイテムが見つかりません: " + fields[1], 0
+		}
+		if command == "history" {
+			reply, err := b.itemHistory(ctx, item)
+			if err != nil {
+				return "エラー: " + err.Error(), item.ID
+			}
+			return reply, item.ID
+		}
+		if err := b.executeItemCommand(ctx, command, item); err != nil {
+			return fmt.Sprintf("エラー: %s\n%s", item.Title, err.Error()), item.ID
+		}
+		return fmt.Sprintf("%s を実行しました: %s", command, item.Title), item.ID
+	default:
+		return adminCommandUsage, 0
+	}
+}
+
+// executeItemCommand はアイテムを対象とする管理者のコマンドを実行します。
+func (b *MICSummaryBot) executeItemCommand(ctx context.Context, command string, item *Item) error {
+	switch command {
+	case "retry":
+		item.Status = StatusUnprocessed
+		item.Reason = ReasonNone
+		item.RetryCount = 0
+		return b.itemRepository.Update(ctx, item, ItemTransition{Stage: StageAdmin, Override: true})
+	case "skip":
+		item.Status = StatusProcessed
+		item.Reason = ReasonSkippedByAdmin
+		return b.itemRepository.Update(ctx, item, ItemTransition{Stage: StageAdmin, Override: true})
+	case "resummarize":
+		return b.ResummarizeItem(ctx, item.ID)
+	case "post":
+		return b.postItem(ctx, item, true)
+	}
+	return fmt.Errorf("unknown command: %s", command)
+}
+
+// adminHistoryLimit は history コマンドで返信するイベントの最大数。新しいものから返信する
+const adminHistoryLimit = 10
+
+// itemHistory はアイテムのステータスの遷移を、古い順に1行ずつ返します。
+// 失敗による遷移にはエラーメッセージを付けます。
+func (b *MICSummaryBot) itemHistory(ctx context.Context, item *Item) (string, error) {
+	events, err := b.itemRepository.GetItemEvents(ctx, item.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return "記録がありません: " + item.Title, nil
+	}
+	if len(events) > adminHistoryLimit {
+		events = events[len(events)-adminHistoryLimit:]
+	}
+	var buf strings.Builder
+	buf.WriteString(item.Title + "\n")
+	for _, e := range events {
+		fmt.Fprintf(&buf, "%s %s: %s → %s", e.CreatedAt.In(jstLocation).Format("01/02 15:04"), e.Stage, itemStatusNames[e.FromStatus], itemStatusNames[e.ToStatus])
+		if e.Error != "" {
+			fmt.Fprintf(&buf, " (%s)", e.Error)
+		}
+		buf.WriteString("\n")
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// statusReport は一時停止中かと、ステータスごとのアイテム数を返します。
+// 処理済みのアイテムのうち、再試行をあきらめたものは理由ごとの数も返します。
+func (b *MICSummaryBot) statusReport(ctx context.Context) (string, error) {
+	counts, err := b.itemRepository.CountItemsByStatus(ctx)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if b.isPaused(ctx) {
+		buf.WriteString("一時停止中\n")
+	} else {
+		buf.WriteString("稼働中\n")
+	}
+	for _, status := range []ItemStatus{StatusUnprocessed, StatusDeferred, StatusPending, StatusProcessed} {
+		fmt.Fprintf(&buf, "%s: %d\n", itemStatusNames[status], counts[status])
+	}
+	reasons, err := b.itemRepository.CountItemsByReason(ctx, StatusProcessed)
+	if err != nil {
+		return "", err
+	}
+	for _, reason := range []ItemReasonCode{ReasonRetryLimitExceeded, ReasonMaxAgeExceeded} {
+		fmt.Fprintf(&buf, "うち%s: %d\n", giveUpReasonNames[reason], reasons[reason])
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// notifyAdmins は admin.account_ids の管理者にテキストをDMで送ります。
+// Mastodonが無効な場合とドライランでは送りません。失敗はログに記録するだけにします。
+func (b *MICSummaryBot) notifyAdmins(ctx context.Context, text string) {
+	if !b.config.Mastodon.Enabled {
+		return
+	}
+	if b.config.DryRun.Enabled {
+		pkgLogger.Info("Dry run: admin notification is not sent", "text", text)
+		return
+	}
+	for _, accountID := range b.config.Admin.AccountIDs {
+		if err := b.mastodonClient.SendDirectMessageToAccount(ctx, accountID, text); err != nil {
+			pkgLogger.Error("Failed to notify admin", "account_id", accountID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package micsummarybot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMICSummaryBot_executeAdminCommand(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	bot := &MICSummaryBot{itemRepository: repo, config: DefaultConfig()}
+
+	now := time.Now().UTC()
+	item := &Item{URL: "https://www.soumu.go.jp/1.html", Title: "会議の開催", PublishedAt: now, Status: StatusDeferred, Reason: ReasonAPIFailed, RetryCount: 2, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, item))
+	require.NoError(t, repo.insert(ctx, &Item{URL: "https://www.soumu.go.jp/2.html", Title: "報道発表", PublishedAt: now, Status: StatusPending, CreatedAt: now, LastCheckedAt: now}))
+	stored, err := repo.GetItemByURL(ctx, item.URL)
+	require.NoError(t, err)
+
+	t.Run("status", func(t *testing.T) {
+		reply, itemID := bot.executeAdminCommand(ctx, "status")
+		assert.Equal(t, "稼働中\n未処理: 0\n先送り: 1\n処理待ち: 1\n処理済み: 0\nうち再試行の上限超過: 0\nうち再試行の期限切れ: 0", reply)
+		assert.Zero(t, itemID)
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		bot.executeAdminCommand(ctx, "pause")
+		assert.True(t, bot.isPaused(ctx))
+		require.NoError(t, bot.PostSummary(ctx), "the pending item is not summarized while paused")
+		pending, err := repo.GetItemByURL(ctx, "https://www.soumu.go.jp/2.html")
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, pending.Status)
+
+		reply, _ := bot.executeAdminCommand(ctx, "status")
+		assert.Contains(t, reply, "一時停止中")
+		bot.executeAdminCommand(ctx, "RESUME")
+		assert.False(t, bot.isPaused(ctx))
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		_, itemID := bot.executeAdminCommand(ctx, "retry "+item.URL)
+		assert.Equal(t, stored.ID, itemID)
+		retried, err := repo.GetItemByID(ctx, stored.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnprocessed, retried.Status)
+		assert.Equal(t, ReasonNone, retried.Reason)
+		assert.Zero(t, retried.RetryCount)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		bot.executeAdminCommand(ctx, "skip "+item.URL)
+		skipped, err := repo.GetItemByID(ctx, stored.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusProcessed, skipped.Status)
+		assert.Equal(t, ReasonSkippedByAdmin, skipped.Reason)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		reply, itemID := bot.executeAdminCommand(ctx, "history "+item.URL)
+		assert.Equal(t, stored.ID, itemID)
+		lines := strings.Split(reply, "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, "会議の開催", lines[0])
+		assert.Contains(t, lines[1], "feed: 先送り → 先送り")
+		assert.Contains(t, lines[2], "admin: 先送り → 未処理")
+		assert.Contains(t, lines[3], "admin: 未処理 → 処理済み")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		reply, _ := bot.executeAdminCommand(ctx, "retry")
+		assert.Contains(t, reply, "URLを指定してください")
+		reply, _ = bot.executeAdminCommand(ctx, "skip https://example.com/unknown.html")
+		assert.Contains(t, reply, "アイテムが見つかりません")
+		reply, _ = bot.executeAdminCommand(ctx, "help")
+		assert.Equal(t, adminCommandUsage, reply)
+	})
+}
+
+func TestMICSummaryBot_isAdminMessage(t *testing.T) {
+	config := DefaultConfig()
+	config.Admin.Enabled = true
+	config.Admin.AccountIDs = []string{"42"}
+	bot := &MICSummaryBot{config: config}
+
+	assert.True(t, bot.isAdminMessage(&IncomingMention{AccountID: "42", Account: "admin", Visibility: "direct"}))
+	assert.False(t, bot.isAdminMessage(&IncomingMention{AccountID: "42", Account: "admin", Visibility: "public"}), "commands are accepted only by DM")
+	assert.False(t, bot.isAdminMessage(&IncomingMention{AccountID: "43", Account: "admin", Visibility: "direct"}), "the sender is checked by account ID, not acct")
+
+	config.Admin.Enabled = false
+	assert.False(t, bot.isAdminMessage(&IncomingMention{AccountID: "42", Visibility: "direct"}))
+}
+
+func TestMICSummaryBot_handleAdminMessage_old(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.Admin.Enabled = true
+	config.Admin.AccountIDs = []string{"42"}
+	bot := &MICSummaryBot{itemRepository: repo, config: config}
+
+	// 初回の実行や停止していた後に取得した古いコマンドは実行しない
+	mention := &IncomingMention{NotificationID: "10", AccountID: "42", Account: "admin", Visibility: "direct", Text: "pause", CreatedAt: time.Now().Add(-2 * mentionMaxAge)}
+	record, err := bot.handleAdminMessage(ctx, mention)
+	require.NoError(t, err)
+	assert.Equal(t, MentionIgnored, record.Result)
+	assert.False(t, bot.isPaused(ctx))
+}
@@ -0,0 +1,68 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// QuestionAnswer は投稿した要約への質問に対する回答を保持します。
+type QuestionAnswer struct {
+	Answer string `json:"answer" genai:"required"`
+}
+
+// answerPromptPrefix は回答の生成時に、要約結果の前に付与される説明です。
+const answerPromptPrefix = "以下は総務省の会議資料を要約した結果(JSON)です。documentsは各資料の要約、final_summaryは会議全体の最終要約です。要約した資料はこの後に添付します。\n\n"
+
+// AnswerQuestion は要約結果と要約した資料をもとに、質問への回答を生成します。
+// 資料はキャッシュに残っているものを使い、残っていない場合はダウンロードし直します。取得できなかった資料は添付せずに回答します。
+func (client *GenAIClient) AnswerQuestion(ctx context.Context, summary SummarizeResult, question string, prompt string) (string, error) {
+	modelConfig := &genai.GenerateContentConfig{
+		Temperature:      new(float32), // 0
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   mustSchemaFor(QuestionAnswer{}),
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	parts := []*genai.Part{genai.NewPartFromText(answerPromptPrefix + string(summaryJSON))}
+
+	for _, doc := range summary.Documents {
+		if doc.URL == "" || !strings.HasSuffix(strings.ToLower(doc.URL), ".pdf") {
+			continue
+		}
+		file, err := client.Downloader.GetArchived(ctx, doc.URL)
+		if err != nil {
+			pkgLogger.Warn("Answering without document", "url", doc.URL, "error", err)
+			continue
+		}
+		f, err := client.Client.Files.UploadFromPath(ctx, file.Path, &genai.UploadFileConfig{})
+		if releaseErr := client.Downloader.Release(ctx, file); releaseErr != nil {
+			pkgLogger.Warn("Failed to release downloaded file", "local_path", file.Path, "error", releaseErr)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to upload file: %w", err)
+		}
+		parts = append(parts, genai.NewPartFromURI(f.URI, f.MIMEType))
+	}
+
+	parts = append(parts, genai.NewPartFromText(prompt), genai.NewPartFromText("質問:\n"+question))
+	contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
+
+	resp, model, err := client.generateContent(ctx, client.SummarizingModel, contents, modelConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate answer: %w", err)
+	}
+
+	var result QuestionAnswer
+	if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+		return "", fmt.Errorf("failed to parse JSON response for answer: %w", err)
+	}
+	pkgLogger.Info("Answer generated", "model", model)
+	return strings.TrimSpace(result.Answer), nil
+}
@@ -0,0 +1,221 @@
+package micsummarybot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivo/uniseg"
+)
+
+// blueskyDefaultMaxCharacters is the maximum length of a post on Bluesky.
+// Bluesky counts graphemes, so the summary is measured with uniseg.GraphemeClusterCount.
+const blueskyDefaultMaxCharacters = 300
+
+// blueskyURLPattern matches the URLs turned into link facets.
+var blueskyURLPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// BlueskyClient is a publisher for Bluesky using the AT Protocol XRPC API.
+type BlueskyClient struct {
+	name          string
+	serviceURL    string
+	identifier    string
+	password      string
+	maxCharacters int
+	templates     *postTemplates
+	repository    PostStore
+	httpClient    *http.Client
+
+	mu      sync.Mutex
+	session *blueskySession
+}
+
+type blueskySession struct {
+	AccessJwt string `json:"accessJwt"`
+	DID       string `json:"did"`
+}
+
+type blueskyPostRecord struct {
+	Type      string         `json:"$type"`
+	Text      string         `json:"text"`
+	CreatedAt string         `json:"createdAt"`
+	Langs     []string       `json:"langs,omitempty"`
+	Facets    []blueskyFacet `json:"facets,omitempty"`
+}
+
+type blueskyFacet struct {
+	Index struct {
+		ByteStart int `json:"byteStart"`
+		ByteEnd   int `json:"byteEnd"`
+	} `json:"index"`
+	Features []blueskyFacetFeature `json:"features"`
+}
+
+type blueskyFacetFeature struct {
+	Type string `json:"$type"`
+	URI  string `json:"uri"`
+}
+
+type blueskyCreateRecordRequest struct {
+	Repo       string            `json:"repo"`
+	Collection string            `json:"collection"`
+	Record     blueskyPostRecord `json:"record"`
+}
+
+type blueskyCreateRecordResponse struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+func newBlueskyClient(config PublisherConfig, templates *postTemplates, repository PostStore, httpClient *http.Client) *BlueskyClient {
+	serviceURL := strings.TrimSuffix(config.URL, "/")
+	if serviceURL == "" {
+		serviceURL = "https://bsky.social"
+	}
+	maxCharacters := config.MaxCharacters
+	if maxCharacters == 0 {
+		maxCharacters = blueskyDefaultMaxCharacters
+	}
+	return &BlueskyClient{
+		name:          config.Name,
+		serviceURL:    serviceURL,
+		identifier:    config.Identifier,
+		password:      config.Password,
+		maxCharacters: maxCharacters,
+		templates:     templates,
+		repository:    repository,
+		httpClient:    httpClient,
+	}
+}
+
+func (c *BlueskyClient) Name() string {
+	return c.name
+}
+
+// PostSummary posts the summary. The summary is truncated to fit in the character limit.
+func (c *BlueskyClient) PostSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	text, err := c.templates.renderSummary(item, summary, c.maxCharacters, uniseg.GraphemeClusterCount)
+	if err != nil {
+		return err
+	}
+	return c.createPost(ctx, item, PostKindSummary, text)
+}
+
+// PostNoValue posts a predefined message for items deemed not valuable.
+func (c *BlueskyClient) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	text, err := c.templates.renderNoValue(item, screening)
+	if err != nil {
+		return err
+	}
+	return c.createPost(ctx, item, PostKindNoValue, text)
+}
+
+// PostFallback posts the text for an item given up without a summary.
+func (c *BlueskyClient) PostFallback(ctx context.Context, item Item, text string) error {
+	return c.createPost(ctx, item, PostKindFallback, text)
+}
+
+func (c *BlueskyClient) renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error) {
+	text, err := c.templates.renderSummary(item, summary, c.maxCharacters, uniseg.GraphemeClusterCount)
+	return []string{text}, err
+}
+
+func (c *BlueskyClient) renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error) {
+	text, err := c.templates.renderNoValue(item, screening)
+	return []string{text}, err
+}
+
+func (c *BlueskyClient) createPost(ctx context.Context, item Item, kind PostKind, text string) error {
+	text = strings.TrimSpace(text)
+	record := blueskyPostRecord{
+		Type:      "app.bsky.feed.post",
+		Text:      text,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Langs:     []string{"ja"},
+		Facets:    blueskyLinkFacets(text),
+	}
+
+	var resp blueskyCreateRecordResponse
+	err := c.withSession(ctx, func(session *blueskySession) error {
+		header := http.Header{"Authorization": {"Bearer " + session.AccessJwt}}
+		return postJSON(ctx, c.httpClient, c.serviceURL+"/xrpc/com.atproto.repo.createRecord", header, blueskyCreateRecordRequest{
+			Repo:       session.DID,
+			Collection: "app.bsky.feed.post",
+			Record:     record,
+		}, &resp)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create post on Bluesky: %w", err)
+	}
+
+	url := blueskyPostURL(resp.URI)
+	recordPublishedPost(ctx, c.repository, item, c.name, kind, 0, resp.URI, url)
+	pkgLogger.Info("Successfully posted to Bluesky", "publisher", c.name, "url", url)
+	return nil
+}
+
+// withSession calls f with the current session, creating one if needed.
+// If the session has expired, a new session is created and f is called again.
+func (c *BlueskyClient) withSession(ctx context.Context, f func(*blueskySession) error) error {
+	session, err := c.getSession(ctx, false)
+	if err != nil {
+		return err
+	}
+	err = f(session)
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusBadRequest || statusErr.StatusCode == http.StatusUnauthorized) && strings.Contains(statusErr.Body, "ExpiredToken") {
+		session, err = c.getSession(ctx, true)
+		if err != nil {
+			return err
+		}
+		return f(session)
+	}
+	return err
+}
+
+func (c *BlueskyClient) getSession(ctx context.Context, refresh bool) (*blueskySession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil && !refresh {
+		return c.session, nil
+	}
+
+	var session blueskySession
+	err := postJSON(ctx, c.httpClient, c.serviceURL+"/xrpc/com.atproto.server.createSession", nil, map[string]string{
+		"identifier": c.identifier,
+		"password":   c.password,
+	}, &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Bluesky session: %w", err)
+	}
+	c.session = &session
+	return c.session, nil
+}
+
+// blueskyLinkFacets returns the link facets for the URLs in the text.
+// Bluesky does not turn URLs into links automatically, and the facet indexes are UTF-8 byte offsets.
+func blueskyLinkFacets(text string) []blueskyFacet {
+	var facets []blueskyFacet
+	for _, loc := range blueskyURLPattern.FindAllStringIndex(text, -1) {
+		var facet blueskyFacet
+		facet.Index.ByteStart = loc[0]
+		facet.Index.ByteEnd = loc[1]
+		facet.Features = []blueskyFacetFeature{{Type: "app.bsky.richtext.facet#link", URI: text[loc[0]:loc[1]]}}
+		facets = append(facets, facet)
+	}
+	return facets
+}
+
+// blueskyPostURL converts an AT URI such as at://did:plc:xxx/app.bsky.feed.post/rkey to the web URL.
+func blueskyPostURL(uri string) string {
+	parts := strings.Split(strings.TrimPrefix(uri, "at://"), "/")
+	if len(parts) != 3 {
+		return uri
+	}
+	return "https://bsky.app/profile/" + parts[0] + "/post/" + parts[2]
+}
@@ -2,8 +2,10 @@ package micsummarybot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime/debug"
+	"time"
 )
 
 // handlePanic is a helper function for consistent panic handling
@@ -23,45 +25,172 @@ type MICSummaryBot struct {
 	rssClient      *RSSClient
 	genAIClient    *GenAIClient
 	mastodonClient *MastodonClient
-	itemRepository *ItemRepository
+	publishers     []Publisher
+	itemRepository Storage
+	schedule       *postingSchedule
+	retry          *retryPolicy
 	config         *Config
 }
 
 func NewMICSummaryBot(config *Config) (*MICSummaryBot, error) {
-	itemRepository, err := NewItemRepository(config.Database.Path, config.Database.MaxDeferredRetryCount)
+	itemRepository, err := NewStorage(&config.Database)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create item repository: %w", err)
 	}
 
-	genAIClient, err := NewGenAIClient(&config.Gemini, &config.Storage)
+	downloadManager, err := NewDownloadManager(&config.Storage, itemRepository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download manager: %w", err)
+	}
+
+	genAIClient, err := NewGenAIClient(&config.Gemini, downloadManager)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GenAI client: %w", err)
 	}
 
-	mastodonClient, err := NewMastodonClient(config)
+	schedule, err := newPostingSchedule(&config.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create posting schedule: %w", err)
+	}
+
+	retry, err := newRetryPolicy(&config.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retry policy: %w", err)
+	}
+
+	mastodonClient, err := NewMastodonClient(config, itemRepository)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Mastodon client: %w", err)
 	}
 
+	publishers, err := NewPublishers(config, itemRepository, mastodonClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create publishers: %w", err)
+	}
+
+	// 前回の実行が投稿の途中で終了した場合に、投稿できたか分からないステータスを確認する
+	if config.Mastodon.Enabled && !config.DryRun.Enabled {
+		if err := mastodonClient.ReconcileOutbox(context.Background()); err != nil {
+			pkgLogger.Error("Failed to reconcile outbox", "error", err)
+		}
+	}
+
 	return &MICSummaryBot{
 		rssClient:      NewRSSClient(),
 		genAIClient:    genAIClient,
 		mastodonClient: mastodonClient,
+		publishers:     publishers,
 		itemRepository: itemRepository,
+		schedule:       schedule,
+		retry:          retry,
 		config:         config,
 	}, nil
 }
 
 // setItemToDeferred はアイテムをDeferredステータスに更新するヘルパー関数
-// エラー処理の共通化により、コードの重複を避け、保守性を向上させる
-func (b *MICSummaryBot) setItemToDeferred(ctx context.Context, item *Item, reason ItemReasonCode, originalErr error, logMsg string) {
+// エラー処理の共通化により、コードの重複を避け、保守性を向上させる。エラーは処理の段階とともにアイテムのイベントに記録する
+func (b *MICSummaryBot) setItemToDeferred(ctx context.Context, item *Item, stage ItemStage, reason ItemReasonCode, originalErr error, logMsg string) {
 	pkgLogger.Error(logMsg, "url", item.URL, "error", originalErr)
-	item.Status = StatusDeferred
+	if updateErr := b.deferItem(ctx, item, stage, reason, originalErr); updateErr != nil {
+		pkgLogger.Error("Failed to update item status after processing error", "url", item.URL, "original_error_context", logMsg, "update_error", updateErr)
+	}
+}
+
+// deferItem はアイテムを先送りし、先送りの理由と回数に応じて次に処理する時刻を設定します。
+// 次に処理する時刻が retry.max_age を過ぎる場合は、再試行をあきらめて処理済みにします。
+func (b *MICSummaryBot) deferItem(ctx context.Context, item *Item, stage ItemStage, reason ItemReasonCode, cause error) error {
 	item.Reason = reason
 	item.RetryCount++
-	if updateErr := b.itemRepository.Update(ctx, item); updateErr != nil {
-		pkgLogger.Error("Failed to update item status after processing error", "url", item.URL, "original_error_context", logMsg, "update_error", updateErr)
+	transition := ItemTransition{Stage: stage, Err: cause}
+	if next, ok := b.retry.nextAttempt(item, time.Now()); ok {
+		item.Status = StatusDeferred
+		transition.NextAttemptAt = next
+		pkgLogger.Info("Deferred item", "url", item.URL, "reason", reason, "retry_count", item.RetryCount, "next_attempt_at", next)
+		return b.itemRepository.Update(ctx, item, transition)
 	}
+	pkgLogger.Warn("Giving up item after retry max age", "url", item.URL, "created_at", item.CreatedAt, "retry_count", item.RetryCount)
+	return b.giveUpItem(ctx, item, ReasonMaxAgeExceeded, transition)
+}
+
+// giveUpItem は再試行をあきらめたアイテムを reason とともに処理済みにします。
+// 設定に応じて要約の代わりのテキストを投稿し、管理者にDMで知らせます。
+// 投稿と通知はステータスを更新した後に行い、失敗してもアイテムを再び処理しないようログに記録するだけにします。
+func (b *MICSummaryBot) giveUpItem(ctx context.Context, item *Item, reason ItemReasonCode, transition ItemTransition) error {
+	item.Status = StatusProcessed
+	item.Reason = reason
+	if err := b.itemRepository.Update(ctx, item, transition); err != nil {
+		return err
+	}
+
+	if b.retry.fallbackTemplate != nil {
+		if err := b.postFallback(ctx, item); err != nil {
+			pkgLogger.Error("Failed to post fallback message", "url", item.URL, "error", err)
+		}
+	}
+	if b.config.Retry.NotifyAdmin {
+		b.notifyAdmins(ctx, fmt.Sprintf("再試行をあきらめました(%s): %s\n%s", giveUpReasonNames[reason], item.Title, item.URL))
+	}
+	return nil
+}
+
+// postFallback は再試行をあきらめたアイテムについて、要約の代わりのテキストを投稿します。
+// 対応していない投稿先はスキップします。
+func (b *MICSummaryBot) postFallback(ctx context.Context, item *Item) error {
+	text, err := b.retry.renderFallback(item, b.config.RSS.Name)
+	if err != nil {
+		return err
+	}
+	var publishers []Publisher
+	for _, p := range b.publishers {
+		if _, ok := p.(FallbackPublisher); ok {
+			publishers = append(publishers, p)
+		} else {
+			pkgLogger.Warn("Publisher does not support fallback posts", "publisher", p.Name())
+		}
+	}
+	return b.deliver(ctx, item, PostKindFallback, publishers, func(p Publisher) error {
+		return p.(FallbackPublisher).PostFallback(ctx, *item, text)
+	})
+}
+
+// SweepRetryLimitExceeded は先送りした回数が database.max_deferred_retry_count に達したアイテムを、
+// 再試行の上限超過として処理済みにします。これらのアイテムは再試行されず、先送りのまま残るためです。
+func (b *MICSummaryBot) SweepRetryLimitExceeded(ctx context.Context) (err error) {
+	defer func() {
+		if panicErr := handlePanic("SweepRetryLimitExceeded"); panicErr != nil {
+			err = panicErr
+		}
+	}()
+
+	if b.isPaused(ctx) {
+		pkgLogger.Info("Bot is paused, skipping sweeping items over retry limit")
+		return nil
+	}
+	pkgLogger.Info("Start sweeping items over retry limit")
+
+	// ドライランでステータスを元に戻す場合は同じアイテムを再び取得するため、一度処理したアイテムで終了する
+	swept := make(map[int]bool)
+	for {
+		item, err := b.itemRepository.GetItemOverRetryLimit(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get item over retry limit: %w", err)
+		}
+		if item == nil || swept[item.ID] {
+			break
+		}
+		swept[item.ID] = true
+
+		pkgLogger.Warn("Giving up item over retry limit", "url", item.URL, "retry_count", item.RetryCount)
+		err = func() error {
+			defer b.restoreAfterDryRun(ctx, b.snapshotForDryRun(ctx, item))
+			return b.giveUpItem(ctx, item, ReasonRetryLimitExceeded, ItemTransition{Stage: StageRetryLimit})
+		}()
+		if err != nil {
+			return fmt.Errorf("failed to give up item: %w", err)
+		}
+	}
+	pkgLogger.Info("Finish sweeping items over retry limit", "count", len(swept))
+	return nil
 }
 
 func (b *MICSummaryBot) RefreshFeedItems(ctx context.Context) error {
@@ -94,7 +223,12 @@ func (b *MICSummaryBot) PostSummary(ctx context.Context) (err error) {
 		}
 	}()
 
+	if b.isPaused(ctx) {
+		pkgLogger.Info("Bot is paused, skipping posting summary")
+		return nil
+	}
 	pkgLogger.Info("Start posting summary")
+	b.resolveScheduledPosts(ctx)
 
 	item, err := b.itemRepository.GetItemForSummarization(ctx)
 	if err != nil {
@@ -106,42 +240,459 @@ func (b *MICSummaryBot) PostSummary(ctx context.Context) (err error) {
 		return nil
 	}
 
+	if err := b.postItem(ctx, item, false); err != nil {
+		return err
+	}
+	pkgLogger.Info("Finish posting summary")
+	return nil
+}
+
+// postItem はアイテムを要約して投稿し、処理済みにします。
+// force が true の場合は投稿の時間帯と頻度の制限を適用せず、すぐに投稿します。
+func (b *MICSummaryBot) postItem(ctx context.Context, item *Item, force bool) error {
+	now := time.Now()
+	postAt, scheduled := now, false
+	if !force {
+		var err error
+		postAt, scheduled, err = b.postingTime(ctx, item)
+		if err != nil {
+			return err
+		}
+		if postAt.After(now) && (scheduled || b.schedule.deferral == ScheduleDeferralWait) {
+			pkgLogger.Info("Postponing summary by posting schedule", "url", item.URL, "until", postAt)
+			b.releaseItem(ctx, item, postAt)
+			return nil
+		}
+	}
+
+	defer b.restoreAfterDryRun(ctx, b.snapshotForDryRun(ctx, item))
+
 	pkgLogger.Info("Processing pending item for summarization", "url", item.URL)
 
+	summary, err := b.summarize(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	if postAt.After(now) {
+		done, err := b.scheduleSummary(ctx, item, summary, postAt)
+		if err != nil {
+			b.setItemToDeferred(ctx, item, StagePosting, ReasonAPIFailed, err, "Failed to schedule summary")
+			return fmt.Errorf("failed to schedule summary: %w", err)
+		}
+		if !done {
+			pkgLogger.Info("Postponing summary for publishers that can't schedule posts", "url", item.URL, "until", postAt)
+			b.releaseItem(ctx, item, postAt)
+			return nil
+		}
+	} else {
+		pkgLogger.Debug("Starting delivery to publishers", "url", item.URL, "publishers", len(b.publishers))
+		err = b.deliver(ctx, item, PostKindSummary, b.publishers, func(p Publisher) error {
+			return p.PostSummary(ctx, *item, summary)
+		})
+		if err != nil {
+			b.setItemToDeferred(ctx, item, StagePosting, ReasonAPIFailed, err, "Failed to deliver summary")
+			return fmt.Errorf("failed to deliver summary: %w", err)
+		}
+		pkgLogger.Debug("Delivery completed successfully", "url", item.URL)
+	}
+
+	pkgLogger.Debug("Updating item status", "url", item.URL)
+	item.Status = StatusProcessed
+	item.Reason = ReasonNone
+	if err := b.itemRepository.Update(ctx, item, ItemTransition{Stage: StagePosting}); err != nil {
+		pkgLogger.Error("Failed to update item status", "url", item.URL, "error", err)
+		return fmt.Errorf("failed to mark as posted: %w", err)
+	}
+	pkgLogger.Debug("Item status updated successfully", "url", item.URL)
+	b.addToDigest(ctx, item, PostKindSummary)
+	return nil
+}
+
+// resolveScheduledPosts は公開された予約投稿を、他の投稿と同じように編集や削除ができるよう記録します。
+// 失敗しても次回に再び確認するため、エラーはログに記録するだけにします。
+func (b *MICSummaryBot) resolveScheduledPosts(ctx context.Context) {
+	if b.config.DryRun.Enabled {
+		return
+	}
+	for _, p := range b.publishers {
+		scheduler, ok := p.(SummaryScheduler)
+		if !ok {
+			continue
+		}
+		if err := scheduler.ResolveScheduledPosts(ctx); err != nil {
+			pkgLogger.Error("Failed to resolve scheduled posts", "publisher", p.Name(), "error", err)
+		}
+	}
+}
+
+// releaseItem はステータスを更新せずに後回しにするアイテムを、投稿できる時刻 postAt 以降に再び取得できるようにします。
+func (b *MICSummaryBot) releaseItem(ctx context.Context, item *Item, postAt time.Time) {
+	if err := b.itemRepository.ReleaseItem(ctx, item.ID, postAt); err != nil {
+		pkgLogger.Error("Failed to release item", "url", item.URL, "error", err)
+	}
+}
+
+// isPaused は管理者のコマンドで一時停止されているかを返します。
+// 設定を読み込めない場合は、意図せず投稿しないよう一時停止中として扱います。
+func (b *MICSummaryBot) isPaused(ctx context.Context) bool {
+	value, err := b.itemRepository.GetSetting(ctx, SettingPaused)
+	if err != nil {
+		pkgLogger.Error("Failed to get paused setting", "error", err)
+		return true
+	}
+	return value == "true"
+}
+
+// dryRunSnapshot はドライランの前のアイテムと配信状況を保持する
+type dryRunSnapshot struct {
+	item       Item
+	deliveries []*Delivery
+}
+
+// snapshotForDryRun は、ドライランでステータスを更新しない場合に、処理前のアイテムと配信状況を返します。
+// それ以外の場合はnilを返します。
+func (b *MICSummaryBot) snapshotForDryRun(ctx context.Context, item *Item) *dryRunSnapshot {
+	if !b.discardsDryRunState() {
+		return nil
+	}
+	snapshot := &dryRunSnapshot{item: *item}
+	for _, kind := range []PostKind{PostKindSummary, PostKindNoValue, PostKindFallback} {
+		deliveries, err := b.itemRepository.GetDeliveries(ctx, item.ID, kind)
+		if err != nil {
+			pkgLogger.Error("Failed to get deliveries for dry run", "url", item.URL, "error", err)
+		}
+		snapshot.deliveries = append(snapshot.deliveries, deliveries...)
+	}
+	return snapshot
+}
+
+// discardsDryRunState は、ドライランでステータスを更新せず、処理の結果を残さない場合に true を返します。
+// この場合は判定と要約の結果も保存せず、実際に処理する際に改めて判定・要約します。
+func (b *MICSummaryBot) discardsDryRunState() bool {
+	return b.config.DryRun.Enabled && !b.config.DryRun.UpdateStatus
+}
+
+// restoreAfterDryRun はアイテムと配信状況をドライランの前の状態に戻します。
+func (b *MICSummaryBot) restoreAfterDryRun(ctx context.Context, snapshot *dryRunSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	if err := b.itemRepository.RestoreItem(ctx, &snapshot.item); err != nil {
+		pkgLogger.Error("Failed to restore item after dry run", "url", snapshot.item.URL, "error", err)
+	}
+	if err := b.itemRepository.ReplaceDeliveries(ctx, snapshot.item.ID, snapshot.deliveries); err != nil {
+		pkgLogger.Error("Failed to restore deliveries after dry run", "url", snapshot.item.URL, "error", err)
+	}
+	pkgLogger.Info("Restored item status after dry run", "url", snapshot.item.URL)
+}
+
+// postingTime はアイテムの要約を投稿する時刻を返します。
+// 予約投稿済みのアイテムは、残りの投稿先にも同じ時刻に配信するため、予約した時刻と true を返します。
+// ドライランでは投稿内容をすぐに確認できるよう、制限を適用しません。
+func (b *MICSummaryBot) postingTime(ctx context.Context, item *Item) (time.Time, bool, error) {
+	now := time.Now()
+	if b.config.DryRun.Enabled {
+		return now, false, nil
+	}
+	scheduledPosts, err := b.itemRepository.GetScheduledPosts(ctx, item.ID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if len(scheduledPosts) > 0 {
+		return scheduledPosts[0].ScheduledAt, true, nil
+	}
+
+	// 1日あたりの投稿数を数えるため、タイムゾーンによらず前日の0時以降を含むように取得する
+	history, err := b.itemRepository.GetPostTimes(ctx, now.Add(-48*time.Hour))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return b.schedule.nextSlot(now, history), false, nil
+}
+
+// scheduleSummary は予約投稿に対応した投稿先に、指定した時刻に公開する予約投稿として要約を送信します。
+// すべての投稿先への配信を終えた場合は true を返します。
+// 予約投稿に対応していない投稿先や、要約が複数の投稿になるため予約できない投稿先は、予約した時刻以降に通常どおり配信します。
+func (b *MICSummaryBot) scheduleSummary(ctx context.Context, item *Item, summary SummarizeResult, at time.Time) (bool, error) {
+	deliveries, err := b.itemRepository.GetDeliveries(ctx, item.ID, PostKindSummary)
+	if err != nil {
+		return false, err
+	}
+	sent := make(map[string]bool)
+	for _, d := range deliveries {
+		sent[d.Publisher] = d.Status == DeliverySent
+	}
+
+	done := true
+	var errs []error
+	for _, p := range b.publishers {
+		if sent[p.Name()] {
+			continue
+		}
+		scheduler, ok := p.(SummaryScheduler)
+		if !ok {
+			done = false
+			continue
+		}
+		scheduleErr := scheduler.ScheduleSummary(ctx, *item, summary, at)
+		if errors.Is(scheduleErr, errCannotSchedule) {
+			pkgLogger.Info("Publisher can't schedule the summary", "url", item.URL, "publisher", p.Name(), "reason", scheduleErr)
+			done = false
+			continue
+		}
+
+		if err := b.itemRepository.StartDelivery(ctx, item.ID, p.Name(), PostKindSummary); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if scheduleErr != nil {
+			pkgLogger.Error("Failed to schedule summary", "url", item.URL, "publisher", p.Name(), "error", scheduleErr)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), scheduleErr))
+		}
+		if err := b.itemRepository.FinishDelivery(ctx, item.ID, p.Name(), PostKindSummary, scheduleErr); err != nil {
+			pkgLogger.Error("Failed to record delivery result", "url", item.URL, "publisher", p.Name(), "error", err)
+		}
+	}
+	return done && len(errs) == 0, errors.Join(errs...)
+}
+
+// deliver は publishers のうち配信済みでない投稿先に配信し、投稿先ごとの配信状況を記録します。
+// 一部の投稿先で失敗した場合も残りの投稿先には配信し、失敗をまとめて返します。
+func (b *MICSummaryBot) deliver(ctx context.Context, item *Item, kind PostKind, publishers []Publisher, post func(Publisher) error) error {
+	deliveries, err := b.itemRepository.GetDeliveries(ctx, item.ID, kind)
+	if err != nil {
+		return err
+	}
+	sent := make(map[string]bool)
+	for _, d := range deliveries {
+		sent[d.Publisher] = d.Status == DeliverySent
+	}
+
+	var errs []error
+	for _, p := range publishers {
+		if sent[p.Name()] {
+			pkgLogger.Debug("Skipping already delivered publisher", "url", item.URL, "publisher", p.Name())
+			continue
+		}
+		if err := b.itemRepository.StartDelivery(ctx, item.ID, p.Name(), kind); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		postErr := post(p)
+		if postErr != nil {
+			pkgLogger.Error("Failed to deliver", "url", item.URL, "publisher", p.Name(), "kind", kind, "error", postErr)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), postErr))
+		}
+		if err := b.itemRepository.FinishDelivery(ctx, item.ID, p.Name(), kind, postErr); err != nil {
+			pkgLogger.Error("Failed to record delivery result", "url", item.URL, "publisher", p.Name(), "error", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliverDigest は publishers のうち配信済みでない投稿先にダイジェストを配信し、投稿先ごとの配信状況をダイジェストのIDで記録します。
+// 一部の投稿先で失敗した場合も残りの投稿先には配信し、失敗をまとめて返します。
+func (b *MICSummaryBot) deliverDigest(ctx context.Context, digest *Digest, publishers []Publisher) error {
+	deliveries, err := b.itemRepository.GetDigestDeliveries(ctx, digest.ID)
+	if err != nil {
+		return err
+	}
+	sent := make(map[string]bool)
+	for _, d := range deliveries {
+		sent[d.Publisher] = d.Status == DeliverySent
+	}
+
+	var errs []error
+	for _, p := range publishers {
+		if sent[p.Name()] {
+			pkgLogger.Debug("Skipping already delivered publisher", "digest_id", digest.ID, "publisher", p.Name())
+			continue
+		}
+		if err := b.itemRepository.StartDigestDelivery(ctx, digest.ID, p.Name()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		postErr := p.(DigestPublisher).PostDigest(ctx, *digest)
+		if postErr != nil {
+			pkgLogger.Error("Failed to deliver digest", "digest_id", digest.ID, "publisher", p.Name(), "error", postErr)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), postErr))
+		}
+		if err := b.itemRepository.FinishDigestDelivery(ctx, digest.ID, p.Name(), postErr); err != nil {
+			pkgLogger.Error("Failed to record delivery result", "digest_id", digest.ID, "publisher", p.Name(), "error", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// summarize はアイテムを要約し、結果を保存します。
+// いずれかの投稿先に配信を始めたアイテムや、予約投稿を記録したアイテムの場合は、同じ内容を配信するために保存済みの要約を返します。
+func (b *MICSummaryBot) summarize(ctx context.Context, item *Item) (SummarizeResult, error) {
+	deliveries, err := b.itemRepository.GetDeliveries(ctx, item.ID, PostKindSummary)
+	if err != nil {
+		return SummarizeResult{}, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+	scheduledPosts, err := b.itemRepository.GetScheduledPosts(ctx, item.ID)
+	if err != nil {
+		return SummarizeResult{}, fmt.Errorf("failed to get scheduled posts: %w", err)
+	}
+	if len(deliveries) > 0 || len(scheduledPosts) > 0 {
+		stored, err := b.itemRepository.GetLatestSummary(ctx, item.ID)
+		if err != nil {
+			return SummarizeResult{}, fmt.Errorf("failed to get stored summary: %w", err)
+		}
+		if stored != nil {
+			pkgLogger.Info("Using stored summary", "url", item.URL, "deliveries", len(deliveries), "scheduled_posts", len(scheduledPosts))
+			return *stored, nil
+		}
+	}
+
+	summary, reason, err := b.generateSummary(ctx, item)
+	if err != nil {
+		b.setItemToDeferred(ctx, item, StageSummarization, reason, err, "Failed to summarize item")
+		return SummarizeResult{}, err
+	}
+	return summary, nil
+}
+
+// generateSummary はアイテムを要約して別版を生成し、結果を保存します。
+// 失敗した場合は、アイテムを保留する際の理由コードも返します。
+func (b *MICSummaryBot) generateSummary(ctx context.Context, item *Item) (SummarizeResult, ItemReasonCode, error) {
 	pkgLogger.Debug("Starting HTML parsing", "url", item.URL)
 	htmlAndDocs, err := GetHTMLSummary(item.URL)
 	if err != nil {
-		b.setItemToDeferred(ctx, item, ReasonDownloadFailed, err, "Failed to parse HTML")
-		return fmt.Errorf("failed to parse html: %w", err)
+		return SummarizeResult{}, ReasonDownloadFailed, fmt.Errorf("failed to parse html: %w", err)
 	}
 	pkgLogger.Debug("HTML parsing completed successfully", "url", item.URL)
 
 	pkgLogger.Debug("Starting document summarization", "url", item.URL)
 	summary, err := b.genAIClient.SummarizeDocument(htmlAndDocs, b.config.Gemini.SummarizingPrompt)
 	if err != nil {
-		b.setItemToDeferred(ctx, item, ReasonAPIFailed, err, "Failed to summarize content")
-		return fmt.Errorf("failed to summarize content: %w", err)
+		return SummarizeResult{}, ReasonAPIFailed, fmt.Errorf("failed to summarize content: %w", err)
 	}
 	pkgLogger.Debug("Document summarization completed", "url", item.URL)
 
-	pkgLogger.Debug("Starting Mastodon post", "url", item.URL)
-	if err := b.mastodonClient.PostSummary(ctx, *item, summary); err != nil {
-		b.setItemToDeferred(ctx, item, ReasonAPIFailed, err, "Failed to post to Mastodon")
-		return fmt.Errorf("failed to post to mastodon: %w", err)
+	if len(b.config.Variants) > 0 {
+		pkgLogger.Debug("Starting summary variant generation", "url", item.URL)
+		variants, err := b.genAIClient.GenerateVariants(summary, b.config.Variants)
+		if err != nil {
+			// 別版はおまけなので、生成に失敗してもメインの要約と生成できた別版は投稿する
+			pkgLogger.Error("Failed to generate some summary variants", "url", item.URL, "generated", len(variants), "error", err)
+		}
+		summary.Variants = variants
 	}
-	pkgLogger.Debug("Mastodon post completed successfully", "url", item.URL)
 
-	pkgLogger.Debug("Updating item status", "url", item.URL)
-	item.Status = StatusProcessed
-	item.Reason = ReasonNone
-	if err := b.itemRepository.Update(ctx, item); err != nil {
-		pkgLogger.Error("Failed to update item status", "url", item.URL, "error", err)
-		return fmt.Errorf("failed to mark as posted: %w", err)
+	if b.discardsDryRunState() {
+		return summary, ReasonNone, nil
+	}
+	if _, err := b.itemRepository.SaveSummary(ctx, item.ID, &summary); err != nil {
+		pkgLogger.Error("Failed to save summary", "url", item.URL, "error", err)
 	}
-	pkgLogger.Debug("Item status updated successfully", "url", item.URL)
 
-	pkgLogger.Info("Finish posting summary")
-	return nil
+	return summary, ReasonNone, nil
+}
+
+// getItem はIDでアイテムを取得します。見つからない場合はエラーを返します。
+func (b *MICSummaryBot) getItem(ctx context.Context, itemID int) (*Item, error) {
+	item, err := b.itemRepository.GetItemByID(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("item ID %d not found", itemID)
+	}
+	return item, nil
+}
+
+// ResummarizeItem はアイテムを要約し直し、要約を配信済みの投稿先の投稿を新しい要約に編集します。
+// 編集に対応していない投稿先はスキップします。アイテムのステータスは変更しません。
+func (b *MICSummaryBot) ResummarizeItem(ctx context.Context, itemID int) error {
+	item, err := b.getItem(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	b.resolveScheduledPosts(ctx)
+	pkgLogger.Info("Start re-summarizing item", "url", item.URL)
+
+	summary, _, err := b.generateSummary(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := b.itemRepository.GetDeliveries(ctx, item.ID, PostKindSummary)
+	if err != nil {
+		return err
+	}
+	sent := make(map[string]bool)
+	for _, d := range deliveries {
+		sent[d.Publisher] = d.Status == DeliverySent
+	}
+
+	var errs []error
+	for _, p := range b.publishers {
+		if !sent[p.Name()] {
+			continue
+		}
+		editor, ok := p.(SummaryEditor)
+		if !ok {
+			pkgLogger.Warn("Publisher does not support editing posts", "publisher", p.Name())
+			continue
+		}
+		if err := editor.EditSummary(ctx, *item, summary); err != nil {
+			pkgLogger.Error("Failed to edit summary", "url", item.URL, "publisher", p.Name(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	pkgLogger.Info("Finish re-summarizing item", "url", item.URL)
+	return errors.Join(errs...)
+}
+
+// DeleteItemPosts はアイテムについての投稿をすべての投稿先から削除します。
+// 削除に対応していない投稿先はスキップします。
+func (b *MICSummaryBot) DeleteItemPosts(ctx context.Context, itemID int) error {
+	item, err := b.getItem(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	b.resolveScheduledPosts(ctx)
+
+	var errs []error
+	for _, p := range b.publishers {
+		deleter, ok := p.(PostDeleter)
+		if !ok {
+			pkgLogger.Warn("Publisher does not support deleting posts", "publisher", p.Name())
+			continue
+		}
+		if err := deleter.DeletePosts(ctx, *item); err != nil {
+			pkgLogger.Error("Failed to delete posts", "url", item.URL, "publisher", p.Name(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReplyToItem はアイテムについての最初の投稿へのリプライとしてテキストを投稿します。
+// 続報や訂正を元の投稿に紐付けるために使います。リプライに対応していない投稿先はスキップします。
+func (b *MICSummaryBot) ReplyToItem(ctx context.Context, itemID int, text string) error {
+	item, err := b.getItem(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	b.resolveScheduledPosts(ctx)
+
+	var errs []error
+	for _, p := range b.publishers {
+		replier, ok := p.(PostReplier)
+		if !ok {
+			pkgLogger.Warn("Publisher does not support replies", "publisher", p.Name())
+			continue
+		}
+		if err := replier.Reply(ctx, *item, text); err != nil {
+			pkgLogger.Error("Failed to post reply", "url", item.URL, "publisher", p.Name(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (b *MICSummaryBot) ScreenItem(ctx context.Context) (err error) {
@@ -151,6 +702,11 @@ func (b *MICSummaryBot) ScreenItem(ctx context.Context) (err error) {
 		}
 	}()
 
+	if b.isPaused(ctx) {
+		pkgLogger.Info("Bot is paused, skipping screening")
+		return nil
+	}
+
 	item, err := b.itemRepository.GetItemForScreening(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get item for screening: %w", err)
@@ -161,44 +717,185 @@ func (b *MICSummaryBot) ScreenItem(ctx context.Context) (err error) {
 		return nil
 	}
 
-	pkgLogger.Info("Screening item", "url", item.URL)
+	defer b.restoreAfterDryRun(ctx, b.snapshotForDryRun(ctx, item))
 
-	htmlAndDocs, err := GetHTMLSummary(item.URL)
+	screeningResult, err := b.postponedScreening(ctx, item)
 	if err != nil {
-		b.setItemToDeferred(ctx, item, ReasonDownloadFailed, err, "Failed to parse HTML")
-		return fmt.Errorf("failed to parse html: %w", err)
+		return err
 	}
+	if screeningResult != nil {
+		pkgLogger.Info("Reusing screening result of postponed item", "url", item.URL, "result", screeningResult.FinalResult)
+	} else {
+		pkgLogger.Info("Screening item", "url", item.URL)
 
-	screeningResult, err := b.genAIClient.IsWorthSummarizing(htmlAndDocs, b.config.Gemini.ScreeningPrompt)
-	if err != nil {
-		b.setItemToDeferred(ctx, item, ReasonAPIFailed, err, "Failed to screen item")
-		return fmt.Errorf("failed to screen item: %w", err)
+		htmlAndDocs, err := GetHTMLSummary(item.URL)
+		if err != nil {
+			b.setItemToDeferred(ctx, item, StageScreening, ReasonDownloadFailed, err, "Failed to parse HTML")
+			return fmt.Errorf("failed to parse html: %w", err)
+		}
+
+		screeningResult, err = b.genAIClient.IsWorthSummarizing(htmlAndDocs, b.config.Gemini.ScreeningPrompt)
+		if err != nil {
+			b.setItemToDeferred(ctx, item, StageScreening, ReasonAPIFailed, err, "Failed to screen item")
+			return fmt.Errorf("failed to screen item: %w", err)
+		}
+		pkgLogger.Info("Item screening result", "url", item.URL, "result", screeningResult.FinalResult, "model", screeningResult.Model)
+		for _, criterion := range screeningResult.Criteria {
+			pkgLogger.Debug("Screening criterion", "url", item.URL, "name", criterion.Name, "result", criterion.Result, "thoughts", criterion.Thoughts)
+		}
+		if !b.discardsDryRunState() {
+			if _, err := b.itemRepository.AddScreeningResult(ctx, item.ID, screeningResult); err != nil {
+				pkgLogger.Error("Failed to save screening result", "url", item.URL, "error", err)
+			}
+		}
 	}
-	pkgLogger.Info("Item screening result", "url", item.URL, "result", screeningResult.FinalResult)
 
 	switch screeningResult.FinalResult {
 	case WorthSummarizingYes:
 		item.Status = StatusPending
-		if err := b.itemRepository.Update(ctx, item); err != nil {
+		if err := b.itemRepository.Update(ctx, item, ItemTransition{Stage: StageScreening}); err != nil {
 			return fmt.Errorf("failed to mark as pending: %w", err)
 		}
 	case WorthSummarizingNo:
+		// 要約対象外の投稿にも、要約と同じ投稿の時間帯と頻度の制限を適用する
+		publishers := b.noValuePublishers()
+		if len(publishers) > 0 {
+			postAt, _, err := b.postingTime(ctx, item)
+			if err != nil {
+				return err
+			}
+			if postAt.After(time.Now()) {
+				pkgLogger.Info("Postponing no value post by posting schedule", "url", item.URL, "until", postAt)
+				b.releaseItem(ctx, item, postAt)
+				return nil
+			}
+		}
 		item.Status = StatusProcessed
 		item.Reason = ReasonGeminiNotValuable
-		if err := b.itemRepository.Update(ctx, item); err != nil {
+		if err := b.itemRepository.Update(ctx, item, ItemTransition{Stage: StageScreening}); err != nil {
 			return fmt.Errorf("failed to mark as not valuable: %w", err)
 		}
-		if err := b.mastodonClient.PostNoValue(ctx, *item); err != nil {
-			return fmt.Errorf("failed to post no value message to mastodon: %w", err)
+		b.addToDigest(ctx, item, PostKindNoValue)
+		err := b.deliver(ctx, item, PostKindNoValue, publishers, func(p Publisher) error {
+			return p.PostNoValue(ctx, *item, screeningResult)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to deliver no value message: %w", err)
 		}
 	case WorthSummarizingWait:
-		item.Status = StatusDeferred
-		item.Reason = ReasonGeminiPageNotReady
-		item.RetryCount++
-		if err := b.itemRepository.Update(ctx, item); err != nil {
+		if err := b.deferItem(ctx, item, StageScreening, ReasonGeminiPageNotReady, nil); err != nil {
 			return fmt.Errorf("failed to mark as not ready: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// postponedScreening は、投稿の制限で要約対象外の投稿を後回しにした未処理のアイテムについて、保存したスクリーニングの結果を返します。
+// 再びスクリーニングせずに投稿するために使います。該当しない場合はnilを返します。
+func (b *MICSummaryBot) postponedScreening(ctx context.Context, item *Item) (*ScreeningResult, error) {
+	if item.Status != StatusUnprocessed {
+		return nil, nil
+	}
+	records, err := b.itemRepository.GetScreeningResults(ctx, item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screening results: %w", err)
+	}
+	if len(records) == 0 || records[len(records)-1].FinalResult != WorthSummarizingNo {
+		return nil, nil
+	}
+	return &records[len(records)-1].Result, nil
+}
+
+// noValuePublishers は要約対象外のアイテムを1件ずつ投稿する投稿先を返します。
+// ダイジェストが有効な場合、ダイジェストに対応した投稿先は除きます。
+func (b *MICSummaryBot) noValuePublishers() []Publisher {
+	if !b.config.Digest.Enabled {
+		return b.publishers
+	}
+	var publishers []Publisher
+	for _, p := range b.publishers {
+		if _, ok := p.(DigestPublisher); !ok {
+			publishers = append(publishers, p)
+		}
+	}
+	return publishers
+}
+
+// addToDigest はダイジェストが有効な場合に、アイテムを次のダイジェストに追加します。
+// 要約を投稿したアイテムは include_summarized が true の場合のみ追加します。
+// ドライランでステータスを更新しない場合は追加しません。
+func (b *MICSummaryBot) addToDigest(ctx context.Context, item *Item, kind PostKind) {
+	if !b.config.Digest.Enabled || (kind == PostKindSummary && !b.config.Digest.IncludeSummarized) {
+		return
+	}
+	if b.discardsDryRunState() {
+		return
+	}
+	if err := b.itemRepository.AddDigestItem(ctx, item.ID, kind); err != nil {
+		pkgLogger.Error("Failed to add item to digest", "url", item.URL, "error", err)
+	}
+}
+
+// PostDigest はダイジェストにまとめたアイテムを、ダイジェストに対応した投稿先に投稿します。
+// 1日1回など、外部のスケジューラから定期的に呼び出すことを想定しています。
+// 一部の投稿先で失敗した場合は、次回の呼び出しで同じダイジェストを失敗した投稿先にだけ投稿します。
+func (b *MICSummaryBot) PostDigest(ctx context.Context) (err error) {
+	defer func() {
+		if panicErr := handlePanic("PostDigest"); panicErr != nil {
+			err = panicErr
+		}
+	}()
+
+	if !b.config.Digest.Enabled {
+		pkgLogger.Info("Digest is disabled")
+		return nil
+	}
+	if b.isPaused(ctx) {
+		pkgLogger.Info("Bot is paused, skipping posting digest")
+		return nil
+	}
+	pkgLogger.Info("Start posting digest")
+
+	var publishers []Publisher
+	for _, p := range b.publishers {
+		if _, ok := p.(DigestPublisher); ok {
+			publishers = append(publishers, p)
+		}
+	}
+
+	// ドライランでステータスを更新しない場合は、ダイジェストを作成せずに投稿内容だけを出力する
+	if b.config.DryRun.Enabled && !b.config.DryRun.UpdateStatus {
+		digest, err := b.itemRepository.GetPendingDigest(ctx)
+		if err != nil || digest == nil {
+			return err
+		}
+		var errs []error
+		for _, p := range publishers {
+			if err := p.(DigestPublisher).PostDigest(ctx, *digest); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	digest, err := b.itemRepository.OpenDigest(ctx)
+	if err != nil {
+		return err
+	}
+	if digest == nil {
+		pkgLogger.Info("No items for digest")
+		return nil
+	}
+
+	err = b.deliverDigest(ctx, digest, publishers)
+	if err != nil {
+		return fmt.Errorf("failed to deliver digest: %w", err)
+	}
+	if err := b.itemRepository.CompleteDigest(ctx, digest.ID); err != nil {
+		return err
+	}
+
+	pkgLogger.Info("Finish posting digest", "digest_id", digest.ID, "items", len(digest.Items))
+	return nil
+}
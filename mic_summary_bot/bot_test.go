@@ -0,0 +1,234 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestMICSummaryBot_SweepRetryLimitExceeded(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	server, requests := newPublisherTestServer(t, map[string]func(w http.ResponseWriter){
+		"/api/v1/statuses": func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"301","url":"https://example.com/@bot/301"}`)
+		},
+		"/api/v1/accounts/42": func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"42","acct":"admin"}`)
+		},
+	})
+	config := DefaultConfig()
+	config.Mastodon.Enabled = true
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	config.Publishers = []PublisherConfig{{Type: PublisherWebhook, URL: server.URL + "/webhook"}}
+	config.Retry.FallbackPost = true
+	config.Retry.NotifyAdmin = true
+	config.Admin.AccountIDs = []string{"42"}
+
+	mastodonClient, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+	publishers, err := NewPublishers(config, repo, mastodonClient)
+	require.NoError(t, err)
+	retry, err := newRetryPolicy(&config.Retry)
+	require.NoError(t, err)
+	bot := &MICSummaryBot{mastodonClient: mastodonClient, publishers: publishers, itemRepository: repo, retry: retry, config: config}
+
+	// setupTestDB のアイテムは3回まで再試行する
+	now := time.Now().UTC()
+	over := &Item{URL: "https://www.soumu.go.jp/1.html", Title: "会議の開催", PublishedAt: now, Status: StatusDeferred, Reason: ReasonDownloadFailed, RetryCount: 3, CreatedAt: now, LastCheckedAt: now}
+	under := &Item{URL: "https://www.soumu.go.jp/2.html", Title: "報道発表", PublishedAt: now, Status: StatusDeferred, Reason: ReasonDownloadFailed, RetryCount: 2, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, over))
+	require.NoError(t, repo.insert(ctx, under))
+
+	require.NoError(t, bot.SweepRetryLimitExceeded(ctx))
+
+	swept, err := repo.GetItemByID(ctx, over.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusProcessed, swept.Status)
+	assert.Equal(t, ReasonRetryLimitExceeded, swept.Reason)
+	kept, err := repo.GetItemByID(ctx, under.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDeferred, kept.Status, "items under the limit are retried later")
+
+	require.Len(t, *requests, 4)
+	fallback, err := url.ParseQuery(string((*requests)[0].Body))
+	require.NoError(t, err)
+	assert.Equal(t, "会議の開催\n要約できませんでした\nhttps://www.soumu.go.jp/1.html\n", fallback.Get("status"))
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal((*requests)[1].Body, &payload))
+	assert.Equal(t, WebhookEventFallback, payload.Event)
+	assert.Equal(t, "/api/v1/accounts/42", (*requests)[2].Path)
+	dm, err := url.ParseQuery(string((*requests)[3].Body))
+	require.NoError(t, err)
+	assert.Equal(t, "@admin 再試行をあきらめました(再試行の上限超過): 会議の開催\nhttps://www.soumu.go.jp/1.html", dm.Get("status"))
+	assert.Equal(t, "direct", dm.Get("visibility"))
+
+	posts, err := repo.GetPosts(ctx, over.ID, mastodonPublisherName, PostKindFallback)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+	deliveries, err := repo.GetDeliveries(ctx, over.ID, PostKindFallback)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+
+	reply, _ := bot.executeAdminCommand(ctx, "status")
+	assert.Contains(t, reply, "処理済み: 1\nうち再試行の上限超過: 1\nうち再試行の期限切れ: 0")
+}
+
+func TestMICSummaryBot_ScreenItem_postponesNoValue(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	server, requests := newPublisherTestServer(t, map[string]func(w http.ResponseWriter){
+		"/api/v1/statuses": func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"301","url":"https://example.com/@bot/301"}`)
+		},
+	})
+	config := DefaultConfig()
+	config.Mastodon.Enabled = true
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	mastodonClient, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+	publishers, err := NewPublishers(config, repo, mastodonClient)
+	require.NoError(t, err)
+	// 現在時刻を含む静穏時間
+	now := time.Now().UTC()
+	quiet, err := newPostingSchedule(&ScheduleConfig{QuietHoursStart: now.Add(-time.Hour).Format("15:04"), QuietHoursEnd: now.Add(time.Hour).Format("15:04")})
+	require.NoError(t, err)
+	bot := &MICSummaryBot{mastodonClient: mastodonClient, publishers: publishers, itemRepository: repo, schedule: quiet, config: config}
+
+	item := &Item{URL: "https://www.soumu.go.jp/1.html", Title: "会議の開催", PublishedAt: now, Status: StatusUnprocessed, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, item))
+	// 静穏時間に要約対象外と判定したアイテム。スクリーニングの結果は保存済み
+	_, err = repo.AddScreeningResult(ctx, item.ID, &ScreeningResult{FinalResult: WorthSummarizingNo})
+	require.NoError(t, err)
+
+	require.NoError(t, bot.ScreenItem(ctx))
+	assert.Empty(t, *requests, "no value post is postponed in quiet hours")
+	postponed, err := repo.GetItemByID(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUnprocessed, postponed.Status)
+	next, err := repo.GetItemForScreening(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, next, "the item is not screened again until the quiet hours end")
+
+	// 静穏時間が終わると、保存した結果を使って投稿する
+	bot.schedule, err = newPostingSchedule(&ScheduleConfig{})
+	require.NoError(t, err)
+	require.NoError(t, repo.ReleaseItem(ctx, item.ID, time.Time{}))
+	require.NoError(t, bot.ScreenItem(ctx))
+	require.Len(t, *requests, 1)
+	processed, err := repo.GetItemByID(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusProcessed, processed.Status)
+	assert.Equal(t, ReasonGeminiNotValuable, processed.Reason)
+	results, err := repo.GetScreeningResults(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Len(t, results, 1, "the item is not screened again")
+}
+
+func TestMICSummaryBot_ScreenItem_dryRun(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page.html" {
+			fmt.Fprint(w, `<html><body><div class="contentsBody"><p>meeting</p></div></body></html>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "{\"criteria\": [], \"final_result\": \"NO\"}"}]}}]}`)
+	}))
+	defer server.Close()
+	genaiClient, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	require.NoError(t, err)
+
+	config := DefaultConfig()
+	config.Mastodon.Enabled = false
+	config.DryRun.Enabled = true
+	config.DryRun.OutputDir = t.TempDir()
+	publishers, err := NewPublishers(config, repo, nil)
+	require.NoError(t, err)
+	schedule, err := newPostingSchedule(&config.Schedule)
+	require.NoError(t, err)
+	retry, err := newRetryPolicy(&config.Retry)
+	require.NoError(t, err)
+	bot := &MICSummaryBot{
+		genAIClient:    &GenAIClient{Client: genaiClient, ScreeningModel: ModelChain{{Name: "model"}}},
+		publishers:     publishers,
+		itemRepository: repo,
+		schedule:       schedule,
+		retry:          retry,
+		config:         config,
+	}
+
+	now := time.Now().UTC()
+	item := &Item{URL: server.URL + "/page.html", Title: "会議の開催", PublishedAt: now, Status: StatusUnprocessed, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, item))
+
+	require.NoError(t, bot.ScreenItem(ctx))
+
+	// 判定の結果を残さず、実際に処理する際に改めて判定する
+	restored, err := repo.GetItemByID(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUnprocessed, restored.Status)
+	results, err := repo.GetScreeningResults(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMICSummaryBot_summarize_storedSummary(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var pageRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageRequests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	retry, err := newRetryPolicy(&config.Retry)
+	require.NoError(t, err)
+	bot := &MICSummaryBot{itemRepository: repo, retry: retry, config: config}
+
+	now := time.Now().UTC()
+	item := &Item{URL: server.URL + "/page.html", Title: "会議の開催", PublishedAt: now, Status: StatusPending, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, item))
+	_, err = repo.SaveSummary(ctx, item.ID, &SummarizeResult{FinalSummary: "保存済みの要約。"})
+	require.NoError(t, err)
+
+	// 配信も予約もしていないアイテムは、保存済みの要約があっても要約し直す
+	_, err = bot.summarize(ctx, item)
+	assert.Error(t, err)
+	assert.Equal(t, 1, pageRequests)
+
+	require.NoError(t, repo.AddScheduledPost(ctx, &ScheduledPost{ItemID: item.ID, Publisher: mastodonPublisherName, ScheduledID: "42", ScheduledAt: now.Add(time.Hour)}))
+	summary, err := bot.summarize(ctx, item)
+	require.NoError(t, err)
+	assert.Equal(t, "保存済みの要約。", summary.FinalSummary)
+	assert.Equal(t, 1, pageRequests, "the stored summary of the scheduled item is reused")
+}
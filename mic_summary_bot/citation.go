@@ -0,0 +1,74 @@
+package micsummarybot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SummarySource は要約の1文の根拠となった資料を表します。
+type SummarySource struct {
+	// DocumentIndex は送信したPDFの順番(1始まり)。Webページの場合は0
+	DocumentIndex int `json:"document_index" genai:"required"`
+	// Page は根拠となったページ番号(1始まり)。不明な場合は0
+	Page int `json:"page,omitempty"`
+}
+
+// SummarySentence は出典付きの要約の1文を表します。
+type SummarySentence struct {
+	Text    string          `json:"text" genai:"required"`
+	Sources []SummarySource `json:"sources" genai:"required"`
+}
+
+// PostSource は投稿に付与する番号付きの出典リンクを表します。
+type PostSource struct {
+	Number int
+	Label  string
+	URL    string
+}
+
+// buildCitations は出典番号を付与した最終要約と、番号付きの出典リストを返します。
+// 出典は最初に参照された順に1から番号を振ります。ページ番号がある場合は [1, p.3] のように付与します。
+// 出典付きの文がない場合は最終要約をそのまま返します。
+func buildCitations(pageURL string, summary SummarizeResult) (string, []PostSource) {
+	if len(summary.FinalSummarySentences) == 0 {
+		return summary.FinalSummary, nil
+	}
+
+	var sources []PostSource
+	numbers := make(map[int]int)
+	var buf strings.Builder
+	for _, sentence := range summary.FinalSummarySentences {
+		buf.WriteString(sentence.Text)
+		for _, source := range sentence.Sources {
+			number, ok := numbers[source.DocumentIndex]
+			if !ok {
+				label, url, valid := sourceLink(pageURL, summary.Documents, source.DocumentIndex)
+				if !valid {
+					pkgLogger.Warn("Ignoring citation to unknown document", "document_index", source.DocumentIndex)
+					continue
+				}
+				number = len(sources) + 1
+				numbers[source.DocumentIndex] = number
+				sources = append(sources, PostSource{Number: number, Label: label, URL: url})
+			}
+			if source.Page > 0 {
+				fmt.Fprintf(&buf, "[%d, p.%d]", number, source.Page)
+			} else {
+				fmt.Fprintf(&buf, "[%d]", number)
+			}
+		}
+	}
+	return buf.String(), sources
+}
+
+// sourceLink は出典のドキュメント番号に対応するラベルとURLを返します。
+func sourceLink(pageURL string, documents []DocumentSummary, index int) (string, string, bool) {
+	if index == 0 {
+		return "", pageURL, true
+	}
+	if index < 0 || index > len(documents) || documents[index-1].URL == "" {
+		return "", "", false
+	}
+	doc := documents[index-1]
+	return doc.Label, doc.URL, true
+}
@@ -0,0 +1,51 @@
+package micsummarybot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCitations(t *testing.T) {
+	pageURL := "https://www.soumu.go.jp/menu_news/s-news/example.html"
+	documents := []DocumentSummary{
+		{Summary: "資料1", URL: "https://www.soumu.go.jp/main_content/001.pdf", Label: "資料1"},
+		{Summary: "資料2", URL: "https://www.soumu.go.jp/main_content/002.pdf", Label: "資料2"},
+	}
+
+	t.Run("no citations", func(t *testing.T) {
+		cited, sources := buildCitations(pageURL, SummarizeResult{FinalSummary: "要約である。"})
+		assert.Equal(t, "要約である。", cited)
+		assert.Empty(t, sources)
+	})
+
+	t.Run("numbered in order of appearance", func(t *testing.T) {
+		summary := SummarizeResult{
+			Documents: documents,
+			FinalSummarySentences: []SummarySentence{
+				{Text: "会議が開催された。", Sources: []SummarySource{{DocumentIndex: 0}}},
+				{Text: "方針が示された。", Sources: []SummarySource{{DocumentIndex: 2, Page: 3}, {DocumentIndex: 1}}},
+				{Text: "次回も議論する。", Sources: []SummarySource{{DocumentIndex: 2}}},
+			},
+		}
+		cited, sources := buildCitations(pageURL, summary)
+		assert.Equal(t, "会議が開催された。[1]方針が示された。[2, p.3][3]次回も議論する。[2]", cited)
+		assert.Equal(t, []PostSource{
+			{Number: 1, URL: pageURL},
+			{Number: 2, Label: "資料2", URL: "https://www.soumu.go.jp/main_content/002.pdf"},
+			{Number: 3, Label: "資料1", URL: "https://www.soumu.go.jp/main_content/001.pdf"},
+		}, sources)
+	})
+
+	t.Run("unknown document is ignored", func(t *testing.T) {
+		summary := SummarizeResult{
+			Documents: documents,
+			FinalSummarySentences: []SummarySentence{
+				{Text: "存在しない資料。", Sources: []SummarySource{{DocumentIndex: 5}}},
+			},
+		}
+		cited, sources := buildCitations(pageURL, summary)
+		assert.Equal(t, "存在しない資料。", cited)
+		assert.Empty(t, sources)
+	})
+}
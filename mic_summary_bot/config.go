@@ -1,6 +1,7 @@
 package micsummarybot
 
 import (
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v2"
@@ -13,45 +14,338 @@ var exampleConfig string
 
 // Config は Bot の設定情報を保持する
 type Config struct {
-	RSS      RSSConfig      `yaml:"rss"`
-	Gemini   GeminiConfig   `yaml:"gemini"`
-	Mastodon MastodonConfig `yaml:"mastodon"`
-	Storage  StorageConfig  `yaml:"storage"`
-	Database DatabaseConfig `yaml:"database"`
+	RSS      RSSConfig       `yaml:"rss"`
+	Gemini   GeminiConfig    `yaml:"gemini"`
+	Mastodon MastodonConfig  `yaml:"mastodon"`
+	Storage  StorageConfig   `yaml:"storage"`
+	Database DatabaseConfig  `yaml:"database"`
+	Variants []VariantConfig `yaml:"variants"`
+	// Publishers はMastodon以外の投稿先
+	Publishers []PublisherConfig `yaml:"publishers"`
+	Schedule   ScheduleConfig    `yaml:"schedule"`
+	Retry      RetryConfig       `yaml:"retry"`
+	DryRun     DryRunConfig      `yaml:"dry_run"`
+	Digest     DigestConfig      `yaml:"digest"`
+	Responder  ResponderConfig   `yaml:"responder"`
+	Admin      AdminConfig       `yaml:"admin"`
+}
+
+// AdminConfig はMastodonのDMで受け付ける管理者のコマンドの設定を保持する
+type AdminConfig struct {
+	// Enabled が true の場合、管理者からのDMをコマンドとして実行し、結果をDMで返信する
+	Enabled bool `yaml:"enabled"`
+	// AccountIDs は管理者のアカウントID。Botのインスタンスから見たIDで、acct と異なり変更やなりすましができない
+	AccountIDs []string `yaml:"account_ids"`
+}
+
+// ResponderConfig は投稿した要約へのリプライで受けた質問に回答する機能の設定を保持する
+type ResponderConfig struct {
+	// Enabled が true の場合、Mastodonのメンションを取得し、要約の投稿へのリプライに回答する
+	Enabled bool `yaml:"enabled"`
+	// Prompt は回答の生成に使うプロンプト
+	Prompt string `yaml:"prompt"`
+	// MaxRepliesPerHour は直近1時間に回答する最大数。0で無制限
+	MaxRepliesPerHour int `yaml:"max_replies_per_hour"`
+	// MaxRepliesPerAccountPerHour は1アカウントあたり直近1時間に回答する最大数。0で無制限
+	MaxRepliesPerAccountPerHour int `yaml:"max_replies_per_account_per_hour"`
+	// BlockedAccounts は回答しないアカウント("user" または "user@example.com")
+	BlockedAccounts []string `yaml:"blocked_accounts"`
+	// BlockedDomains は回答しないインスタンスのドメイン
+	BlockedDomains []string `yaml:"blocked_domains"`
+}
+
+// DigestConfig は1日分のアイテムをまとめて投稿するダイジェストの設定を保持する
+type DigestConfig struct {
+	// Enabled が true の場合、要約対象外のアイテムを1件ずつ投稿せず、ダイジェストにまとめる
+	Enabled bool `yaml:"enabled"`
+	// IncludeSummarized が true の場合、要約を投稿したアイテムもダイジェストに含める
+	IncludeSummarized bool `yaml:"include_summarized"`
+	// PostTemplate はダイジェストの投稿テンプレート。最大文字数を超える場合はアイテムの区切りでスレッドに分割される
+	PostTemplate string `yaml:"post_template"`
+}
+
+// DryRunConfig は投稿せずに投稿内容を出力するドライランの設定を保持する
+type DryRunConfig struct {
+	// Enabled が true の場合、スクリーニングと要約は通常どおり行い、投稿の代わりに投稿内容を出力する
+	Enabled bool `yaml:"enabled"`
+	// OutputDir は投稿内容を書き出すディレクトリ。空の場合は標準出力に書き出す
+	OutputDir string `yaml:"output_dir"`
+	// UpdateStatus が true の場合、アイテムのステータスと配信状況を通常どおり更新する。
+	// false の場合は処理後に元に戻すため、同じアイテムを繰り返し試せる
+	UpdateStatus bool `yaml:"update_status"`
+}
+
+// ScheduleDeferral は投稿できない時間帯の要約の扱いを表す
+type ScheduleDeferral string
+
+const (
+	// ScheduleDeferralWait は投稿できるようになるまで要約をキューに残す
+	ScheduleDeferralWait ScheduleDeferral = "wait"
+	// ScheduleDeferralSchedule は要約してMastodonの予約投稿として送信する
+	ScheduleDeferralSchedule ScheduleDeferral = "schedule"
+)
+
+// ScheduleConfig は要約を投稿する時間帯と頻度の設定を保持する
+type ScheduleConfig struct {
+	// Timezone は静穏時間と1日の区切りに使うタイムゾーン
+	Timezone string `yaml:"timezone"`
+	// QuietHoursStart と QuietHoursEnd は投稿しない時間帯("HH:MM")。日付をまたいでもよい。空の場合は制限しない
+	QuietHoursStart string `yaml:"quiet_hours_start"`
+	QuietHoursEnd   string `yaml:"quiet_hours_end"`
+	// MaxPostsPerHour は直近1時間の最大投稿数。0で無制限
+	MaxPostsPerHour int `yaml:"max_posts_per_hour"`
+	// MaxPostsPerDay は1日(Timezone の0時から)の最大投稿数。0で無制限
+	MaxPostsPerDay int `yaml:"max_posts_per_day"`
+	// MinIntervalSec は投稿の最小間隔
+	MinIntervalSec int              `yaml:"min_interval_sec"`
+	Deferral       ScheduleDeferral `yaml:"deferral"`
+}
+
+// RetryConfig は先送りしたアイテムを再び処理するまでの間隔と、再試行をあきらめるまでの時間、あきらめた場合の動作の設定
+type RetryConfig struct {
+	Backoff RetryBackoffConfig `yaml:"backoff"`
+	// MaxAge はアイテムを追加してから、再試行をあきらめて処理済みにするまでの時間("168h" など)。空の場合は制限しない
+	MaxAge string `yaml:"max_age"`
+	// FallbackPost が true の場合、再試行をあきらめたアイテムについて、要約の代わりに FallbackPostTemplate のテキストを投稿する
+	FallbackPost bool `yaml:"fallback_post"`
+	// FallbackPostTemplate は要約の代わりに投稿するテキストのテンプレート。.Title, .URL, .PublishedAt, .FeedName を参照できる
+	FallbackPostTemplate string `yaml:"fallback_post_template"`
+	// NotifyAdmin が true の場合、再試行をあきらめたアイテムを admin.account_ids の管理者にMastodonのDMで知らせる
+	NotifyAdmin bool `yaml:"notify_admin"`
+}
+
+// RetryBackoffConfig は先送りの理由ごとの、再び処理するまでの間隔("6h" など)。
+// 先送りした回数に応じて順に使い、使い切った後は最後の値を繰り返す
+type RetryBackoffConfig struct {
+	// PageNotReady はページがまだ完成していないと判定された場合
+	PageNotReady []string `yaml:"page_not_ready"`
+	// DownloadFailed はページや資料のダウンロードに失敗した場合
+	DownloadFailed []string `yaml:"download_failed"`
+	// APIFailed はGeminiや投稿先のAPIの呼び出しに失敗した場合
+	APIFailed []string `yaml:"api_failed"`
+	// Default はそれ以外の理由の場合
+	Default []string `yaml:"default"`
 }
 
 type RSSConfig struct {
 	URL string `yaml:"url"`
+	// Name はフィードの名前。投稿テンプレートでは .FeedName で参照できる
+	Name string `yaml:"name"`
 }
 
 type GeminiConfig struct {
-	APIKey            string `yaml:"api_key"`
-	MaxTokens         int    `yaml:"max_tokens"`
-	RetryCount        int    `yaml:"retry_count"`
-	RetryIntervalSec  int    `yaml:"retry_interval_sec"`
-	ScreeningModel    string `yaml:"screening_model"`
-	ScreeningPrompt   string `yaml:"screening_prompt"`
-	SummarizingModel  string `yaml:"summarizing_model"`
-	SummarizingPrompt string `yaml:"summarizing_prompt"`
+	APIKey            string     `yaml:"api_key"`
+	MaxTokens         int        `yaml:"max_tokens"`
+	RetryCount        int        `yaml:"retry_count"`
+	RetryIntervalSec  int        `yaml:"retry_interval_sec"`
+	ScreeningModel    ModelChain `yaml:"screening_model"`
+	ScreeningPrompt   string     `yaml:"screening_prompt"`
+	SummarizingModel  ModelChain `yaml:"summarizing_model"`
+	SummarizingPrompt string     `yaml:"summarizing_prompt"`
+	// ExtraOutputFields は要約時にGeminiに追加で出力させる項目。投稿テンプレートでは .Extra.<name> で参照できる
+	ExtraOutputFields []ExtraOutputField `yaml:"extra_output_fields"`
+}
+
+// ExtraOutputField は要約の出力に追加する文字列の項目を表す
+type ExtraOutputField struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Enum を指定すると、出力をいずれかの値に制限する
+	Enum []string `yaml:"enum"`
+}
+
+// ModelChain は優先順に並べたモデルの設定を保持する。
+// 先頭のモデルが過負荷やクォータ超過で利用できない場合、次のモデルが使われる
+type ModelChain []ModelConfig
+
+// ModelConfig はモデル名とその生成設定を保持する。未設定の項目はAPIのデフォルト値が使われる
+type ModelConfig struct {
+	Name            string   `yaml:"name"`
+	Temperature     *float32 `yaml:"temperature"`
+	TopP            *float32 `yaml:"top_p"`
+	MaxOutputTokens int32    `yaml:"max_output_tokens"`
+	ThinkingBudget  *int32   `yaml:"thinking_budget"`
+}
+
+// UnmarshalYAML はモデル名の文字列、またはモデル名かモデル設定のリストを読み込む
+func (c *ModelChain) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*c = ModelChain{{Name: name}}
+		return nil
+	}
+
+	var items []modelConfigItem
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	chain := make(ModelChain, 0, len(items))
+	for _, item := range items {
+		if item.Name == "" {
+			return fmt.Errorf("model name is empty")
+		}
+		chain = append(chain, ModelConfig(item))
+	}
+	*c = chain
+	return nil
+}
+
+// modelConfigItem はモデルのリストの要素として、文字列のモデル名も受け付ける
+type modelConfigItem ModelConfig
+
+func (m *modelConfigItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*m = modelConfigItem{Name: name}
+		return nil
+	}
+	var config ModelConfig
+	if err := unmarshal(&config); err != nil {
+		return err
+	}
+	*m = modelConfigItem(config)
+	return nil
 }
 
 type MastodonConfig struct {
-	InstanceURL  string `yaml:"instance_url"`
-	AccessToken  string `yaml:"access_token"`
-	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
-	PostTemplate      string `yaml:"post_template"`
+	// Enabled が false の場合、Mastodonには投稿しない。variants の account モードでは無視される
+	Enabled             bool   `yaml:"enabled"`
+	InstanceURL         string `yaml:"instance_url"`
+	AccessToken         string `yaml:"access_token"`
+	ClientID            string `yaml:"client_id"`
+	ClientSecret        string `yaml:"client_secret"`
+	PostTemplate        string `yaml:"post_template"`
+	NoValuePostTemplate string `yaml:"no_value_post_template"`
+	MaxCharacters       int    `yaml:"max_characters"`
+	// Overflow は投稿が最大文字数を超える場合の扱い
+	Overflow           MastodonOverflowMode `yaml:"overflow"`
+	ThreadMode         bool                 `yaml:"thread_mode"`
+	ThreadPostTemplate string               `yaml:"thread_post_template"`
+	// Visibility は投稿の公開範囲。public, unlisted, private, direct のいずれか
+	Visibility string `yaml:"visibility"`
+	// Language は投稿の言語(ISO 639-1)。空の場合はサーバーの判定に任せる
+	Language string `yaml:"language"`
+	// SpoilerText は投稿の注意書き(CW)。空の場合は付けない
+	SpoilerText string `yaml:"spoiler_text"`
+	// Sensitive が true の場合、添付メディアを閲覧注意にする
+	Sensitive bool          `yaml:"sensitive"`
+	Hashtags  HashtagConfig `yaml:"hashtags"`
+	// Rules はアイテムのタイトルやURLに応じて投稿の設定を上書きするルール。最初に一致したルールを使う
+	Rules []PostRuleConfig `yaml:"rules"`
+}
+
+// HashtagConfig は投稿の末尾に付けるハッシュタグの設定を保持する
+type HashtagConfig struct {
+	// Static は常に付けるハッシュタグ
+	Static []string `yaml:"static"`
+	// FromMeetingName が true の場合、タイトルから取り出した会議名をハッシュタグにする
+	FromMeetingName bool `yaml:"from_meeting_name"`
+	// MeetingNamePattern はタイトルから会議名を取り出す正規表現。最初のキャプチャグループを会議名とする
+	MeetingNamePattern string `yaml:"meeting_name_pattern"`
+	// Keywords は要約に含まれている場合にハッシュタグにするキーワード
+	Keywords []string `yaml:"keywords"`
+}
+
+// PostRuleConfig はフィードやカテゴリごとに投稿の設定を上書きするルールを保持する。
+// 条件を省略した場合はすべてのアイテムに一致する。空の設定項目は上書きしない
+type PostRuleConfig struct {
+	Name string `yaml:"name"`
+	// TitlePattern はアイテムのタイトルに一致する正規表現
+	TitlePattern string `yaml:"title_pattern"`
+	// URLPattern はアイテムのURLに一致する正規表現。URLのパスでカテゴリを、ホストでフィードを区別できる
+	URLPattern  string `yaml:"url_pattern"`
+	Visibility  string `yaml:"visibility"`
+	Language    string `yaml:"language"`
+	SpoilerText string `yaml:"spoiler_text"`
+	Sensitive   *bool  `yaml:"sensitive"`
+	// Hashtags は hashtags.static に加えて付けるハッシュタグ
+	Hashtags []string `yaml:"hashtags"`
+}
+
+// MastodonOverflowMode は投稿が最大文字数を超える場合の扱いを表す
+type MastodonOverflowMode string
+
+const (
+	// MastodonOverflowTruncate は要約を文の区切り(。)で短くして収める
+	MastodonOverflowTruncate MastodonOverflowMode = "truncate"
+	// MastodonOverflowThread は収まらなかった文を投稿へのリプライとして続けて投稿する
+	MastodonOverflowThread MastodonOverflowMode = "thread"
+)
+
+// VariantPostMode は別版の要約の投稿方法を表す
+type VariantPostMode string
+
+const (
+	// VariantPostReply はメインの投稿へのリプライとして投稿する
+	VariantPostReply VariantPostMode = "reply"
+	// VariantPostAccount は別のアカウントから投稿する
+	VariantPostAccount VariantPostMode = "account"
+)
+
+// VariantConfig は英語版ややさしい日本語版など、別版の要約の設定を保持する
+type VariantConfig struct {
+	Name         string          `yaml:"name"`
+	Prompt       string          `yaml:"prompt"`
+	PostTemplate string          `yaml:"post_template"`
+	PostMode     VariantPostMode `yaml:"post_mode"`
+	// PostMode が account の場合に利用するアカウント。テンプレートの設定は無視される
+	Mastodon *MastodonConfig `yaml:"mastodon"`
+}
+
+// PublisherType は投稿先の種類を表す
+type PublisherType string
+
+const (
+	PublisherMisskey PublisherType = "misskey"
+	PublisherBluesky PublisherType = "bluesky"
+	PublisherSlack   PublisherType = "slack"
+	PublisherDiscord PublisherType = "discord"
+	// PublisherWebhook は要約結果をJSONで送信する汎用のWebhook
+	PublisherWebhook PublisherType = "webhook"
+)
+
+// PublisherConfig はMastodon以外の投稿先の設定を保持する。種類ごとに使う項目が異なる
+type PublisherConfig struct {
+	// Name は投稿先を識別する名前。配信状況の記録に使われるため、投稿先ごとに一意にする。省略時は Type と同じ
+	Name string        `yaml:"name"`
+	Type PublisherType `yaml:"type"`
+	// URL はMisskeyのインスタンスURL、BlueskyのPDSのURL、またはWebhookのURL
+	URL string `yaml:"url"`
+	// AccessToken はMisskeyのアクセストークン
+	AccessToken string `yaml:"access_token"`
+	// Identifier と Password はBlueskyのハンドルとアプリパスワード
+	Identifier string `yaml:"identifier"`
+	Password   string `yaml:"password"`
+	// Secret は汎用のWebhookの署名に使う鍵
+	Secret string `yaml:"secret"`
+	// Visibility はMisskeyの公開範囲 (public, home, followers)
+	Visibility string `yaml:"visibility"`
+	// MaxCharacters は1投稿あたりの最大文字数。超える場合は要約を切り詰める。0の場合は種類ごとのデフォルト値
+	MaxCharacters int `yaml:"max_characters"`
+	// PostTemplate と NoValuePostTemplate は省略時に mastodon の設定が使われる
+	PostTemplate        string `yaml:"post_template"`
 	NoValuePostTemplate string `yaml:"no_value_post_template"`
 }
 
 type StorageConfig struct {
-	DownloadDir   string `yaml:"download_dir"`
-	KeepLocalCopy bool   `yaml:"keep_local_copy"`
+	DownloadDir         string   `yaml:"download_dir"`
+	KeepLocalCopy       bool     `yaml:"keep_local_copy"`
+	MaxFileSizeMB       int64    `yaml:"max_file_size_mb"`
+	QuotaMB             int64    `yaml:"quota_mb"`
+	DownloadTimeoutSec  int      `yaml:"download_timeout_sec"`
+	AllowedContentTypes []string `yaml:"allowed_content_types"`
 }
 
 type DatabaseConfig struct {
-	Path                  string `yaml:"path"`
-	MaxDeferredRetryCount int    `yaml:"max_deferred_retry_count"`
+	// Driver はデータベースの種類。sqlite または postgres
+	Driver DatabaseDriver `yaml:"driver"`
+	// Path はSQLiteのデータベースファイルのパス
+	Path string `yaml:"path"`
+	// DSN はPostgreSQLの接続文字列。複数のインスタンスで同じデータベースを共有できる
+	DSN string `yaml:"dsn"`
+	// MaxDeferredRetryCount は先送りしたアイテムを再試行する最大回数。先送りした回数がこの値に達したアイテムは再試行しない。
+	// 0 の場合は先送りしたアイテムを再試行せず、SweepRetryLimitExceeded でも処理済みにしない
+	MaxDeferredRetryCount int `yaml:"max_deferred_retry_count"`
 }
 
 // LoadConfig は指定されたパスから設定ファイルを読み込み、Config構造体にパースします。記述されていない項目はデフォルト値が使われます
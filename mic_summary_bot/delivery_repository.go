@@ -0,0 +1,166 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DeliveryStatus は投稿先ごとの配信状況を表す
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending" // 配信中。配信の途中で終了した場合もこの状態のまま残る
+	DeliverySent    DeliveryStatus = "sent"    // 配信済み
+	DeliveryFailed  DeliveryStatus = "failed"  // 配信失敗。次回の処理で再試行される
+)
+
+// Delivery は deliveries テーブルのレコードを表す構造体
+type Delivery struct {
+	ItemID int
+	// DigestID はダイジェストの配信状況の場合にダイジェストのID。ItemID は0になる。アイテムの配信状況では0
+	DigestID  int
+	Publisher string
+	Kind      PostKind
+	Status    DeliveryStatus
+	Attempts  int
+	LastError string
+	UpdatedAt time.Time
+}
+
+// StartDelivery はアイテムと投稿先の組の配信を開始したことを記録し、試行回数を増やします。
+func (r *ItemRepository) StartDelivery(ctx context.Context, itemID int, publisher string, kind PostKind) error {
+	if err := r.startDelivery(ctx, itemID, 0, publisher, kind); err != nil {
+		return fmt.Errorf("failed to start delivery for item ID %d to %s: %w", itemID, publisher, err)
+	}
+	return nil
+}
+
+// StartDigestDelivery はダイジェストと投稿先の組の配信を開始したことを記録し、試行回数を増やします。
+func (r *ItemRepository) StartDigestDelivery(ctx context.Context, digestID int, publisher string) error {
+	if err := r.startDelivery(ctx, 0, digestID, publisher, PostKindDigest); err != nil {
+		return fmt.Errorf("failed to start delivery for digest ID %d to %s: %w", digestID, publisher, err)
+	}
+	return nil
+}
+
+func (r *ItemRepository) startDelivery(ctx context.Context, itemID int, digestID int, publisher string, kind PostKind) error {
+	upsertSQL := formatQuery(`
+	INSERT INTO deliveries (item_id, digest_id, publisher, kind, status, attempts, last_error, updated_at)
+	VALUES (?, ?, ?, ?, ?, 1, '', ?)
+	ON CONFLICT (item_id, digest_id, publisher, kind) DO UPDATE SET
+		status = excluded.status,
+		attempts = deliveries.attempts + 1,
+		updated_at = excluded.updated_at;
+	`)
+	return withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, upsertSQL, itemID, digestID, publisher, kind, DeliveryPending, time.Now().UTC())
+		return err
+	})
+}
+
+// FinishDelivery は配信の結果を記録します。deliveryErr がnilの場合は配信済み、それ以外は配信失敗として記録します。
+func (r *ItemRepository) FinishDelivery(ctx context.Context, itemID int, publisher string, kind PostKind, deliveryErr error) error {
+	if err := r.finishDelivery(ctx, itemID, 0, publisher, kind, deliveryErr); err != nil {
+		return fmt.Errorf("failed to finish delivery for item ID %d to %s: %w", itemID, publisher, err)
+	}
+	return nil
+}
+
+// FinishDigestDelivery はダイジェストの配信の結果を記録します。
+func (r *ItemRepository) FinishDigestDelivery(ctx context.Context, digestID int, publisher string, deliveryErr error) error {
+	if err := r.finishDelivery(ctx, 0, digestID, publisher, PostKindDigest, deliveryErr); err != nil {
+		return fmt.Errorf("failed to finish delivery for digest ID %d to %s: %w", digestID, publisher, err)
+	}
+	return nil
+}
+
+func (r *ItemRepository) finishDelivery(ctx context.Context, itemID int, digestID int, publisher string, kind PostKind, deliveryErr error) error {
+	status := DeliverySent
+	lastError := ""
+	if deliveryErr != nil {
+		status = DeliveryFailed
+		lastError = deliveryErr.Error()
+	}
+
+	updateSQL := formatQuery(`
+	UPDATE deliveries
+	SET status = ?, last_error = ?, updated_at = ?
+	WHERE item_id = ? AND digest_id = ? AND publisher = ? AND kind = ?;
+	`)
+	return withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, updateSQL, status, lastError, time.Now().UTC(), itemID, digestID, publisher, kind)
+		return err
+	})
+}
+
+// GetDeliveries は指定したアイテムの配信状況を投稿先の名前順に返します。
+func (r *ItemRepository) GetDeliveries(ctx context.Context, itemID int, kind PostKind) ([]*Delivery, error) {
+	deliveries, err := r.getDeliveries(ctx, itemID, 0, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries for item ID %d: %w", itemID, err)
+	}
+	return deliveries, nil
+}
+
+// GetDigestDeliveries は指定したダイジェストの配信状況を投稿先の名前順に返します。
+func (r *ItemRepository) GetDigestDeliveries(ctx context.Context, digestID int) ([]*Delivery, error) {
+	deliveries, err := r.getDeliveries(ctx, 0, digestID, PostKindDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries for digest ID %d: %w", digestID, err)
+	}
+	return deliveries, nil
+}
+
+func (r *ItemRepository) getDeliveries(ctx context.Context, itemID int, digestID int, kind PostKind) ([]*Delivery, error) {
+	query := formatQuery(`
+	SELECT item_id, digest_id, publisher, kind, status, attempts, last_error, updated_at
+	FROM deliveries
+	WHERE item_id = ? AND digest_id = ? AND kind = ?
+	ORDER BY publisher ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, itemID, digestID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		d := &Delivery{}
+		if err := rows.Scan(&d.ItemID, &d.DigestID, &d.Publisher, &d.Kind, &d.Status, &d.Attempts, &d.LastError, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReplaceDeliveries はアイテムの配信状況を指定したものに置き換えます。ドライランの後に元の状態に戻すために使います。
+func (r *ItemRepository) ReplaceDeliveries(ctx context.Context, itemID int, deliveries []*Delivery) error {
+	deleteSQL := formatQuery(`
+	DELETE FROM deliveries
+	WHERE item_id = ?;
+	`)
+	insertSQL := formatQuery(`
+	INSERT INTO deliveries (item_id, digest_id, publisher, kind, status, attempts, last_error, updated_at)
+	VALUES (?, 0, ?, ?, ?, ?, ?, ?);
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, deleteSQL, itemID); err != nil {
+			return err
+		}
+		for _, d := range deliveries {
+			if _, err := tx.ExecContext(ctx, insertSQL, itemID, d.Publisher, d.Kind, d.Status, d.Attempts, d.LastError, d.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace deliveries for item ID %d: %w", itemID, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package micsummarybot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_Deliveries(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, repo.StartDelivery(ctx, 1, "mastodon", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 1, "mastodon", PostKindSummary, nil))
+	require.NoError(t, repo.StartDelivery(ctx, 1, "misskey", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 1, "misskey", PostKindSummary, errors.New("connection refused")))
+	require.NoError(t, repo.StartDelivery(ctx, 1, "misskey", PostKindSummary))
+	require.NoError(t, repo.StartDelivery(ctx, 1, "mastodon", PostKindNoValue))
+
+	deliveries, err := repo.GetDeliveries(ctx, 1, PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+
+	assert.Equal(t, "mastodon", deliveries[0].Publisher)
+	assert.Equal(t, DeliverySent, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+
+	// 再試行を開始すると pending に戻り、試行回数が増える。前回のエラーは残る
+	assert.Equal(t, "misskey", deliveries[1].Publisher)
+	assert.Equal(t, DeliveryPending, deliveries[1].Status)
+	assert.Equal(t, 2, deliveries[1].Attempts)
+	assert.Equal(t, "connection refused", deliveries[1].LastError)
+
+	deliveries, err = repo.GetDeliveries(ctx, 2, PostKindSummary)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestItemRepository_ReplaceDeliveries(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, repo.StartDelivery(ctx, 1, "mastodon", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 1, "mastodon", PostKindSummary, assert.AnError))
+	before, err := repo.GetDeliveries(ctx, 1, PostKindSummary)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.StartDelivery(ctx, 1, "mastodon", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 1, "mastodon", PostKindSummary, nil))
+	require.NoError(t, repo.StartDelivery(ctx, 1, "slack", PostKindSummary))
+
+	require.NoError(t, repo.ReplaceDeliveries(ctx, 1, before))
+	after, err := repo.GetDeliveries(ctx, 1, PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.Equal(t, DeliveryFailed, after[0].Status)
+	assert.Equal(t, 1, after[0].Attempts)
+}
@@ -0,0 +1,107 @@
+package micsummarybot
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DigestInfo is the template data for the digest.
+type DigestInfo struct {
+	// Date is the date of the digest in the schedule timezone, formatted as 2006-01-02.
+	Date string
+	// Summarized lists the items whose summaries were posted.
+	Summarized []DigestEntry
+	// NoValue lists the items deemed not valuable.
+	NoValue []DigestEntry
+}
+
+// DigestEntry is an item in the digest.
+type DigestEntry struct {
+	Title string
+	URL   string
+}
+
+// postItem returns the item passed to the posting functions for the digest.
+// It has no ID because the digest posts are recorded with the digest ID.
+// The URL and the title of the first item are used to find a posted status and in the logs.
+func (d Digest) postItem() Item {
+	var item Item
+	if len(d.Items) > 0 {
+		item.URL = d.Items[0].URL
+		item.Title = d.Items[0].Title
+	}
+	return item
+}
+
+// digestTemplate renders the digest posts.
+type digestTemplate struct {
+	template *template.Template
+	location *time.Location
+}
+
+func newDigestTemplate(config *Config) (*digestTemplate, error) {
+	t, err := parsePostTemplate("digest_post", config.Digest.PostTemplate)
+	if err == nil {
+		err = validatePostTemplate(t, DigestInfo{
+			Date:       "2025-04-01",
+			Summarized: []DigestEntry{{Title: "サンプル会議（第1回）", URL: "https://www.soumu.go.jp/menu_news/s-news/sample.html"}},
+			NoValue:    []DigestEntry{{Title: "サンプル報道発表", URL: "https://www.soumu.go.jp/menu_news/s-news/sample2.html"}},
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest post template: %w", err)
+	}
+	location := time.UTC
+	if config.Schedule.Timezone != "" {
+		location, err = time.LoadLocation(config.Schedule.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone %s: %w", config.Schedule.Timezone, err)
+		}
+	}
+	return &digestTemplate{template: t, location: location}, nil
+}
+
+// render renders the digest into posts for which fits reports true.
+// Items are added to a post in order until the next one does not fit, so that an item is never split across posts.
+// A post with a single item is returned as is even if it does not fit.
+func (t *digestTemplate) render(digest Digest, fits func(string) bool) ([]string, error) {
+	date := digest.CreatedAt.In(t.location).Format("2006-01-02")
+	var posts []string
+	var current []DigestItem
+	var currentText string
+	for _, item := range digest.Items {
+		text, err := t.execute(date, append(current, item))
+		if err != nil {
+			return nil, err
+		}
+		if len(current) > 0 && !fits(text) {
+			posts = append(posts, currentText)
+			current = nil
+			if text, err = t.execute(date, []DigestItem{item}); err != nil {
+				return nil, err
+			}
+		}
+		current = append(current, item)
+		currentText = text
+	}
+	if len(current) > 0 {
+		posts = append(posts, currentText)
+	}
+	return posts, nil
+}
+
+func (t *digestTemplate) execute(date string, items []DigestItem) (string, error) {
+	info := DigestInfo{Date: date}
+	for _, item := range items {
+		entry := DigestEntry{Title: item.Title, URL: item.URL}
+		if item.Kind == PostKindSummary {
+			info.Summarized = append(info.Summarized, entry)
+		} else {
+			info.NoValue = append(info.NoValue, entry)
+		}
+	}
+	text, err := executeTemplate(t.template, info)
+	return strings.TrimSpace(text), err
+}
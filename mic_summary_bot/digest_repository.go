@@ -0,0 +1,163 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Digest は digests テーブルのレコードと、ダイジェストに含まれるアイテムを表す構造体
+type Digest struct {
+	ID        int
+	CreatedAt time.Time
+	// Items はダイジェストに含まれるアイテム。種類ごとに公開日時の古い順に並ぶ
+	Items []DigestItem
+}
+
+// DigestItem はダイジェストに含まれるアイテムと、アイテムを追加した投稿の種類を表す構造体
+type DigestItem struct {
+	Item
+	// Kind は PostKindSummary (要約済み) または PostKindNoValue (要約対象外)
+	Kind PostKind
+}
+
+// AddDigestItem はアイテムを次のダイジェストに追加します。追加済みの場合は何もしません。
+func (r *ItemRepository) AddDigestItem(ctx context.Context, itemID int, kind PostKind) error {
+	insertSQL := formatQuery(`
+	INSERT INTO digest_items (item_id, kind, digest_id, created_at)
+	VALUES (?, ?, NULL, ?)
+	ON CONFLICT (item_id, kind) DO NOTHING;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, insertSQL, itemID, kind, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add item ID %d to digest: %w", itemID, err)
+	}
+	return nil
+}
+
+// OpenDigest は投稿が完了していないダイジェストを返します。
+// 該当するダイジェストがない場合は、まだダイジェストに含まれていないアイテムから新しいダイジェストを作成します。
+// ダイジェストに含めるアイテムがない場合はnilを返します。
+func (r *ItemRepository) OpenDigest(ctx context.Context) (*Digest, error) {
+	var digest *Digest
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		openQuery := formatQuery(`
+		SELECT id, created_at FROM digests
+		WHERE posted_at IS NULL
+		ORDER BY id ASC
+		LIMIT 1;
+		`)
+		d := &Digest{}
+		err := tx.QueryRowContext(ctx, openQuery).Scan(&d.ID, &d.CreatedAt)
+		if err == nil {
+			digest = d
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM digest_items WHERE digest_id IS NULL;").Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+
+		d.CreatedAt = time.Now().UTC()
+		if err := tx.QueryRowContext(ctx, "INSERT INTO digests (created_at) VALUES (?) RETURNING id;", d.CreatedAt).Scan(&d.ID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE digest_items SET digest_id = ? WHERE digest_id IS NULL;", d.ID); err != nil {
+			return err
+		}
+		digest = d
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open digest: %w", err)
+	}
+	if digest == nil {
+		return nil, nil
+	}
+
+	digest.Items, err = r.queryDigestItems(ctx, "digest_items.digest_id = ?", digest.ID)
+	if err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// GetDigest は指定したIDのダイジェストを、含まれるアイテムとともに返します。見つからない場合はnilを返します。
+func (r *ItemRepository) GetDigest(ctx context.Context, digestID int) (*Digest, error) {
+	query := formatQuery(`
+	SELECT id, created_at FROM digests
+	WHERE id = ?;
+	`)
+	digest := &Digest{}
+	err := r.db.QueryRowContext(ctx, query, digestID).Scan(&digest.ID, &digest.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest ID %d: %w", digestID, err)
+	}
+	digest.Items, err = r.queryDigestItems(ctx, "digest_items.digest_id = ?", digest.ID)
+	if err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// GetPendingDigest は、まだダイジェストに含まれていないアイテムから、保存せずにダイジェストを作成して返します。
+// IDは0になります。ダイジェストに含めるアイテムがない場合はnilを返します。
+func (r *ItemRepository) GetPendingDigest(ctx context.Context) (*Digest, error) {
+	items, err := r.queryDigestItems(ctx, "digest_items.digest_id IS NULL")
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &Digest{CreatedAt: time.Now().UTC(), Items: items}, nil
+}
+
+// CompleteDigest はダイジェストの投稿が完了したことを記録します。
+func (r *ItemRepository) CompleteDigest(ctx context.Context, digestID int) error {
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE digests SET posted_at = ? WHERE id = ?;", time.Now().UTC(), digestID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete digest ID %d: %w", digestID, err)
+	}
+	return nil
+}
+
+func (r *ItemRepository) queryDigestItems(ctx context.Context, condition string, args ...any) ([]DigestItem, error) {
+	query := formatQuery(`
+	SELECT items.id, items.url, items.title, items.published_at, items.status, items.reason, items.retry_count, items.created_at, items.last_checked_at, digest_items.kind
+	FROM digest_items
+	JOIN items ON items.id = digest_items.item_id
+	WHERE ` + condition + `
+	ORDER BY digest_items.kind DESC, items.published_at ASC, items.id ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []DigestItem
+	for rows.Next() {
+		var d DigestItem
+		if err := rows.Scan(&d.ID, &d.URL, &d.Title, &d.PublishedAt, &d.Status, &d.Reason, &d.RetryCount, &d.CreatedAt, &d.LastCheckedAt, &d.Kind); err != nil {
+			return nil, fmt.Errorf("failed to scan digest item: %w", err)
+		}
+		items = append(items, d)
+	}
+	return items, rows.Err()
+}
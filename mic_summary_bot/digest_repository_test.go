@@ -0,0 +1,73 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_Digest(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, repo.insert(ctx, &Item{
+			URL:           fmt.Sprintf("https://www.soumu.go.jp/%d.html", i),
+			Title:         fmt.Sprintf("Article %d", i),
+			PublishedAt:   now.Add(-time.Duration(i) * time.Hour),
+			Status:        StatusProcessed,
+			CreatedAt:     now,
+			LastCheckedAt: now,
+		}))
+	}
+
+	digest, err := repo.OpenDigest(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, digest, "no digest without items")
+
+	require.NoError(t, repo.AddDigestItem(ctx, 1, PostKindNoValue))
+	require.NoError(t, repo.AddDigestItem(ctx, 2, PostKindNoValue))
+	require.NoError(t, repo.AddDigestItem(ctx, 3, PostKindSummary))
+	require.NoError(t, repo.AddDigestItem(ctx, 1, PostKindNoValue), "adding an item twice is ignored")
+
+	pending, err := repo.GetPendingDigest(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, pending)
+	assert.Equal(t, 0, pending.ID)
+	assert.Len(t, pending.Items, 3)
+
+	digest, err = repo.OpenDigest(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, digest)
+	require.Len(t, digest.Items, 3)
+	// Summarized items first, then the oldest first
+	assert.Equal(t, []int{3, 2, 1}, []int{digest.Items[0].ID, digest.Items[1].ID, digest.Items[2].ID})
+	assert.Equal(t, PostKindSummary, digest.Items[0].Kind)
+	assert.Equal(t, "Article 2", digest.Items[1].Title)
+
+	// Items added while the digest is being posted go to the next digest
+	require.NoError(t, repo.AddDigestItem(ctx, 2, PostKindSummary))
+	reopened, err := repo.OpenDigest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, digest.ID, reopened.ID)
+	assert.Len(t, reopened.Items, 3)
+
+	require.NoError(t, repo.CompleteDigest(ctx, digest.ID))
+	next, err := repo.OpenDigest(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.NotEqual(t, digest.ID, next.ID)
+	require.Len(t, next.Items, 1)
+	assert.Equal(t, 2, next.Items[0].ID)
+
+	require.NoError(t, repo.CompleteDigest(ctx, next.ID))
+	digest, err = repo.OpenDigest(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, digest)
+}
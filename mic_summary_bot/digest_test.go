@@ -0,0 +1,55 @@
+package micsummarybot
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestTemplate_render(t *testing.T) {
+	config := DefaultConfig()
+	tmpl, err := newDigestTemplate(config)
+	require.NoError(t, err)
+
+	digest := Digest{
+		// 2025-04-01 in Asia/Tokyo
+		CreatedAt: time.Date(2025, 3, 31, 16, 0, 0, 0, time.UTC),
+		Items: []DigestItem{
+			{Item: Item{Title: "会議A", URL: "https://www.soumu.go.jp/a.html"}, Kind: PostKindSummary},
+			{Item: Item{Title: "報道発表B", URL: "https://www.soumu.go.jp/b.html"}, Kind: PostKindNoValue},
+			{Item: Item{Title: "報道発表C", URL: "https://www.soumu.go.jp/c.html"}, Kind: PostKindNoValue},
+		},
+	}
+
+	t.Run("single post", func(t *testing.T) {
+		posts, err := tmpl.render(digest, func(string) bool { return true })
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2025-04-01 の新着情報\n" +
+			"【要約済み】\n・会議A\nhttps://www.soumu.go.jp/a.html\n" +
+			"【要約対象外】\n・報道発表B\nhttps://www.soumu.go.jp/b.html\n・報道発表C\nhttps://www.soumu.go.jp/c.html"}, posts)
+	})
+
+	t.Run("split at item boundaries", func(t *testing.T) {
+		fits := func(text string) bool { return utf8.RuneCountInString(text) <= 120 }
+		posts, err := tmpl.render(digest, fits)
+		require.NoError(t, err)
+		require.Len(t, posts, 2)
+		for _, post := range posts {
+			assert.True(t, fits(post), post)
+			assert.True(t, strings.HasPrefix(post, "2025-04-01 の新着情報\n"), post)
+		}
+		assert.Contains(t, posts[0], "会議A")
+		assert.Contains(t, posts[0], "報道発表B")
+		assert.Equal(t, "2025-04-01 の新着情報\n【要約対象外】\n・報道発表C\nhttps://www.soumu.go.jp/c.html", posts[1])
+	})
+
+	t.Run("item that does not fit alone", func(t *testing.T) {
+		posts, err := tmpl.render(digest, func(string) bool { return false })
+		require.NoError(t, err)
+		assert.Len(t, posts, 3)
+	})
+}
@@ -0,0 +1,362 @@
+package micsummarybot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnexpectedStatus はダウンロード時に2xx以外のステータスコードが返されたことを表します。
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	// ErrUnexpectedContentType はダウンロードしたファイルが許可されていない形式であることを表します。
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+	// ErrFileTooLarge はダウンロードしたファイルが上限サイズを超えたことを表します。
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// DownloadedFile は downloaded_files テーブルのレコードを表す構造体
+type DownloadedFile struct {
+	SHA256      string
+	URL         string
+	Path        string
+	Size        int64
+	ContentType string
+	// ETag と LastModified はダウンロード時のレスポンスヘッダ。次回のダウンロードで変更の有無を確認するのに使う
+	ETag           string
+	LastModified   string
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+}
+
+// DownloadManager は添付資料のダウンロードと、SHA-256をファイル名としたローカルキャッシュを管理します。
+// メタデータはURLごとに記録し、異なるURLから同じ内容のファイルをダウンロードした場合は同じファイルを参照します。
+// キャッシュの合計サイズが上限を超えた場合、最後に参照された日時が古いものから削除します。
+type DownloadManager struct {
+	httpClient          *http.Client
+	repository          DownloadStore
+	dir                 string
+	keepLocalCopy       bool
+	maxFileSize         int64
+	quota               int64
+	allowedContentTypes []string
+}
+
+// NewDownloadManager は新しいDownloadManagerインスタンスを作成します。
+func NewDownloadManager(storage *StorageConfig, repository DownloadStore) (*DownloadManager, error) {
+	if err := os.MkdirAll(storage.DownloadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	return &DownloadManager{
+		httpClient: &http.Client{
+			Timeout: time.Duration(storage.DownloadTimeoutSec) * time.Second,
+		},
+		repository:          repository,
+		dir:                 storage.DownloadDir,
+		keepLocalCopy:       storage.KeepLocalCopy,
+		maxFileSize:         storage.MaxFileSizeMB * 1024 * 1024,
+		quota:               storage.QuotaMB * 1024 * 1024,
+		allowedContentTypes: storage.AllowedContentTypes,
+	}, nil
+}
+
+// isAllowedContentType はContent-Typeが許可された形式か判定します。パラメータ部分は無視します。
+func (m *DownloadManager) isAllowedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range m.allowedContentTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Download は指定されたURLからファイルをダウンロードし、SHA-256をファイル名としてキャッシュに保存します。
+// 同じURLのファイルがキャッシュにある場合は、ETag と Last-Modified による条件付きリクエストで変更を確認し、
+// 変更がなければダウンロードせずにキャッシュを返します。
+// 同じ内容のファイルが既にキャッシュにある場合はそれを再利用し、最終参照日時のみ更新します。
+func (m *DownloadManager) Download(ctx context.Context, url string) (*DownloadedFile, error) {
+	cached, err := m.cachedFile(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.LastAccessedAt = time.Now().UTC()
+		if err := m.repository.SaveDownloadedFile(ctx, cached); err != nil {
+			return nil, err
+		}
+		pkgLogger.Debug("Downloaded file is not modified", "url", url, "sha256", cached.SHA256)
+		return cached, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: %s returned %d", ErrUnexpectedStatus, url, resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !m.isAllowedContentType(contentType) {
+		return nil, fmt.Errorf("%w: %s returned %q", ErrUnexpectedContentType, url, contentType)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if m.maxFileSize > 0 && resp.ContentLength > m.maxFileSize {
+		return nil, fmt.Errorf("%w: %s has Content-Length %d (max %d)", ErrFileTooLarge, url, resp.ContentLength, m.maxFileSize)
+	}
+
+	tmp, err := os.CreateTemp(m.dir, "download-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	// 正常終了時はリネーム済みなので、ここでの削除は失敗しても問題ない
+	defer os.Remove(tmpPath)
+
+	size, sha, head, err := m.copyWithLimit(tmp, resp.Body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", tmpPath, closeErr)
+	}
+
+	// Content-Typeヘッダが正しくても、本文がHTMLのエラーページである場合がある
+	if !sniffContentType(mediaType, head) {
+		return nil, fmt.Errorf("%w: body of %s does not look like %q", ErrUnexpectedContentType, url, mediaType)
+	}
+
+	// Geminiへのアップロード時にMIMEタイプを拡張子から判定するため、拡張子は保持する
+	ext := strings.ToLower(path.Ext(url))
+	if mediaType == "application/pdf" {
+		ext = ".pdf"
+	}
+
+	now := time.Now().UTC()
+	file := &DownloadedFile{
+		SHA256:         sha,
+		URL:            url,
+		Path:           filepath.Join(m.dir, sha+ext),
+		Size:           size,
+		ContentType:    mediaType,
+		ETag:           resp.Header.Get("ETag"),
+		LastModified:   resp.Header.Get("Last-Modified"),
+		CreatedAt:      now,
+		LastAccessedAt: now,
+	}
+
+	if _, err := os.Stat(file.Path); err == nil {
+		pkgLogger.Debug("Downloaded file already exists in cache", "url", url, "sha256", sha)
+	} else if err := os.Rename(tmpPath, file.Path); err != nil {
+		return nil, fmt.Errorf("failed to move downloaded file to %s: %w", file.Path, err)
+	}
+
+	if err := m.repository.SaveDownloadedFile(ctx, file); err != nil {
+		return nil, err
+	}
+
+	if err := m.evict(ctx, file.SHA256); err != nil {
+		pkgLogger.Warn("Failed to evict downloaded files", "error", err)
+	}
+
+	return file, nil
+}
+
+// cachedFile は指定されたURLから以前にダウンロードしたファイルがキャッシュに残っていれば返します。
+// 残っていない場合はnilを返します。
+func (m *DownloadManager) cachedFile(ctx context.Context, url string) (*DownloadedFile, error) {
+	file, err := m.repository.GetDownloadedFileByURL(ctx, url)
+	if err != nil || file == nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file.Path); err != nil {
+		return nil, nil
+	}
+	return file, nil
+}
+
+// GetArchived は指定されたURLから以前にダウンロードしたファイルがキャッシュに残っていればそれを返し、
+// 残っていなければダウンロードします。返したファイルの利用後は Release を呼んでください。
+func (m *DownloadManager) GetArchived(ctx context.Context, url string) (*DownloadedFile, error) {
+	file, err := m.cachedFile(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if file != nil {
+		file.LastAccessedAt = time.Now().UTC()
+		if err := m.repository.SaveDownloadedFile(ctx, file); err != nil {
+			return nil, err
+		}
+		pkgLogger.Debug("Using archived file", "url", url, "sha256", file.SHA256)
+		return file, nil
+	}
+	return m.Download(ctx, url)
+}
+
+// copyWithLimit はsrcをdstに書き込みながらサイズとSHA-256を計算し、形式の判定に使う先頭512バイトを返します。
+// 書き込み量がmaxFileSizeを超えた時点でErrFileTooLargeを返します。
+func (m *DownloadManager) copyWithLimit(dst io.Writer, src io.Reader) (int64, string, []byte, error) {
+	hash := sha256.New()
+	head := &headBuffer{limit: 512}
+	reader := src
+	if m.maxFileSize > 0 {
+		reader = io.LimitReader(src, m.maxFileSize+1)
+	}
+
+	size, err := io.Copy(io.MultiWriter(dst, hash, head), reader)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if m.maxFileSize > 0 && size > m.maxFileSize {
+		return 0, "", nil, fmt.Errorf("%w: exceeded %d bytes", ErrFileTooLarge, m.maxFileSize)
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), head.buf, nil
+}
+
+var (
+	zipSignature = []byte("PK\x03\x04")
+	// oleSignature は .doc, .xls, .ppt などの複合ドキュメント形式のシグネチャ
+	oleSignature = []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
+)
+
+// contentSniffers は http.DetectContentType が判定できない形式について、本文の先頭がその形式に見えるかを判定する関数
+var contentSniffers = map[string]func(head []byte) bool{
+	"application/zip": hasSignature(zipSignature),
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   hasSignature(zipSignature),
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         hasSignature(zipSignature),
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": hasSignature(zipSignature),
+	"application/msword":            hasSignature(oleSignature),
+	"application/vnd.ms-excel":      hasSignature(oleSignature),
+	"application/vnd.ms-powerpoint": hasSignature(oleSignature),
+	"text/csv":                      isPlainText,
+}
+
+func hasSignature(signature []byte) func(head []byte) bool {
+	return func(head []byte) bool {
+		return bytes.HasPrefix(head, signature)
+	}
+}
+
+// isPlainText はHTMLやバイナリではないテキストに見えるかを判定します。
+func isPlainText(head []byte) bool {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return detected == "text/plain"
+}
+
+// sniffContentType は本文の先頭 head が mediaType の形式に見えるかを判定します。
+// contentSniffers にない形式は http.DetectContentType の結果と比べます。
+func sniffContentType(mediaType string, head []byte) bool {
+	if sniff, ok := contentSniffers[mediaType]; ok {
+		return sniff(head)
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return detected == mediaType
+}
+
+// headBuffer は書き込まれたデータの先頭limitバイトのみを保持するio.Writer
+type headBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if rest := h.limit - len(h.buf); rest > 0 {
+		h.buf = append(h.buf, p[:min(rest, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// Release はダウンロードしたファイルの利用が終わったことを通知します。
+// keep_local_copy が false の場合、URLのメタデータを削除し、他のURLが参照していなければファイルも削除します。
+func (m *DownloadManager) Release(ctx context.Context, file *DownloadedFile) error {
+	if m.keepLocalCopy {
+		return nil
+	}
+	remaining, err := m.repository.DeleteDownloadedFile(ctx, file.URL)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		pkgLogger.Debug("Keeping local copy referenced by other URLs", "local_path", file.Path, "urls", remaining)
+		return nil
+	}
+	return removeLocalCopy(file)
+}
+
+func removeLocalCopy(file *DownloadedFile) error {
+	pkgLogger.Debug("Removing local copy", "local_path", file.Path)
+	if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", file.Path, err)
+	}
+	return nil
+}
+
+// evict はキャッシュの合計サイズがquotaを超えている間、最終参照日時が古いファイルから削除します。
+// keepで指定されたファイルは削除しません。
+func (m *DownloadManager) evict(ctx context.Context, keep string) error {
+	if m.quota <= 0 {
+		return nil
+	}
+
+	total, err := m.repository.TotalDownloadedFileSize(ctx)
+	if err != nil {
+		return err
+	}
+	if total <= m.quota {
+		return nil
+	}
+
+	files, err := m.repository.ListDownloadedFilesByLastAccess(ctx)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if total <= m.quota {
+			break
+		}
+		if file.SHA256 == keep {
+			continue
+		}
+		pkgLogger.Info("Evicting downloaded file", "sha256", file.SHA256, "url", file.URL, "size", file.Size)
+		if err := removeLocalCopy(file); err != nil {
+			return err
+		}
+		if err := m.repository.DeleteDownloadedContent(ctx, file.SHA256); err != nil {
+			return err
+		}
+		total -= file.Size
+	}
+	return nil
+}
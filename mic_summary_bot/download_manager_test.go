@@ -0,0 +1,233 @@
+package micsummarybot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDownloadManager creates a DownloadManager that stores files in a temporary directory.
+func newTestDownloadManager(t *testing.T, maxFileSizeMB, quotaMB int64, keepLocalCopy bool) (*DownloadManager, *ItemRepository) {
+	t.Helper()
+	repo, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	m, err := NewDownloadManager(&StorageConfig{
+		DownloadDir:         t.TempDir(),
+		KeepLocalCopy:       keepLocalCopy,
+		MaxFileSizeMB:       maxFileSizeMB,
+		QuotaMB:             quotaMB,
+		DownloadTimeoutSec:  10,
+		AllowedContentTypes: []string{"application/pdf"},
+	}, repo)
+	require.NoError(t, err)
+	return m, repo
+}
+
+// fakePDF returns a PDF-like body of the given size.
+func fakePDF(size int) []byte {
+	body := []byte("%PDF-1.7\n")
+	return append(body, []byte(strings.Repeat("x", size-len(body)))...)
+}
+
+func newDocumentServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(fakePDF(600 * 1024))
+	})
+	mux.HandleFunc("/b.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(fakePDF(700 * 1024))
+	})
+	mux.HandleFunc("/same-as-a.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(fakePDF(600 * 1024))
+	})
+	mux.HandleFunc("/large.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		// Flush before writing so that Content-Length is not set and the limit is enforced while streaming.
+		w.(http.Flusher).Flush()
+		w.Write(fakePDF(2 * 1024 * 1024))
+	})
+	mux.HandleFunc("/error-page.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("<!DOCTYPE html><html><body>Not Found</body></html>"))
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/missing.pdf", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadManager_Download(t *testing.T) {
+	server := newDocumentServer(t)
+	ctx := context.Background()
+
+	t.Run("stores file by sha256 and records metadata", func(t *testing.T) {
+		m, repo := newTestDownloadManager(t, 1, 0, true)
+
+		file, err := m.Download(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		assert.Len(t, file.SHA256, 64)
+		assert.Equal(t, filepath.Join(m.dir, file.SHA256+".pdf"), file.Path)
+		assert.Equal(t, int64(600*1024), file.Size)
+		assert.Equal(t, "application/pdf", file.ContentType)
+		_, err = os.Stat(file.Path)
+		assert.NoError(t, err)
+
+		stored, err := repo.GetDownloadedFileByURL(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, file.SHA256, stored.SHA256)
+
+		// Same content from another URL shares the cache entry
+		same, err := m.Download(ctx, server.URL+"/same-as-a.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, file.SHA256, same.SHA256)
+		files, err := repo.ListDownloadedFilesByLastAccess(ctx)
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+		stored, err = repo.GetDownloadedFileByURL(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		require.NotNil(t, stored, "the metadata of each URL is kept")
+		assert.Equal(t, server.URL+"/a.pdf", stored.URL)
+	})
+
+	t.Run("revalidates cached file with conditional request", func(t *testing.T) {
+		m, _ := newTestDownloadManager(t, 1, 0, true)
+		downloads := 0
+		cached := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			downloads++
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(fakePDF(1024))
+		}))
+		defer cached.Close()
+
+		first, err := m.Download(ctx, cached.URL+"/c.pdf")
+		require.NoError(t, err)
+		second, err := m.Download(ctx, cached.URL+"/c.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, 1, downloads, "not modified file is not downloaded again")
+		assert.Equal(t, first.SHA256, second.SHA256)
+		assert.Equal(t, first.Path, second.Path)
+
+		require.NoError(t, os.Remove(first.Path))
+		_, err = m.Download(ctx, cached.URL+"/c.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, 2, downloads, "downloaded again if the cached file is missing")
+	})
+
+	t.Run("rejects non-2xx responses", func(t *testing.T) {
+		m, _ := newTestDownloadManager(t, 1, 0, true)
+		_, err := m.Download(ctx, server.URL+"/missing.pdf")
+		assert.ErrorIs(t, err, ErrUnexpectedStatus)
+	})
+
+	t.Run("rejects wrong content types", func(t *testing.T) {
+		m, _ := newTestDownloadManager(t, 1, 0, true)
+		_, err := m.Download(ctx, server.URL+"/page.html")
+		assert.ErrorIs(t, err, ErrUnexpectedContentType)
+
+		_, err = m.Download(ctx, server.URL+"/error-page.pdf")
+		assert.ErrorIs(t, err, ErrUnexpectedContentType)
+	})
+
+	t.Run("enforces max size while streaming", func(t *testing.T) {
+		m, _ := newTestDownloadManager(t, 1, 0, true)
+		_, err := m.Download(ctx, server.URL+"/large.pdf")
+		assert.ErrorIs(t, err, ErrFileTooLarge)
+
+		entries, err := os.ReadDir(m.dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Partial download should be removed")
+	})
+
+	t.Run("evicts least recently used files over quota", func(t *testing.T) {
+		m, repo := newTestDownloadManager(t, 1, 1, true)
+
+		a, err := m.Download(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		b, err := m.Download(ctx, server.URL+"/b.pdf")
+		require.NoError(t, err)
+
+		_, err = os.Stat(a.Path)
+		assert.True(t, os.IsNotExist(err), "Least recently used file should be evicted")
+		_, err = os.Stat(b.Path)
+		assert.NoError(t, err)
+
+		total, err := repo.TotalDownloadedFileSize(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, b.Size, total)
+	})
+
+	t.Run("release removes file without keep_local_copy", func(t *testing.T) {
+		m, repo := newTestDownloadManager(t, 1, 0, false)
+		file, err := m.Download(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+
+		require.NoError(t, m.Release(ctx, file))
+		_, err = os.Stat(file.Path)
+		assert.True(t, os.IsNotExist(err))
+		stored, err := repo.GetDownloadedFileByURL(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("release keeps file shared with another URL", func(t *testing.T) {
+		m, _ := newTestDownloadManager(t, 1, 0, false)
+		file, err := m.Download(ctx, server.URL+"/a.pdf")
+		require.NoError(t, err)
+		same, err := m.Download(ctx, server.URL+"/same-as-a.pdf")
+		require.NoError(t, err)
+
+		require.NoError(t, m.Release(ctx, file))
+		_, err = os.Stat(same.Path)
+		assert.NoError(t, err)
+		require.NoError(t, m.Release(ctx, same))
+		_, err = os.Stat(same.Path)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestSniffContentType(t *testing.T) {
+	html := []byte("<!DOCTYPE html><html><body>Not Found</body></html>")
+	tests := []struct {
+		mediaType string
+		head      []byte
+		want      bool
+	}{
+		{"application/pdf", fakePDF(100), true},
+		{"application/pdf", html, false},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []byte("PK\x03\x04\x14\x00\x06\x00"), true},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", html, false},
+		{"application/vnd.ms-excel", []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00\x00"), true},
+		{"application/msword", fakePDF(100), false},
+		{"text/csv", []byte("会議名,開催日\n総会,2025-06-01\n"), true},
+		{"text/csv", html, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sniffContentType(tt.mediaType, tt.head), "%s: %q", tt.mediaType, tt.head)
+	}
+}
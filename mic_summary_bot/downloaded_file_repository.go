@@ -0,0 +1,132 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// SaveDownloadedFile はURLからダウンロードしたファイルのメタデータを保存します。
+// 同じURLのレコードが既に存在する場合、ファイルの内容(SHA-256)と検証用のヘッダ、最終参照日時を更新します。
+// 異なるURLから同じ内容のファイルをダウンロードした場合は、それぞれのURLのレコードが同じファイルを参照します。
+func (r *ItemRepository) SaveDownloadedFile(ctx context.Context, file *DownloadedFile) error {
+	upsertSQL := formatQuery(`
+	INSERT INTO downloaded_files (url, sha256, path, size, content_type, etag, last_modified, created_at, last_accessed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(url) DO UPDATE SET sha256 = excluded.sha256, path = excluded.path, size = excluded.size, content_type = excluded.content_type, etag = excluded.etag, last_modified = excluded.last_modified, last_accessed_at = excluded.last_accessed_at;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, upsertSQL, file.URL, file.SHA256, file.Path, file.Size, file.ContentType, file.ETag, file.LastModified, file.CreatedAt, file.LastAccessedAt)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save downloaded file %s: %w", file.URL, err)
+	}
+	return nil
+}
+
+// GetDownloadedFileByURL は指定されたURLからダウンロードしたファイルのメタデータを取得します。
+// 見つからない場合はnilを返します。
+func (r *ItemRepository) GetDownloadedFileByURL(ctx context.Context, url string) (*DownloadedFile, error) {
+	query := formatQuery(`
+	SELECT sha256, url, path, size, content_type, etag, last_modified, created_at, last_accessed_at
+	FROM downloaded_files
+	WHERE url = ?;
+	`)
+
+	var file DownloadedFile
+	err := r.db.QueryRowContext(ctx, query, url).Scan(&file.SHA256, &file.URL, &file.Path, &file.Size, &file.ContentType, &file.ETag, &file.LastModified, &file.CreatedAt, &file.LastAccessedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get downloaded file by URL %s: %w", url, err)
+	}
+	return &file, nil
+}
+
+// ListDownloadedFilesByLastAccess はダウンロード済みファイルを、内容(SHA-256)ごとに最終参照日時が古い順に返します。
+// 複数のURLが同じ内容を参照している場合は、最後に参照されたURLのレコードだけを返します。
+func (r *ItemRepository) ListDownloadedFilesByLastAccess(ctx context.Context) ([]*DownloadedFile, error) {
+	query := formatQuery(`
+	SELECT sha256, url, path, size, content_type, etag, last_modified, created_at, last_accessed_at
+	FROM downloaded_files
+	ORDER BY last_accessed_at DESC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downloaded files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*DownloadedFile
+	seen := make(map[string]bool)
+	for rows.Next() {
+		file := &DownloadedFile{}
+		if err := rows.Scan(&file.SHA256, &file.URL, &file.Path, &file.Size, &file.ContentType, &file.ETag, &file.LastModified, &file.CreatedAt, &file.LastAccessedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan downloaded file: %w", err)
+		}
+		if seen[file.SHA256] {
+			continue
+		}
+		seen[file.SHA256] = true
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(files)
+	return files, nil
+}
+
+// TotalDownloadedFileSize はダウンロード済みファイルの合計サイズをバイト単位で返します。
+// 複数のURLが参照している同じ内容のファイルは1回だけ数えます。
+func (r *ItemRepository) TotalDownloadedFileSize(ctx context.Context) (int64, error) {
+	query := `SELECT COALESCE(SUM(size), 0) FROM (SELECT sha256, MAX(size) AS size FROM downloaded_files GROUP BY sha256) AS files;`
+	var total int64
+	if err := r.db.QueryRowContext(ctx, query).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to sum downloaded file size: %w", err)
+	}
+	return total, nil
+}
+
+// DeleteDownloadedFile は指定されたURLのメタデータを削除し、同じ内容のファイルを参照している残りのURLの数を返します。
+func (r *ItemRepository) DeleteDownloadedFile(ctx context.Context, url string) (int, error) {
+	selectSQL := `SELECT sha256 FROM downloaded_files WHERE url = ?;`
+	deleteSQL := `DELETE FROM downloaded_files WHERE url = ?;`
+	countSQL := `SELECT COUNT(*) FROM downloaded_files WHERE sha256 = ?;`
+	var remaining int
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		var sha string
+		err := tx.QueryRowContext(ctx, selectSQL, url).Scan(&sha)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, deleteSQL, url); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, countSQL, sha).Scan(&remaining)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete downloaded file %s: %w", url, err)
+	}
+	return remaining, nil
+}
+
+// DeleteDownloadedContent は指定されたSHA-256の内容を参照しているすべてのURLのメタデータを削除します。
+func (r *ItemRepository) DeleteDownloadedContent(ctx context.Context, sha256 string) error {
+	deleteSQL := `DELETE FROM downloaded_files WHERE sha256 = ?;`
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, deleteSQL, sha256)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete downloaded content %s: %w", sha256, err)
+	}
+	return nil
+}
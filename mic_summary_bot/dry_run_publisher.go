@@ -0,0 +1,129 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// postRenderer is implemented by publishers that can render their posts without sending them.
+type postRenderer interface {
+	// renderSummaryPosts returns the posts for the summary in the order they are posted, including replies.
+	renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error)
+	// renderNoValuePosts returns the posts for an item deemed not valuable.
+	renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error)
+}
+
+// digestRenderer is implemented by publishers that can render a digest without sending it.
+type digestRenderer interface {
+	renderDigestPosts(digest Digest) ([]string, error)
+}
+
+// DryRunPublisher writes the posts rendered by a publisher instead of sending them.
+// The posts are written to stdout, or to a file per item, publisher and kind if the output directory is set.
+type DryRunPublisher struct {
+	name      string
+	renderer  postRenderer
+	outputDir string
+	stdout    io.Writer
+}
+
+// dryRunStdoutMu serializes the output of the dry-run publishers to stdout.
+var dryRunStdoutMu sync.Mutex
+
+// newDryRunPublisher wraps the publisher. The returned publisher implements DigestPublisher
+// only if the wrapped publisher does.
+func newDryRunPublisher(publisher Publisher, config *DryRunConfig) (Publisher, error) {
+	renderer, ok := publisher.(postRenderer)
+	if !ok {
+		return nil, fmt.Errorf("publisher %s does not support dry run", publisher.Name())
+	}
+	p := &DryRunPublisher{
+		name:      publisher.Name(),
+		renderer:  renderer,
+		outputDir: config.OutputDir,
+		stdout:    os.Stdout,
+	}
+	if digest, ok := publisher.(digestRenderer); ok {
+		if _, ok := publisher.(DigestPublisher); ok {
+			return &dryRunDigestPublisher{DryRunPublisher: p, digest: digest}, nil
+		}
+	}
+	return p, nil
+}
+
+func (p *DryRunPublisher) Name() string {
+	return p.name
+}
+
+// PostSummary writes the rendered summary posts.
+func (p *DryRunPublisher) PostSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	posts, err := p.renderer.renderSummaryPosts(item, summary)
+	if err != nil {
+		return err
+	}
+	return p.write(item, PostKindSummary, posts)
+}
+
+// PostNoValue writes the rendered message for an item deemed not valuable.
+func (p *DryRunPublisher) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	posts, err := p.renderer.renderNoValuePosts(item, screening)
+	if err != nil {
+		return err
+	}
+	return p.write(item, PostKindNoValue, posts)
+}
+
+// PostFallback writes the text for an item given up without a summary.
+func (p *DryRunPublisher) PostFallback(ctx context.Context, item Item, text string) error {
+	return p.write(item, PostKindFallback, []string{text})
+}
+
+// dryRunDigestPublisher is a DryRunPublisher for a publisher that supports digests.
+type dryRunDigestPublisher struct {
+	*DryRunPublisher
+	digest digestRenderer
+}
+
+// PostDigest writes the rendered digest. The output is named after the first item of the digest.
+func (p *dryRunDigestPublisher) PostDigest(ctx context.Context, digest Digest) error {
+	if len(digest.Items) == 0 {
+		return nil
+	}
+	posts, err := p.digest.renderDigestPosts(digest)
+	if err != nil {
+		return err
+	}
+	return p.write(digest.Items[0].Item, PostKindDigest, posts)
+}
+
+func (p *DryRunPublisher) write(item Item, kind PostKind, posts []string) error {
+	var buf strings.Builder
+	for i, post := range posts {
+		fmt.Fprintf(&buf, "----- [%d/%d] -----\n%s", i+1, len(posts), post)
+		if !strings.HasSuffix(post, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+
+	if p.outputDir == "" {
+		dryRunStdoutMu.Lock()
+		defer dryRunStdoutMu.Unlock()
+		_, err := fmt.Fprintf(p.stdout, "===== %s %s item %d %s =====\n%s", p.name, kind, item.ID, item.URL, buf.String())
+		return err
+	}
+
+	if err := os.MkdirAll(p.outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create dry run output directory: %w", err)
+	}
+	path := filepath.Join(p.outputDir, fmt.Sprintf("%d_%s_%s.txt", item.ID, p.name, kind))
+	if err := os.WriteFile(path, []byte(buf.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write dry run output: %w", err)
+	}
+	pkgLogger.Info("Wrote dry run output", "publisher", p.name, "path", path)
+	return nil
+}
@@ -0,0 +1,64 @@
+package micsummarybot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunPublisher(t *testing.T) {
+	config := DefaultConfig()
+	config.Mastodon.MaxCharacters = 500
+	config.Mastodon.ThreadMode = true
+	config.DryRun.Enabled = true
+	config.Publishers = []PublisherConfig{{Type: PublisherWebhook, URL: "http://127.0.0.1:1/never-called"}}
+	mastodonClient, err := NewMastodonClient(config, nil)
+	require.NoError(t, err)
+	publishers, err := NewPublishers(config, nil, mastodonClient)
+	require.NoError(t, err)
+	require.Len(t, publishers, 2)
+
+	summary := SummarizeResult{
+		FinalSummary: "要約。",
+		Documents:    []DocumentSummary{{URL: "https://www.soumu.go.jp/main_content/1.pdf", Label: "資料1", KeyPoints: []string{"要点"}}},
+	}
+
+	t.Run("stdout", func(t *testing.T) {
+		var out strings.Builder
+		p := publishers[0].(*dryRunDigestPublisher)
+		p.stdout = &out
+		require.NoError(t, p.PostSummary(context.Background(), publisherTestItem, summary))
+		assert.Equal(t, "===== mastodon summary item 1 "+publisherTestItem.URL+" =====\n"+
+			"----- [1/2] -----\nテスト会議\n要約。\n"+publisherTestItem.URL+"\n"+
+			"----- [2/2] -----\n[1/1] 資料1\n・要点\nhttps://www.soumu.go.jp/main_content/1.pdf\n", out.String())
+
+		out.Reset()
+		digest := Digest{
+			CreatedAt: time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC),
+			Items:     []DigestItem{{Item: publisherTestItem, Kind: PostKindNoValue}},
+		}
+		require.NoError(t, p.PostDigest(context.Background(), digest))
+		assert.Equal(t, "===== mastodon digest item 1 "+publisherTestItem.URL+" =====\n"+
+			"----- [1/1] -----\n2025-04-01 の新着情報\n【要約対象外】\n・テスト会議\n"+publisherTestItem.URL+"\n", out.String())
+	})
+
+	t.Run("output directory", func(t *testing.T) {
+		dir := t.TempDir()
+		p := publishers[1].(*DryRunPublisher)
+		_, ok := publishers[1].(DigestPublisher)
+		assert.False(t, ok)
+		p.outputDir = dir
+		require.NoError(t, p.PostNoValue(context.Background(), publisherTestItem, nil))
+
+		data, err := os.ReadFile(filepath.Join(dir, "1_webhook_no_value.txt"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"event": "no_value"`)
+		assert.Contains(t, string(data), "【要約対象外】")
+	})
+}
@@ -2,6 +2,11 @@ package micsummarybot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"google.golang.org/genai"
 )
@@ -10,14 +15,23 @@ type GenAIClient struct {
 	Client           *genai.Client
 	MaxRetry         int
 	RetryIntervalSec int
-	ScreeningModel   string
-	SummarizingModel string
-	DownloadDir      string
-	KeepLocalCopy    bool
+	ScreeningModel   ModelChain
+	SummarizingModel ModelChain
+	Downloader       *DownloadManager
+	// ExtraOutputFields は要約のスキーマに追加する出力項目
+	ExtraOutputFields []ExtraOutputField
 }
 
 // NewGenAIClient は新しいGenAIClientインスタンスを作成します。
-func NewGenAIClient(gemini *GeminiConfig, storage *StorageConfig) (*GenAIClient, error) {
+func NewGenAIClient(gemini *GeminiConfig, downloader *DownloadManager) (*GenAIClient, error) {
+	names := make(map[string]bool)
+	for _, field := range gemini.ExtraOutputFields {
+		if field.Name == "" || names[field.Name] {
+			return nil, fmt.Errorf("extra output field name must be unique and non-empty: %q", field.Name)
+		}
+		names[field.Name] = true
+	}
+
 	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
 		APIKey: gemini.APIKey,
 	})
@@ -26,12 +40,90 @@ func NewGenAIClient(gemini *GeminiConfig, storage *StorageConfig) (*GenAIClient,
 	}
 
 	return &GenAIClient{
-		Client:           client,
-		MaxRetry:         gemini.RetryCount,
-		RetryIntervalSec: gemini.RetryIntervalSec,
-		ScreeningModel:   gemini.ScreeningModel,
-		SummarizingModel: gemini.SummarizingModel,
-		DownloadDir:      storage.DownloadDir,
-		KeepLocalCopy:    storage.KeepLocalCopy,
+		Client:            client,
+		MaxRetry:          gemini.RetryCount,
+		RetryIntervalSec:  gemini.RetryIntervalSec,
+		ScreeningModel:    gemini.ScreeningModel,
+		SummarizingModel:  gemini.SummarizingModel,
+		Downloader:        downloader,
+		ExtraOutputFields: gemini.ExtraOutputFields,
 	}, nil
 }
+
+// generateContent はモデルのリストを先頭から順に使ってコンテンツを生成し、レスポンスと生成したモデル名を返します。
+// 各モデルはリトライ回数まで再試行しますが、過負荷やクォータ超過のエラーの場合はすぐに次のモデルに切り替えます。
+// 最後のモデルでは、これらのエラーも通常どおり再試行します。最後の試行に失敗した後は待たずにエラーを返します。
+func (client *GenAIClient) generateContent(ctx context.Context, chain ModelChain, contents []*genai.Content, baseConfig *genai.GenerateContentConfig) (*genai.GenerateContentResponse, string, error) {
+	if len(chain) == 0 {
+		return nil, "", fmt.Errorf("no model is configured")
+	}
+
+	var err error
+	// attempts は試したモデルごとの試行回数で、すべて失敗したときのエラーに含める
+	var attempts []string
+	for m, model := range chain {
+		modelConfig := model.apply(baseConfig)
+		hasNext := m < len(chain)-1
+		tries := 0
+		for i := 0; i < client.MaxRetry+1; i++ {
+			pkgLogger.Debug("Calling Gemini API", "attempt", i+1, "model", model.Name)
+			var resp *genai.GenerateContentResponse
+			resp, err = client.Client.Models.GenerateContent(ctx, model.Name, contents, modelConfig)
+			if err == nil {
+				pkgLogger.Debug("Gemini API call succeeded", "attempt", i+1, "model", model.Name)
+				return resp, model.Name, nil
+			}
+			tries = i + 1
+			if hasNext && isModelUnavailable(err) {
+				pkgLogger.Warn("Model is unavailable, falling back to next model", "model", model.Name, "next_model", chain[m+1].Name, "error", err)
+				break
+			}
+			if i == client.MaxRetry {
+				pkgLogger.Warn("Gemini API call failed", "model", model.Name, "attempt", i+1, "max_retry", client.MaxRetry+1, "error", err)
+				break
+			}
+			pkgLogger.Warn("Gemini API call failed", "model", model.Name, "attempt", i+1, "max_retry", client.MaxRetry+1, "error", err, "retrying_in_seconds", client.RetryIntervalSec)
+			time.Sleep(time.Duration(client.RetryIntervalSec) * time.Second)
+		}
+		attempts = append(attempts, fmt.Sprintf("%s x%d", model.Name, tries))
+		if !isModelUnavailable(err) {
+			break
+		}
+	}
+	return nil, "", fmt.Errorf("failed to get response from Gemini API (attempts: %s): %w", strings.Join(attempts, ", "), err)
+}
+
+// isModelUnavailable はエラーがモデルの過負荷やクォータ超過によるものか判定します。
+func isModelUnavailable(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	switch apiErr.Status {
+	case "RESOURCE_EXHAUSTED", "UNAVAILABLE":
+		return true
+	}
+	return false
+}
+
+// apply は生成設定のコピーにモデルごとの設定を反映して返します。
+func (m ModelConfig) apply(base *genai.GenerateContentConfig) *genai.GenerateContentConfig {
+	config := *base
+	if m.Temperature != nil {
+		config.Temperature = m.Temperature
+	}
+	if m.TopP != nil {
+		config.TopP = m.TopP
+	}
+	if m.MaxOutputTokens > 0 {
+		config.MaxOutputTokens = m.MaxOutputTokens
+	}
+	if m.ThinkingBudget != nil {
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: m.ThinkingBudget}
+	}
+	return &config
+}
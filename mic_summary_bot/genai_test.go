@@ -0,0 +1,164 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+	"gopkg.in/yaml.v2"
+)
+
+func TestModelChain_UnmarshalYAML(t *testing.T) {
+	t.Run("single model name", func(t *testing.T) {
+		var config GeminiConfig
+		require.NoError(t, yaml.UnmarshalStrict([]byte(`summarizing_model: "gemini-2.5-pro"`), &config))
+		assert.Equal(t, ModelChain{{Name: "gemini-2.5-pro"}}, config.SummarizingModel)
+	})
+
+	t.Run("list of models with settings", func(t *testing.T) {
+		var config GeminiConfig
+		input := `
+summarizing_model:
+  - name: "gemini-2.5-pro"
+    temperature: 0.5
+  - "gemini-2.5-flash"
+  - name: "gemini-2.0-flash"
+    max_output_tokens: 8192
+`
+		require.NoError(t, yaml.UnmarshalStrict([]byte(input), &config))
+		require.Len(t, config.SummarizingModel, 3)
+		assert.Equal(t, "gemini-2.5-pro", config.SummarizingModel[0].Name)
+		require.NotNil(t, config.SummarizingModel[0].Temperature)
+		assert.Equal(t, float32(0.5), *config.SummarizingModel[0].Temperature)
+		assert.Equal(t, ModelConfig{Name: "gemini-2.5-flash"}, config.SummarizingModel[1])
+		assert.Equal(t, int32(8192), config.SummarizingModel[2].MaxOutputTokens)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		var config GeminiConfig
+		err := yaml.UnmarshalStrict([]byte("summarizing_model:\n  - name: a\n    temprature: 0\n"), &config)
+		assert.Error(t, err)
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		var config GeminiConfig
+		err := yaml.UnmarshalStrict([]byte("summarizing_model:\n  - temperature: 0\n"), &config)
+		assert.Error(t, err)
+	})
+}
+
+func TestGenAIClient_generateContent(t *testing.T) {
+	var mu sync.Mutex
+	var calledModels []string
+	errorByModel := map[string]genai.APIError{
+		"overloaded": {Code: http.StatusServiceUnavailable, Message: "The model is overloaded.", Status: "UNAVAILABLE"},
+		"exhausted":  {Code: http.StatusTooManyRequests, Message: "Quota exceeded.", Status: "RESOURCE_EXHAUSTED"},
+		"invalid":    {Code: http.StatusBadRequest, Message: "Invalid argument.", Status: "INVALID_ARGUMENT"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// パスは /v1beta/models/<model>:generateContent
+		model := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ":generateContent")
+		mu.Lock()
+		calledModels = append(calledModels, model)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if apiErr, ok := errorByModel[model]; ok {
+			w.WriteHeader(apiErr.Code)
+			json.NewEncoder(w).Encode(map[string]genai.APIError{"error": apiErr})
+			return
+		}
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "ok"}]}}]}`))
+	}))
+	defer server.Close()
+
+	genaiClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	require.NoError(t, err)
+	client := &GenAIClient{Client: genaiClient, MaxRetry: 1}
+	contents := []*genai.Content{genai.NewContentFromText("test", genai.RoleUser)}
+
+	t.Run("falls back on overload and quota errors", func(t *testing.T) {
+		calledModels = nil
+		chain := ModelChain{{Name: "overloaded"}, {Name: "exhausted"}, {Name: "available"}}
+		resp, model, err := client.generateContent(context.Background(), chain, contents, &genai.GenerateContentConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp.Text())
+		assert.Equal(t, "available", model)
+		assert.Equal(t, []string{"overloaded", "exhausted", "available"}, calledModels)
+	})
+
+	t.Run("does not fall back on other errors", func(t *testing.T) {
+		calledModels = nil
+		chain := ModelChain{{Name: "invalid"}, {Name: "available"}}
+		_, _, err := client.generateContent(context.Background(), chain, contents, &genai.GenerateContentConfig{})
+		require.Error(t, err)
+		assert.Equal(t, []string{"invalid", "invalid"}, calledModels)
+		assert.Contains(t, err.Error(), "attempts: invalid x2)")
+	})
+
+	t.Run("retries the last model", func(t *testing.T) {
+		calledModels = nil
+		chain := ModelChain{{Name: "overloaded"}}
+		_, _, err := client.generateContent(context.Background(), chain, contents, &genai.GenerateContentConfig{})
+		require.Error(t, err)
+		assert.Equal(t, []string{"overloaded", "overloaded"}, calledModels)
+	})
+
+	t.Run("reports the attempts of each model", func(t *testing.T) {
+		calledModels = nil
+		chain := ModelChain{{Name: "exhausted"}, {Name: "overloaded"}}
+		_, _, err := client.generateContent(context.Background(), chain, contents, &genai.GenerateContentConfig{})
+		require.Error(t, err)
+		assert.Equal(t, []string{"exhausted", "overloaded", "overloaded"}, calledModels)
+		assert.Contains(t, err.Error(), "attempts: exhausted x1, overloaded x2)")
+	})
+
+	t.Run("does not wait after the last attempt", func(t *testing.T) {
+		client := &GenAIClient{Client: genaiClient, MaxRetry: 0, RetryIntervalSec: 10}
+		start := time.Now()
+		_, _, err := client.generateContent(context.Background(), ModelChain{{Name: "invalid"}}, contents, &genai.GenerateContentConfig{})
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), time.Duration(client.RetryIntervalSec)*time.Second)
+	})
+}
+
+func TestGenAIClient_GenerateVariants(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		text := `{\"summary\": \"English summary\"}`
+		if strings.Contains(string(body), "broken") {
+			text = "not json"
+		}
+		fmt.Fprintf(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "%s"}]}}]}`, text)
+	}))
+	defer server.Close()
+
+	genaiClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	require.NoError(t, err)
+	client := &GenAIClient{Client: genaiClient, SummarizingModel: ModelChain{{Name: "model"}}}
+
+	variants, err := client.GenerateVariants(SummarizeResult{FinalSummary: "要約"}, []VariantConfig{
+		{Name: "broken", Prompt: "broken"},
+		{Name: "english", Prompt: "Translate into English."},
+	})
+	assert.ErrorContains(t, err, "variant broken")
+	assert.Equal(t, []SummaryVariant{{Name: "english", Summary: "English summary"}}, variants, "successful variants are kept")
+}
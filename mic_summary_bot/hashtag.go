@@ -0,0 +1,56 @@
+package micsummarybot
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeHashtag は文字列をMastodonのハッシュタグとして有効な形式にします。
+// NFKC正規化で全角英数字を半角にし、文字・数字・アンダースコア以外を取り除きます。
+// 数字のみになった場合などハッシュタグにできない場合は空文字列を返します。
+func normalizeHashtag(s string) string {
+	s = norm.NFKC.String(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+	var b strings.Builder
+	hasLetter := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			hasLetter = true
+			b.WriteRune(r)
+		case unicode.IsNumber(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+	return b.String()
+}
+
+// meetingName はタイトルから会議名を取り出します。パターンに一致しない場合は空文字列を返します。
+func meetingName(title string, pattern *regexp.Regexp) string {
+	m := pattern.FindStringSubmatch(title)
+	if len(m) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(m[1])
+}
+
+// hashtagLine はハッシュタグを正規化し、重複を除いて "#a #b" の形式で返します。
+// 重複は大文字小文字を区別せずに判定します。
+func hashtagLine(tags []string) string {
+	seen := make(map[string]bool)
+	var line []string
+	for _, tag := range tags {
+		tag = normalizeHashtag(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		line = append(line, "#"+tag)
+	}
+	return strings.Join(line, " ")
+}
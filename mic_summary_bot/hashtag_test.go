@@ -0,0 +1,40 @@
+package micsummarybot
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeHashtag(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "総務省", expected: "総務省"},
+		{input: "#総務省", expected: "総務省"},
+		{input: "情報通信審議会 電波政策部会", expected: "情報通信審議会電波政策部会"},
+		{input: "Ｂｅｙｏｎｄ５Ｇ", expected: "Beyond5G"},
+		{input: "デジタル・ガバメント", expected: "デジタルガバメント"},
+		{input: "2025", expected: ""},
+		{input: "！？", expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, normalizeHashtag(tc.input))
+		})
+	}
+}
+
+func TestMeetingName(t *testing.T) {
+	pattern := regexp.MustCompile(DefaultConfig().Mastodon.Hashtags.MeetingNamePattern)
+	assert.Equal(t, "情報通信審議会 電波政策部会", meetingName("情報通信審議会 電波政策部会（第12回）の開催について", pattern))
+	assert.Equal(t, "電波監理審議会", meetingName("電波監理審議会の開催", pattern))
+	assert.Equal(t, "", meetingName("報道資料の公表", pattern))
+}
+
+func TestHashtagLine(t *testing.T) {
+	assert.Equal(t, "#総務省 #Beyond5G", hashtagLine([]string{"総務省", "Beyond5G", "beyond5g", "#総務省", "123"}))
+	assert.Equal(t, "", hashtagLine(nil))
+}
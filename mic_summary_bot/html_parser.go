@@ -119,7 +119,7 @@ func parseHTMLForDocuments(htmlContent string, baseURL *url.URL, sizeFetcher fun
 							pkgLogger.Warn("Could not get size", "url", resolvedURL, "error", err)
 							size = 0 // エラー時はサイズを0とする
 						}
-						documents = append(documents, Document{URL: resolvedURL, Size: size})
+						documents = append(documents, Document{URL: resolvedURL, Label: nodeText(n), Size: size})
 					}
 					break
 				}
@@ -134,6 +134,22 @@ func parseHTMLForDocuments(htmlContent string, baseURL *url.URL, sizeFetcher fun
 	return documents, nil
 }
 
+// nodeText はノード以下のテキストを連結し、前後の空白を取り除いて返します。
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.TrimSpace(sb.String())
+}
+
 // resolveURL は相対URLを絶対URLに解決します。
 func resolveURL(baseURL *url.URL, relativePath string) string {
 	rel, err := url.Parse(relativePath)
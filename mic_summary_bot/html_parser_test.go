@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/japanese"
 )
 
 // dummySizeFetcher はテスト用に常に固定のサイズを返すモック関数です。
@@ -70,3 +71,19 @@ func TestParseHTMLForDocuments_WithNonPDF(t *testing.T) {
 		assert.Equal(t, int64(12345), doc.Size) // dummySizeFetcherが返すサイズ
 	}
 }
+
+func TestParseHTMLForDocuments_Label(t *testing.T) {
+	htmlFilePath := filepath.Join("..", "resources", "example_with_non_pdf.htm")
+	htmlBytes, err := os.ReadFile(htmlFilePath)
+	assert.NoError(t, err)
+	htmlContent, err := japanese.ShiftJIS.NewDecoder().Bytes(htmlBytes)
+	assert.NoError(t, err)
+
+	baseURL, err := url.Parse("https://www.soumu.go.jp/menu_news/s-news/01kiban04_02000258.html")
+	assert.NoError(t, err)
+
+	documents, err := parseHTMLForDocuments(string(htmlContent), baseURL, dummySizeFetcher)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1, "Should find 1 PDF document")
+	assert.Equal(t, "ワット・ビット連携官民懇談会取りまとめ1.0", documents[0].Label)
+}
@@ -0,0 +1,105 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrInvalidTransition は itemTransitions で許可されていないステータスの遷移をしようとした場合のエラー
+var ErrInvalidTransition = errors.New("invalid item status transition")
+
+// ItemStage はアイテムのステータスを更新した処理の段階を表す
+type ItemStage string
+
+const (
+	StageFeed          ItemStage = "feed"          // RSSフィードからの追加
+	StageScreening     ItemStage = "screening"     // スクリーニング
+	StageSummarization ItemStage = "summarization" // 要約
+	StagePosting       ItemStage = "posting"       // 投稿と予約投稿
+	StageAdmin         ItemStage = "admin"         // 管理者のコマンド
+	StageDryRun        ItemStage = "dry_run"       // ドライランの後に元の状態に戻す
+	StageRetryLimit    ItemStage = "retry_limit"   // 先送りした回数が上限に達したアイテムの整理
+)
+
+// itemTransitions は遷移元のステータスごとに、遷移できるステータスを表す。同じステータスへの更新は常に許可する。
+// 処理済みのアイテムを再び処理する場合など、ここにない遷移は ItemTransition.Override を指定した場合だけ行う
+var itemTransitions = map[ItemStatus][]ItemStatus{
+	StatusUnprocessed: {StatusDeferred, StatusPending, StatusProcessed},
+	StatusDeferred:    {StatusPending, StatusProcessed},
+	StatusPending:     {StatusDeferred, StatusProcessed},
+	StatusProcessed:   {},
+}
+
+// ItemTransition はアイテムのステータスを更新する処理の段階と、処理に失敗した場合のエラーを表す
+type ItemTransition struct {
+	Stage ItemStage
+	// Err は処理に失敗してステータスを更新する場合のエラー。イベントにメッセージを記録する
+	Err error
+	// Override が true の場合、itemTransitions で許可されていない遷移も行う。管理者のコマンドで使う
+	Override bool
+	// NextAttemptAt はアイテムを次に処理する時刻。ゼロ値の場合はすぐに処理できる
+	NextAttemptAt time.Time
+}
+
+// ItemEvent は item_events テーブルのレコードを表す構造体
+type ItemEvent struct {
+	ID     int64
+	ItemID int
+	// FromStatus は遷移前のステータス。アイテムを追加したイベントでは ToStatus と同じ
+	FromStatus ItemStatus
+	ToStatus   ItemStatus
+	Reason     ItemReasonCode
+	RetryCount int
+	Stage      ItemStage
+	// Error は処理に失敗した場合のエラーメッセージ。失敗していない場合は空
+	Error     string
+	CreatedAt time.Time
+}
+
+// canTransitionItem はステータスを from から to に遷移できるかを返します。
+func canTransitionItem(from, to ItemStatus) bool {
+	return from == to || slices.Contains(itemTransitions[from], to)
+}
+
+// addItemEvent はアイテムのステータスの遷移を記録します。
+func addItemEvent(ctx context.Context, tx *sql.Tx, event *ItemEvent) error {
+	insertSQL := formatQuery(`
+	INSERT INTO item_events (item_id, from_status, to_status, reason, retry_count, stage, error_message, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+	`)
+	_, err := tx.ExecContext(ctx, insertSQL, event.ItemID, event.FromStatus, event.ToStatus, event.Reason, event.RetryCount, event.Stage, event.Error, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add item event: %w", err)
+	}
+	return nil
+}
+
+// GetItemEvents はアイテムのステータスの遷移を古い順に返します。
+func (r *ItemRepository) GetItemEvents(ctx context.Context, itemID int) ([]*ItemEvent, error) {
+	query := formatQuery(`
+	SELECT id, item_id, from_status, to_status, reason, retry_count, stage, error_message, created_at
+	FROM item_events
+	WHERE item_id = ?
+	ORDER BY created_at ASC, id ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events for item ID %d: %w", itemID, err)
+	}
+	defer rows.Close()
+
+	var events []*ItemEvent
+	for rows.Next() {
+		e := &ItemEvent{}
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.FromStatus, &e.ToStatus, &e.Reason, &e.RetryCount, &e.Stage, &e.Error, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan item event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
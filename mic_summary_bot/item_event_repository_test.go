@@ -0,0 +1,89 @@
+package micsummarybot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransitionItem(t *testing.T) {
+	tests := []struct {
+		from, to ItemStatus
+		want     bool
+	}{
+		{StatusUnprocessed, StatusPending, true},
+		{StatusUnprocessed, StatusProcessed, true},
+		{StatusDeferred, StatusDeferred, true},
+		{StatusDeferred, StatusPending, true},
+		{StatusPending, StatusDeferred, true},
+		{StatusPending, StatusProcessed, true},
+		{StatusProcessed, StatusProcessed, true},
+		{StatusProcessed, StatusPending, false},
+		{StatusProcessed, StatusUnprocessed, false},
+		{StatusDeferred, StatusUnprocessed, false},
+		{StatusPending, StatusUnprocessed, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, canTransitionItem(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestItemRepository_ItemEvents(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	item := &Item{URL: "https://www.soumu.go.jp/1.html", Title: "会議の開催", PublishedAt: now, Status: StatusUnprocessed, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, item))
+
+	item.Status = StatusDeferred
+	item.Reason = ReasonDownloadFailed
+	item.RetryCount = 1
+	require.NoError(t, repo.Update(ctx, item, ItemTransition{Stage: StageScreening, Err: errors.New("connection reset")}))
+	item.Status = StatusPending
+	item.Reason = ReasonNone
+	require.NoError(t, repo.Update(ctx, item, ItemTransition{Stage: StageScreening}))
+	item.Status = StatusProcessed
+	require.NoError(t, repo.Update(ctx, item, ItemTransition{Stage: StagePosting}))
+
+	t.Run("invalid transition is rejected", func(t *testing.T) {
+		item.Status = StatusPending
+		err := repo.Update(ctx, item, ItemTransition{Stage: StageScreening})
+		require.ErrorIs(t, err, ErrInvalidTransition)
+		stored, err := repo.GetItemByID(ctx, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusProcessed, stored.Status, "the item is not changed")
+	})
+
+	t.Run("override", func(t *testing.T) {
+		item.Status = StatusUnprocessed
+		require.NoError(t, repo.Update(ctx, item, ItemTransition{Stage: StageAdmin, Override: true}))
+	})
+
+	events, err := repo.GetItemEvents(ctx, item.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.Equal(t, StageFeed, events[0].Stage)
+	assert.Equal(t, StatusUnprocessed, events[0].ToStatus)
+
+	assert.Equal(t, StatusUnprocessed, events[1].FromStatus)
+	assert.Equal(t, StatusDeferred, events[1].ToStatus)
+	assert.Equal(t, ReasonDownloadFailed, events[1].Reason)
+	assert.Equal(t, 1, events[1].RetryCount)
+	assert.Equal(t, "connection reset", events[1].Error)
+
+	assert.Equal(t, StagePosting, events[3].Stage)
+	assert.Empty(t, events[3].Error)
+	assert.Equal(t, StatusProcessed, events[4].FromStatus)
+	assert.Equal(t, StatusUnprocessed, events[4].ToStatus)
+	assert.Equal(t, StageAdmin, events[4].Stage)
+
+	others, err := repo.GetItemEvents(ctx, item.ID+1)
+	require.NoError(t, err)
+	assert.Empty(t, others)
+}
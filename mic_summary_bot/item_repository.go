@@ -23,6 +23,21 @@ const (
 	StatusProcessed                     // 3: processed（処理済み）
 )
 
+// itemStatusLabels はログとエラーメッセージに使うステータスの名前
+var itemStatusLabels = map[ItemStatus]string{
+	StatusUnprocessed: "unprocessed",
+	StatusDeferred:    "deferred",
+	StatusPending:     "pending",
+	StatusProcessed:   "processed",
+}
+
+func (s ItemStatus) String() string {
+	if label, ok := itemStatusLabels[s]; ok {
+		return label
+	}
+	return fmt.Sprintf("ItemStatus(%d)", int(s))
+}
+
 // ItemReasonCode はアイテムが先送りまたは処理済みになった理由を表すコード
 type ItemReasonCode int
 
@@ -34,6 +49,8 @@ const (
 	ReasonLargeFileSkipped                         // 4: ファイルサイズが大きすぎるため要約スキップ
 	ReasonAPIFailed                                // 5: Gemini/Mastodon API呼び出し失敗
 	ReasonRetryLimitExceeded                       // 6: リトライ回数上限超過
+	ReasonSkippedByAdmin                           // 7: 管理者のコマンドでスキップ
+	ReasonMaxAgeExceeded                           // 8: 再試行の期限(retry.max_age)超過
 )
 
 // Item は items テーブルのレコードを表す構造体
@@ -52,9 +69,14 @@ type Item struct {
 // ItemRepository は items テーブルへの操作を提供する
 type ItemRepository struct {
 	db                    *sql.DB
+	dialect               *sqlDialect
 	maxDeferredRetryCount int
 }
 
+// itemClaimDuration は処理するアイテムを取得してから、他のインスタンスが同じアイテムを取得しないようにする時間。
+// アイテムのステータスを更新すると解除される。処理中に終了した場合も、この時間が過ぎれば再び取得できる
+const itemClaimDuration = 30 * time.Minute
+
 // formatQuery
 func formatQuery(query string) string {
 	query = strings.ReplaceAll(query, "\n", " ")
@@ -62,8 +84,7 @@ func formatQuery(query string) string {
 	return query
 }
 
-// NewItemRepository は新しいItemRepositoryインスタンスを作成し、データベース接続を初期化します。
-// テーブルが存在しない場合は作成します。
+// NewItemRepository はSQLiteのデータベースを開き、未適用のマイグレーションを適用します。
 func NewItemRepository(dbPath string, maxDeferredRetryCount int) (*ItemRepository, error) {
 	// if directory is not exists, create
 	if _, err := os.Stat(path.Dir(dbPath)); os.IsNotExist(err) {
@@ -84,32 +105,12 @@ func NewItemRepository(dbPath string, maxDeferredRetryCount int) (*ItemRepositor
 		return nil, fmt.Errorf("failed to verify database connection: %w", err)
 	}
 
-	// テーブル作成
-	createTableSQLs := []string{
-		`CREATE TABLE IF NOT EXISTS items (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		url TEXT NOT NULL UNIQUE,
-		title TEXT NOT NULL,
-		published_at TIMESTAMP NOT NULL,
-		status INTEGER NOT NULL,
-		reason INTEGER NOT NULL,
-		retry_count INTEGER NOT NULL,
-		created_at TIMESTAMP NOT NULL,
-		last_checked_at TIMESTAMP NOT NULL
-	);`,
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_items_url ON items(url);",
-		"CREATE INDEX IF NOT EXISTS idx_items_status_published_at ON items(status, published_at);",
-		"CREATE INDEX IF NOT EXISTS idx_items_status_last_checked_at ON items(status, last_checked_at);",
-	}
-	for _, createTableSQL := range createTableSQLs {
-		_, err = db.Exec(formatQuery(createTableSQL))
-		if err != nil {
-			db.Close()
-			return nil, fmt.Errorf("failed to create table: %w", err)
-		}
+	if err := migrate(context.Background(), db, sqliteDialect); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return &ItemRepository{db: db, maxDeferredRetryCount: maxDeferredRetryCount}, nil
+	return &ItemRepository{db: db, dialect: sqliteDialect, maxDeferredRetryCount: maxDeferredRetryCount}, nil
 }
 
 // Close はデータベース接続を閉じます。
@@ -140,13 +141,25 @@ func withTransaction(ctx context.Context, db *sql.DB, txFunc func(*sql.Tx) error
 
 // insert
 func (r *ItemRepository) insert(ctx context.Context, item *Item) error {
-	insertSQL := `
+	insertSQL := formatQuery(`
 	INSERT INTO items (url, title, published_at, status, reason, retry_count, created_at, last_checked_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?);
-	`
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id;
+	`)
 	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
-		_, err := tx.Exec(insertSQL, item.URL, item.Title, item.PublishedAt, item.Status, item.Reason, item.RetryCount, item.CreatedAt, item.LastCheckedAt)
-		return err
+		err := tx.QueryRowContext(ctx, insertSQL, item.URL, item.Title, item.PublishedAt, item.Status, item.Reason, item.RetryCount, item.CreatedAt, item.LastCheckedAt).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+		return addItemEvent(ctx, tx, &ItemEvent{
+			ItemID:     item.ID,
+			FromStatus: item.Status,
+			ToStatus:   item.Status,
+			Reason:     item.Reason,
+			RetryCount: item.RetryCount,
+			Stage:      StageFeed,
+			CreatedAt:  item.CreatedAt,
+		})
 	})
 	if err != nil {
 		return fmt.Errorf("failed to insert item: %w", err)
@@ -154,16 +167,46 @@ func (r *ItemRepository) insert(ctx context.Context, item *Item) error {
 	return nil
 }
 
-// Update updates database content. It updates last_checked_at automatically
-func (r *ItemRepository) Update(ctx context.Context, item *Item) error {
-	updateSQL := `
+// Update updates database content and records the transition as an item event.
+// It updates last_checked_at automatically, releases the claim and sets next_attempt_at from the transition.
+// Transitions not allowed by itemTransitions fail with ErrInvalidTransition unless transition.Override is set
+func (r *ItemRepository) Update(ctx context.Context, item *Item, transition ItemTransition) error {
+	updateSQL := formatQuery(`
 	UPDATE items
-	SET status = ?, reason = ?, retry_count = ?, last_checked_at = ?
+	SET status = ?, reason = ?, retry_count = ?, last_checked_at = ?, claimed_until = NULL, next_attempt_at = ?
 	WHERE id = ?;
-	`
+	`)
+	nextAttemptAt := sql.NullTime{Time: transition.NextAttemptAt.UTC(), Valid: !transition.NextAttemptAt.IsZero()}
 	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
-		_, err := tx.Exec(updateSQL, item.Status, item.Reason, item.RetryCount, time.Now().UTC(), item.ID)
-		return err
+		var from ItemStatus
+		err := tx.QueryRowContext(ctx, r.dialect.lockQuery("SELECT status FROM items WHERE id = ?;"), item.ID).Scan(&from)
+		if err == sql.ErrNoRows {
+			return nil // No item to update
+		}
+		if err != nil {
+			return err
+		}
+		if !transition.Override && !canTransitionItem(from, item.Status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, item.Status)
+		}
+
+		now := time.Now().UTC()
+		if _, err := tx.ExecContext(ctx, updateSQL, item.Status, item.Reason, item.RetryCount, now, nextAttemptAt, item.ID); err != nil {
+			return err
+		}
+		event := &ItemEvent{
+			ItemID:     item.ID,
+			FromStatus: from,
+			ToStatus:   item.Status,
+			Reason:     item.Reason,
+			RetryCount: item.RetryCount,
+			Stage:      transition.Stage,
+			CreatedAt:  now,
+		}
+		if transition.Err != nil {
+			event.Error = transition.Err.Error()
+		}
+		return addItemEvent(ctx, tx, event)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update item ID %d: %w", item.ID, err)
@@ -171,6 +214,43 @@ func (r *ItemRepository) Update(ctx context.Context, item *Item) error {
 	return nil
 }
 
+// RestoreItem はアイテムのステータスを、last_checked_at を含めて指定した値に戻します。
+// ドライランの後に元の状態に戻すために使います。遷移の制限は適用せず、イベントの段階は StageDryRun になります。
+// 処理中のアイテムは次に処理する時刻を過ぎているため、next_attempt_at は消去します。
+func (r *ItemRepository) RestoreItem(ctx context.Context, item *Item) error {
+	updateSQL := formatQuery(`
+	UPDATE items
+	SET status = ?, reason = ?, retry_count = ?, last_checked_at = ?, claimed_until = NULL, next_attempt_at = NULL
+	WHERE id = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		var from ItemStatus
+		err := tx.QueryRowContext(ctx, r.dialect.lockQuery("SELECT status FROM items WHERE id = ?;"), item.ID).Scan(&from)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, updateSQL, item.Status, item.Reason, item.RetryCount, item.LastCheckedAt, item.ID); err != nil {
+			return err
+		}
+		return addItemEvent(ctx, tx, &ItemEvent{
+			ItemID:     item.ID,
+			FromStatus: from,
+			ToStatus:   item.Status,
+			Reason:     item.Reason,
+			RetryCount: item.RetryCount,
+			Stage:      StageDryRun,
+			CreatedAt:  time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore item ID %d: %w", item.ID, err)
+	}
+	return nil
+}
+
 // GetItemByURL
 func (r *ItemRepository) GetItemByURL(ctx context.Context, url string) (*Item, error) {
 	query := formatQuery(`
@@ -191,28 +271,41 @@ func (r *ItemRepository) GetItemByURL(ctx context.Context, url string) (*Item, e
 	return &item, nil
 }
 
-// getItemWithStatusAndUpdateLastChecked は指定されたステータスのアイテムを取得し、last_checked_atを更新します。
-// トランザクション内で呼び出され、アイテムが見つからない場合はnilを返します。
-func (r *ItemRepository) getItemWithStatusAndUpdateLastChecked(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*Item, error) {
-	rows, err := tx.QueryContext(ctx, query, args...)
+// GetItemByID はIDでアイテムを取得します。見つからない場合はnilを返します。
+func (r *ItemRepository) GetItemByID(ctx context.Context, id int) (*Item, error) {
+	query := formatQuery(`
+	SELECT id, url, title, published_at, status, reason, retry_count, created_at, last_checked_at
+	FROM items
+	WHERE id = ?;
+	`)
+
+	row := r.db.QueryRowContext(ctx, query, id)
+	var item Item
+	err := row.Scan(&item.ID, &item.URL, &item.Title, &item.PublishedAt, &item.Status, &item.Reason, &item.RetryCount, &item.CreatedAt, &item.LastCheckedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil // Item not found
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get item by ID %d: %w", id, err)
 	}
-	defer rows.Close()
+	return &item, nil
+}
 
-	if !rows.Next() {
+// getItemWithStatusAndUpdateLastChecked は指定されたステータスのアイテムを取得し、last_checked_atを更新します。
+// トランザクション内で呼び出され、アイテムが見つからない場合はnilを返します。
+// now は claimed_until と next_attempt_at と比べる現在時刻で、クエリのプレースホルダーの値として args に含めます。
+func (r *ItemRepository) getItemWithStatusAndUpdateLastChecked(ctx context.Context, tx *sql.Tx, now time.Time, query string, args ...interface{}) (*Item, error) {
+	item := &Item{}
+	err := tx.QueryRowContext(ctx, r.dialect.claimQuery(query), args...).Scan(&item.ID, &item.URL, &item.Title, &item.PublishedAt, &item.Status, &item.Reason, &item.RetryCount, &item.CreatedAt, &item.LastCheckedAt)
+	if err == sql.ErrNoRows {
 		return nil, nil // No item found
 	}
-
-	item := &Item{}
-	if err := rows.Scan(&item.ID, &item.URL, &item.Title, &item.PublishedAt, &item.Status, &item.Reason, &item.RetryCount, &item.CreatedAt, &item.LastCheckedAt); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	// time.Now().UTC()を一度だけ呼び出して一貫性を保つ
-	now := time.Now().UTC()
-	updateSQL := `UPDATE items SET last_checked_at = ? WHERE id = ?;`
-	if _, err := tx.Exec(updateSQL, now, item.ID); err != nil {
+	updateSQL := `UPDATE items SET last_checked_at = ?, claimed_until = ? WHERE id = ?;`
+	if _, err := tx.ExecContext(ctx, updateSQL, now, now.Add(itemClaimDuration), item.ID); err != nil {
 		return nil, fmt.Errorf("failed to update last_checked_at: %w", err)
 	}
 	item.LastCheckedAt = now
@@ -221,20 +314,22 @@ func (r *ItemRepository) getItemWithStatusAndUpdateLastChecked(ctx context.Conte
 }
 
 // GetItemForSummarization は要約対象のアイテムを取得します。
-// 取得したアイテムのlast_checked_atを即座に更新します。
+// 取得したアイテムのlast_checked_atを即座に更新し、ステータスを更新するか一定時間が過ぎるまで他のインスタンスが取得しないようにします。
+// 次に処理する時刻(next_attempt_at)が未来のアイテムは取得しません。
 func (r *ItemRepository) GetItemForSummarization(ctx context.Context) (*Item, error) {
 	var item *Item
 	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
 		query := formatQuery(`
 			SELECT id, url, title, published_at, status, reason, retry_count, created_at, last_checked_at
 			FROM items
-			WHERE status = ?
+			WHERE status = ? AND (claimed_until IS NULL OR claimed_until < ?) AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
 			ORDER BY published_at ASC
 			LIMIT 1;
 		`)
 
+		now := time.Now().UTC()
 		var err error
-		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, query, StatusPending)
+		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, now, query, StatusPending, now, now)
 		if err != nil {
 			return fmt.Errorf("failed to get pending items: %w", err)
 		}
@@ -250,7 +345,8 @@ func (r *ItemRepository) GetItemForSummarization(ctx context.Context) (*Item, er
 }
 
 // GetItemForScreening はスクリーニング対象のアイテムを取得します。
-// 取得したアイテムのlast_checked_atを即座に更新します。
+// 取得したアイテムのlast_checked_atを即座に更新し、ステータスを更新するか一定時間が過ぎるまで他のインスタンスが取得しないようにします。
+// 次に処理する時刻(next_attempt_at)が未来のアイテムは取得しません。
 func (r *ItemRepository) GetItemForScreening(ctx context.Context) (*Item, error) {
 	var item *Item
 	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
@@ -258,13 +354,14 @@ func (r *ItemRepository) GetItemForScreening(ctx context.Context) (*Item, error)
 		query := formatQuery(`
 			SELECT id, url, title, published_at, status, reason, retry_count, created_at, last_checked_at
 			FROM items
-			WHERE status = ?
+			WHERE status = ? AND (claimed_until IS NULL OR claimed_until < ?) AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
 			ORDER BY published_at ASC
 			LIMIT 1;
 		`)
 
+		now := time.Now().UTC()
 		var err error
-		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, query, StatusUnprocessed)
+		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, now, query, StatusUnprocessed, now, now)
 		if err != nil {
 			return fmt.Errorf("failed to get unprocessed items: %w", err)
 		}
@@ -278,12 +375,12 @@ func (r *ItemRepository) GetItemForScreening(ctx context.Context) (*Item, error)
 		query = formatQuery(`
 			SELECT id, url, title, published_at, status, reason, retry_count, created_at, last_checked_at
 			FROM items
-			WHERE status = ? AND retry_count < ?
-			ORDER BY last_checked_at ASC
+			WHERE status = ? AND retry_count < ? AND (claimed_until IS NULL OR claimed_until < ?) AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+			ORDER BY COALESCE(next_attempt_at, last_checked_at) ASC
 			LIMIT 1;
 		`)
 
-		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, query, StatusDeferred, r.maxDeferredRetryCount)
+		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, now, query, StatusDeferred, r.maxDeferredRetryCount, now, now)
 		if err != nil {
 			return fmt.Errorf("failed to get deferred items: %w", err)
 		}
@@ -298,6 +395,53 @@ func (r *ItemRepository) GetItemForScreening(ctx context.Context) (*Item, error)
 	return item, nil // item will be nil if no items found for screening
 }
 
+// GetItemOverRetryLimit は先送りした回数が max_deferred_retry_count に達し、再試行しなくなったアイテムを取得します。
+// 次に処理する時刻(next_attempt_at)によらず取得し、他のインスタンスが取得しないよう last_checked_at と claimed_until を更新します。
+// max_deferred_retry_count が0の場合は上限を設けていないものとして、常にnilを返します。
+func (r *ItemRepository) GetItemOverRetryLimit(ctx context.Context) (*Item, error) {
+	if r.maxDeferredRetryCount <= 0 {
+		return nil, nil
+	}
+	var item *Item
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		query := formatQuery(`
+			SELECT id, url, title, published_at, status, reason, retry_count, created_at, last_checked_at
+			FROM items
+			WHERE status = ? AND retry_count >= ? AND (claimed_until IS NULL OR claimed_until < ?)
+			ORDER BY last_checked_at ASC
+			LIMIT 1;
+		`)
+
+		now := time.Now().UTC()
+		var err error
+		item, err = r.getItemWithStatusAndUpdateLastChecked(ctx, tx, now, query, StatusDeferred, r.maxDeferredRetryCount, now)
+		if err != nil {
+			return fmt.Errorf("failed to get items over retry limit: %w", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil // item will be nil if no items are over the retry limit
+}
+
+// ReleaseItem は取得したアイテムを、ステータスを更新せずに nextAttemptAt 以降に再び取得できる状態に戻します。
+// 投稿の時間帯の制限などで、処理せずに後回しにする場合に使います。nextAttemptAt がゼロ値の場合はすぐに取得できます。
+func (r *ItemRepository) ReleaseItem(ctx context.Context, itemID int, nextAttemptAt time.Time) error {
+	updateSQL := `UPDATE items SET claimed_until = NULL, next_attempt_at = ? WHERE id = ?;`
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, updateSQL, sql.NullTime{Time: nextAttemptAt.UTC(), Valid: !nextAttemptAt.IsZero()}, itemID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release item ID %d: %w", itemID, err)
+	}
+	return nil
+}
+
 // IsURLExists
 func (r *ItemRepository) IsURLExists(ctx context.Context, url string) (bool, error) {
 	query := `SELECT COUNT(*) FROM items WHERE url = ?;`
@@ -386,3 +530,45 @@ func (r *ItemRepository) CountUnprocessedItems(ctx context.Context) (int, error)
 	}
 	return count, nil
 }
+
+// CountItemsByStatus はステータスごとのアイテム数を返します。アイテムがないステータスは含みません。
+func (r *ItemRepository) CountItemsByStatus(ctx context.Context) (map[ItemStatus]int, error) {
+	query := `SELECT status, COUNT(*) FROM items GROUP BY status;`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count items by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[ItemStatus]int)
+	for rows.Next() {
+		var status ItemStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan item count: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// CountItemsByReason は指定したステータスのアイテム数を理由ごとに返します。アイテムがない理由は含みません。
+func (r *ItemRepository) CountItemsByReason(ctx context.Context, status ItemStatus) (map[ItemReasonCode]int, error) {
+	query := `SELECT reason, COUNT(*) FROM items WHERE status = ? GROUP BY reason;`
+	rows, err := r.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count items by reason: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[ItemReasonCode]int)
+	for rows.Next() {
+		var reason ItemReasonCode
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan item count: %w", err)
+		}
+		counts[reason] = count
+	}
+	return counts, rows.Err()
+}
@@ -324,7 +324,7 @@ func TestItemRepository_Update(t *testing.T) {
 	// Record time before update
 	beforeUpdate := time.Now().UTC()
 
-	err = repo.Update(context.Background(), insertedItem)
+	err = repo.Update(context.Background(), insertedItem, ItemTransition{Stage: StageScreening})
 	require.NoError(t, err, "Update should succeed")
 
 	// Verify the update
@@ -353,7 +353,7 @@ func TestItemRepository_Update(t *testing.T) {
 		Reason:     ReasonNone,
 		RetryCount: 0,
 	}
-	err = repo.Update(context.Background(), nonExistentItem)
+	err = repo.Update(context.Background(), nonExistentItem, ItemTransition{Stage: StageScreening})
 	require.NoError(t, err, "Updating a non-existent item should not error")
 }
 
@@ -397,6 +397,34 @@ func TestItemRepository_GetItemByURL(t *testing.T) {
 	})
 }
 
+func TestItemRepository_GetItemByID(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().Truncate(time.Second).UTC()
+	itemToInsert := &Item{
+		URL:           "http://example.com/getbyid",
+		Title:         "Get By ID Test",
+		PublishedAt:   now,
+		Status:        StatusProcessed,
+		CreatedAt:     now,
+		LastCheckedAt: now,
+	}
+	require.NoError(t, repo.insert(context.Background(), itemToInsert))
+	inserted, err := repo.GetItemByURL(context.Background(), itemToInsert.URL)
+	require.NoError(t, err)
+
+	retrievedItem, err := repo.GetItemByID(context.Background(), inserted.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrievedItem)
+	assert.Equal(t, itemToInsert.URL, retrievedItem.URL)
+	assert.Equal(t, itemToInsert.Status, retrievedItem.Status)
+
+	retrievedItem, err = repo.GetItemByID(context.Background(), inserted.ID+1)
+	require.NoError(t, err)
+	assert.Nil(t, retrievedItem)
+}
+
 func TestItemRepository_GetItemForSummarization(t *testing.T) {
 	baseTime := time.Now().Truncate(time.Second).UTC()
 
@@ -566,3 +594,22 @@ func TestItemRepository_GetItemForScreening(t *testing.T) {
 		assert.True(t, dbItem.LastCheckedAt.After(oldTime), "last_checked_at should be updated in database")
 	})
 }
+
+func TestItemRepository_GetItemOverRetryLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// max_deferred_retry_count が0の場合(デフォルト)は、先送りしたアイテムを再試行せず、処理済みにもしない
+	repo, err := NewItemRepository(":memory:", 0)
+	require.NoError(t, err)
+	defer repo.Close()
+	deferred := &Item{URL: "https://www.soumu.go.jp/1.html", Title: "会議の開催", PublishedAt: now, Status: StatusDeferred, Reason: ReasonDownloadFailed, RetryCount: 5, CreatedAt: now, LastCheckedAt: now}
+	require.NoError(t, repo.insert(ctx, deferred))
+
+	item, err := repo.GetItemForScreening(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, item, "deferred items are not retried")
+	item, err = repo.GetItemOverRetryLimit(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, item, "deferred items are kept when there is no limit")
+}
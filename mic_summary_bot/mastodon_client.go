@@ -2,91 +2,712 @@ package micsummarybot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 	"strings"
 	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mattn/go-mastodon"
+	"golang.org/x/text/unicode/norm"
+)
+
+// mastodonPublisherName is the publisher name recorded in the posts table.
+const mastodonPublisherName = "mastodon"
+
+const (
+	// mastodonDefaultMaxCharacters is used when the instance does not report its character limit.
+	mastodonDefaultMaxCharacters = 500
+	// mastodonDefaultCharactersPerURL is the length Mastodon counts for each URL.
+	mastodonDefaultCharactersPerURL = 23
+	// mastodonInstanceTimeout is the timeout for reading the instance configuration.
+	mastodonInstanceTimeout = 10 * time.Second
 )
 
 // MastodonClient is a client for posting to Mastodon.
 type MastodonClient struct {
 	client          *mastodon.Client
+	repository      Storage
 	template        *template.Template
 	noValueTemplate *template.Template
+	threadTemplate  *template.Template
+	digestTemplate  *digestTemplate
+	feedName        string
+	threadMode      bool
+	// limits is read from the instance at startup unless max_characters is configured.
+	limits   mastodonLimits
+	overflow MastodonOverflowMode
+	// usesCitedSummary is true if the post template uses CitedSummary, so that overflowing sentences are taken from it.
+	usesCitedSummary bool
+	variants         []mastodonVariant
+	// postOptions holds the settings applied to the statuses unless a rule overrides them.
+	postOptions        mastodonPostOptions
+	rules              []mastodonPostRule
+	meetingNamePattern *regexp.Regexp
+	hashtagKeywords    []string
+}
+
+// mastodonLimits holds the character limit of a status.
+type mastodonLimits struct {
+	maxCharacters int
+	// charactersPerURL is the length counted for each URL in a status.
+	charactersPerURL int
+}
+
+// mastodonPostOptions holds the settings applied to the statuses of an item.
+type mastodonPostOptions struct {
+	visibility  string
+	language    string
+	spoilerText string
+	sensitive   bool
+	// hashtags is the hashtags appended to the first status. The summary keywords are added on rendering.
+	hashtags []string
+}
+
+// toot returns the status with the options applied.
+func (o mastodonPostOptions) toot(status string, inReplyTo mastodon.ID) *mastodon.Toot {
+	return &mastodon.Toot{
+		Status:      status,
+		InReplyToID: inReplyTo,
+		Visibility:  o.visibility,
+		Language:    o.language,
+		SpoilerText: o.spoilerText,
+		Sensitive:   o.sensitive,
+	}
+}
+
+// mastodonPostRule overrides the post options for the items matching its patterns.
+type mastodonPostRule struct {
+	config PostRuleConfig
+	title  *regexp.Regexp
+	url    *regexp.Regexp
+}
+
+func (r *mastodonPostRule) matches(item Item) bool {
+	return (r.title == nil || r.title.MatchString(item.Title)) && (r.url == nil || r.url.MatchString(item.URL))
+}
+
+// mastodonVariant holds how a summary variant is posted.
+type mastodonVariant struct {
+	config   VariantConfig
+	template *template.Template
+	// client is the account used to post the variant. It is the main account for the reply mode.
+	client *mastodon.Client
 }
 
 type PostInfo struct {
 	Title   string
 	Summary string
 	URL     string
+	// CitedSummary is the summary with citation markers such as [1] or [1, p.3] after each sentence.
+	// It is the same as Summary when the model did not return citations.
+	CitedSummary string
+	// Sources lists the documents referred to by the citation markers.
+	Sources []PostSource
+	// Extra holds the output fields added by gemini.extra_output_fields, keyed by name.
+	Extra map[string]string
+	// PublishedAt is the publication time of the item in the feed.
+	PublishedAt time.Time
+	// FeedName is rss.name in the config.
+	FeedName string
+	// Documents lists the summarized attachments.
+	Documents []PostDocument
+	// KeyPoints lists the key points of all the attachments.
+	KeyPoints []string
+	// Metadata is the meeting metadata such as the date and the attendees, taken from the first attachment that has it.
+	Metadata string
+	// ScreeningReason is why the item was deemed not valuable. It is set only in the no value posts.
+	ScreeningReason string
+}
+
+// PostDocument is a summarized attachment in the template data.
+type PostDocument struct {
+	Label     string
+	URL       string
+	Summary   string
+	KeyPoints []string
+	Metadata  string
+}
+
+// ThreadPostInfo is the template data for a reply in the thread mode. Each reply covers one attachment.
+type ThreadPostInfo struct {
+	Title     string // Title of the item
+	Label     string // Link text of the attachment
+	URL       string // Direct link to the attachment
+	Summary   string
+	KeyPoints []string
+	Index     int // 1-origin index of the attachment
+	Total     int // Number of attachments
 }
 
 // NewMastodonClient initializes and returns a new MastodonClient.
-func NewMastodonClient(config *Config) (*MastodonClient, error) {
-	client := mastodon.NewClient(&mastodon.Config{
-		Server:       config.Mastodon.InstanceURL,
-		ClientID:     config.Mastodon.ClientID,
-		ClientSecret: config.Mastodon.ClientSecret,
-		AccessToken:  config.Mastodon.AccessToken,
-	})
+func NewMastodonClient(config *Config, repository Storage) (*MastodonClient, error) {
+	client := newMastodonAPIClient(&config.Mastodon)
+
+	// The templates are executed with sample data to report errors such as unknown fields at startup.
+	t, err := parsePostTemplate("post", config.Mastodon.PostTemplate)
+	if err == nil {
+		err = validatePostTemplate(t, samplePostInfo())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid post template: %w", err)
+	}
+
+	noValueT, err := parsePostTemplate("no_value_post", config.Mastodon.NoValuePostTemplate)
+	if err == nil {
+		err = validatePostTemplate(noValueT, samplePostInfo())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid no value post template: %w", err)
+	}
 
-	t, err := template.New("post").Parse(config.Mastodon.PostTemplate)
+	threadT, err := parsePostTemplate("thread_post", config.Mastodon.ThreadPostTemplate)
+	if err == nil {
+		err = validatePostTemplate(threadT, sampleThreadPostInfo())
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse post template: %w", err)
+		return nil, fmt.Errorf("invalid thread post template: %w", err)
 	}
 
-	noValueT, err := template.New("no_value_post").Parse(config.Mastodon.NoValuePostTemplate)
+	digestT, err := newDigestTemplate(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse no value post template: %w", err)
+		return nil, err
+	}
+
+	switch config.Mastodon.Overflow {
+	case "", MastodonOverflowTruncate, MastodonOverflowThread:
+	default:
+		return nil, fmt.Errorf("unknown overflow mode %q", config.Mastodon.Overflow)
+	}
+
+	limits := mastodonLimits{maxCharacters: config.Mastodon.MaxCharacters, charactersPerURL: mastodonDefaultCharactersPerURL}
+	switch {
+	case limits.maxCharacters > 0:
+	case config.Mastodon.Enabled:
+		limits, err = fetchInstanceLimits(client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read character limit from instance (set mastodon.max_characters to skip): %w", err)
+		}
+	default:
+		limits.maxCharacters = mastodonDefaultMaxCharacters
+	}
+
+	postOptions, rules, err := newMastodonPostOptions(&config.Mastodon)
+	if err != nil {
+		return nil, err
+	}
+	var meetingNamePattern *regexp.Regexp
+	if config.Mastodon.Hashtags.FromMeetingName {
+		meetingNamePattern, err = regexp.Compile(config.Mastodon.Hashtags.MeetingNamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile meeting name pattern: %w", err)
+		}
+	}
+
+	var variants []mastodonVariant
+	for _, v := range config.Variants {
+		variantT, err := parsePostTemplate("variant_post_"+v.Name, v.PostTemplate)
+		if err == nil {
+			err = validatePostTemplate(variantT, samplePostInfo())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid post template for variant %s: %w", v.Name, err)
+		}
+		variant := mastodonVariant{config: v, template: variantT, client: client}
+		switch v.PostMode {
+		case VariantPostReply:
+		case VariantPostAccount:
+			if v.Mastodon == nil {
+				return nil, fmt.Errorf("mastodon account is not configured for variant %s", v.Name)
+			}
+			variant.client = newMastodonAPIClient(v.Mastodon)
+		default:
+			return nil, fmt.Errorf("unknown post mode %q for variant %s", v.PostMode, v.Name)
+		}
+		variants = append(variants, variant)
 	}
 
 	return &MastodonClient{
-		client:          client,
-		template:        t,
-		noValueTemplate: noValueT,
+		client:           client,
+		repository:       repository,
+		template:         t,
+		noValueTemplate:  noValueT,
+		threadTemplate:   threadT,
+		digestTemplate:   digestT,
+		feedName:         config.RSS.Name,
+		threadMode:       config.Mastodon.ThreadMode,
+		limits:           limits,
+		overflow:         config.Mastodon.Overflow,
+		usesCitedSummary: templateUsesField(t, "CitedSummary"),
+		variants:         variants,
+
+		postOptions:        postOptions,
+		rules:              rules,
+		meetingNamePattern: meetingNamePattern,
+		hashtagKeywords:    config.Mastodon.Hashtags.Keywords,
 	}, nil
 }
 
-// PostSummary posts the summary result to Mastodon.
-func (c *MastodonClient) PostSummary(ctx context.Context, task Item, summary SummarizeResult) error {
-	var buf strings.Builder
-	err := c.template.Execute(&buf, PostInfo{
-		Title:   task.Title,
-		Summary: summary.FinalSummary,
-		URL:     task.URL,
+// newMastodonPostOptions validates the post settings and compiles the rules.
+func newMastodonPostOptions(config *MastodonConfig) (mastodonPostOptions, []mastodonPostRule, error) {
+	options := mastodonPostOptions{
+		visibility:  config.Visibility,
+		language:    config.Language,
+		spoilerText: config.SpoilerText,
+		sensitive:   config.Sensitive,
+		hashtags:    config.Hashtags.Static,
+	}
+	if options.visibility == "" {
+		options.visibility = mastodon.VisibilityUnlisted
+	}
+	if err := validateVisibility(options.visibility); err != nil {
+		return mastodonPostOptions{}, nil, err
+	}
+
+	var rules []mastodonPostRule
+	for i, rc := range config.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("#%d", i+1)
+		}
+		rule := mastodonPostRule{config: rc}
+		var err error
+		if rc.TitlePattern != "" {
+			if rule.title, err = regexp.Compile(rc.TitlePattern); err != nil {
+				return mastodonPostOptions{}, nil, fmt.Errorf("failed to compile title pattern of rule %s: %w", rc.Name, err)
+			}
+		}
+		if rc.URLPattern != "" {
+			if rule.url, err = regexp.Compile(rc.URLPattern); err != nil {
+				return mastodonPostOptions{}, nil, fmt.Errorf("failed to compile URL pattern of rule %s: %w", rc.Name, err)
+			}
+		}
+		if rc.Visibility != "" {
+			if err := validateVisibility(rc.Visibility); err != nil {
+				return mastodonPostOptions{}, nil, fmt.Errorf("invalid rule %s: %w", rc.Name, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return options, rules, nil
+}
+
+func validateVisibility(visibility string) error {
+	switch visibility {
+	case mastodon.VisibilityPublic, mastodon.VisibilityUnlisted, mastodon.VisibilityFollowersOnly, mastodon.VisibilityDirectMessage:
+		return nil
+	default:
+		return fmt.Errorf("unknown visibility %q", visibility)
+	}
+}
+
+// postOptionsFor returns the post options for the item, applying the first matching rule.
+func (c *MastodonClient) postOptionsFor(item Item) mastodonPostOptions {
+	options := c.postOptions
+	options.hashtags = append([]string{}, c.postOptions.hashtags...)
+	for _, rule := range c.rules {
+		if !rule.matches(item) {
+			continue
+		}
+		pkgLogger.Debug("Applying post rule", "rule", rule.config.Name, "url", item.URL)
+		if rule.config.Visibility != "" {
+			options.visibility = rule.config.Visibility
+		}
+		if rule.config.Language != "" {
+			options.language = rule.config.Language
+		}
+		if rule.config.SpoilerText != "" {
+			options.spoilerText = rule.config.SpoilerText
+		}
+		if rule.config.Sensitive != nil {
+			options.sensitive = *rule.config.Sensitive
+		}
+		options.hashtags = append(options.hashtags, rule.config.Hashtags...)
+		break
+	}
+	if c.meetingNamePattern != nil {
+		if name := meetingName(item.Title, c.meetingNamePattern); name != "" {
+			options.hashtags = append(options.hashtags, name)
+		}
+	}
+	return options
+}
+
+// summaryHashtags returns the hashtags for the summary, adding the keywords that appear in it.
+func (c *MastodonClient) summaryHashtags(options mastodonPostOptions, summary SummarizeResult) []string {
+	tags := options.hashtags
+	text := norm.NFKC.String(summary.FinalSummary)
+	for _, keyword := range c.hashtagKeywords {
+		if keyword != "" && strings.Contains(text, norm.NFKC.String(keyword)) {
+			tags = append(tags, keyword)
+		}
+	}
+	return tags
+}
+
+// appendHashtags appends the hashtag line to the status text.
+func appendHashtags(text string, hashtags string) string {
+	if hashtags == "" {
+		return text
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + hashtags
+}
+
+// fetchInstanceLimits reads the character limit and the length counted for URLs from the instance configuration.
+// If the instance does not report them, the defaults are used.
+func fetchInstanceLimits(client *mastodon.Client) (mastodonLimits, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mastodonInstanceTimeout)
+	defer cancel()
+
+	limits := mastodonLimits{maxCharacters: mastodonDefaultMaxCharacters, charactersPerURL: mastodonDefaultCharactersPerURL}
+	instance, err := client.GetInstance(ctx)
+	if err != nil {
+		return limits, fmt.Errorf("failed to get instance configuration: %w", err)
+	}
+	if instance.Configuration == nil || instance.Configuration.Statuses == nil {
+		pkgLogger.Warn("Instance does not report character limit, using default", "max_characters", limits.maxCharacters)
+		return limits, nil
+	}
+	statuses := *instance.Configuration.Statuses
+	if n := statuses["max_characters"]; n > 0 {
+		limits.maxCharacters = n
+	}
+	if n := statuses["characters_reserved_per_url"]; n > 0 {
+		limits.charactersPerURL = n
+	}
+	pkgLogger.Info("Read instance character limit", "max_characters", limits.maxCharacters, "characters_reserved_per_url", limits.charactersPerURL)
+	return limits, nil
+}
+
+func newMastodonAPIClient(config *MastodonConfig) *mastodon.Client {
+	client := mastodon.NewClient(&mastodon.Config{
+		Server:       config.InstanceURL,
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		AccessToken:  config.AccessToken,
 	})
+	client.Transport = &idempotencyTransport{base: http.DefaultTransport}
+	return client
+}
+
+// renderSummary renders the statuses for the summary.
+// The first status holds the summary, shortened or continued in replies if it exceeds the character limit.
+// In the thread mode, each following status covers one attachment, split by the character limit.
+func (c *MastodonClient) renderSummary(task Item, summary SummarizeResult) ([]string, error) {
+	hashtags := hashtagLine(c.summaryHashtags(c.postOptionsFor(task), summary))
+	statuses, err := c.renderMainStatuses(newPostInfo(c.feedName, task, summary), hashtags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	if !c.threadMode {
+		return statuses, nil
+	}
+
+	for i := range statuses {
+		statuses[i] = strings.TrimSpace(statuses[i])
+	}
+	var buf strings.Builder
+	for i, doc := range summary.Documents {
+		url := doc.URL
+		if url == "" {
+			url = task.URL
+		}
+		buf.Reset()
+		err := c.threadTemplate.Execute(&buf, ThreadPostInfo{
+			Title:     task.Title,
+			Label:     doc.Label,
+			URL:       url,
+			Summary:   doc.Summary,
+			KeyPoints: doc.KeyPoints,
+			Index:     i + 1,
+			Total:     len(summary.Documents),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute thread template: %w", err)
+		}
+		statuses = append(statuses, splitText(buf.String(), c.limits.maxCharacters, c.textLength)...)
+	}
+	return statuses, nil
+}
+
+// Name returns the publisher name recorded in the database.
+func (c *MastodonClient) Name() string {
+	return mastodonPublisherName
+}
+
+// fits reports whether the text fits in a status.
+func (c *MastodonClient) fits(text string) bool {
+	return c.textLength(text) <= c.limits.maxCharacters
+}
+
+// textLength returns the length of the text counted as Mastodon does.
+func (c *MastodonClient) textLength(text string) int {
+	return mastodonTextLength(text, c.limits.charactersPerURL)
+}
+
+// renderMainStatuses renders the summary status. If it exceeds the character limit,
+// trailing sentences of the summary are dropped at "。" until it fits.
+// In the thread overflow mode, the dropped sentences are returned as following statuses.
+func (c *MastodonClient) renderMainStatuses(info PostInfo, hashtags string) ([]string, error) {
+	text, err := c.renderMainStatus(info, hashtags)
+	if err != nil || c.fits(text) {
+		return []string{text}, err
+	}
+
+	summaries := splitSentences(info.Summary)
+	cited := splitSentences(info.CitedSummary)
+	overflow := summaries
+	if c.usesCitedSummary {
+		overflow = cited
+	}
+	for k := len(overflow) - 1; k >= 1; k-- {
+		shortened := info
+		shortened.Summary = strings.Join(summaries[:min(k, len(summaries))], "")
+		shortened.CitedSummary = strings.Join(cited[:min(k, len(cited))], "")
+		text, err := c.renderMainStatus(shortened, hashtags)
+		if err != nil {
+			return nil, err
+		}
+		if c.fits(text) {
+			pkgLogger.Info("Shortened summary to fit in character limit", "sentences", k, "total_sentences", len(overflow), "overflow", c.overflow)
+			return c.withOverflow(text, overflow[k:]), nil
+		}
+	}
+
+	// Even the first sentence does not fit, so truncate it in the middle.
+	summaryLength := utf8.RuneCountInString(info.Summary)
+	citedLength := utf8.RuneCountInString(info.CitedSummary)
+	over := c.textLength(text) - c.limits.maxCharacters
+	for n := 0; over+n < max(summaryLength, citedLength); n++ {
+		shortened := info
+		shortened.Summary = truncateRunes(info.Summary, summaryLength-over-n)
+		shortened.CitedSummary = truncateRunes(info.CitedSummary, citedLength-over-n)
+		text, err := c.renderMainStatus(shortened, hashtags)
+		if err != nil {
+			return nil, err
+		}
+		if c.fits(text) {
+			pkgLogger.Info("Truncated summary to fit in character limit", "overflow", c.overflow)
+			return c.withOverflow(text, overflow), nil
+		}
+	}
+	pkgLogger.Warn("Status exceeds character limit even without summary", "max_characters", c.limits.maxCharacters)
+	return []string{text}, nil
+}
+
+// renderMainStatus renders the summary status followed by the hashtags.
+func (c *MastodonClient) renderMainStatus(info PostInfo, hashtags string) (string, error) {
+	text, err := executeTemplate(c.template, info)
 	if err != nil {
-		pkgLogger.Error("Failed to execute template", "error", err)
+		return "", err
+	}
+	return appendHashtags(text, hashtags), nil
+}
+
+// withOverflow appends the overflowing sentences as following statuses in the thread overflow mode.
+func (c *MastodonClient) withOverflow(text string, sentences []string) []string {
+	statuses := []string{text}
+	if c.overflow != MastodonOverflowThread {
+		return statuses
+	}
+
+	var current strings.Builder
+	for _, sentence := range sentences {
+		if current.Len() > 0 && !c.fits(current.String()+sentence) {
+			statuses = append(statuses, current.String())
+			current.Reset()
+		}
+		if !c.fits(sentence) {
+			statuses = append(statuses, splitText(sentence, c.limits.maxCharacters, c.textLength)...)
+			continue
+		}
+		current.WriteString(sentence)
+	}
+	if current.Len() > 0 {
+		statuses = append(statuses, current.String())
+	}
+	return statuses
+}
+
+// PostSummary posts the summary result to Mastodon, followed by the summary variants.
+// Posted status IDs are recorded so that a partially posted thread is resumed on the next call.
+// Failures while posting variants are logged and do not fail the main post.
+func (c *MastodonClient) PostSummary(ctx context.Context, task Item, summary SummarizeResult) error {
+	statuses, err := c.renderSummary(task, summary)
+	if err != nil {
+		pkgLogger.Error("Failed to render summary", "error", err)
 		return err
 	}
-	status := buf.String()
 
-	// The character limit for Mastodon is 5000 characters by default, so we don't check it here.
-	// On posting error, the current post is skipped.
-	s, err := c.client.PostStatus(ctx, &mastodon.Toot{Status: status, Visibility: mastodon.VisibilityUnlisted})
+	options := c.postOptionsFor(task)
+	s, err := c.postThread(ctx, task, OutboxEntry{Kind: PostKindSummary}, statuses, options)
 	if err != nil {
 		pkgLogger.Error("Failed to post to Mastodon", "error", err)
 		return err
 	}
-	pkgLogger.Info("Successfully posted to Mastodon", "url", s.URL)
+	pkgLogger.Info("Successfully posted to Mastodon", "url", s.URL, "statuses", len(statuses))
+
+	posted, err := c.repository.GetPosts(ctx, task.ID, mastodonPublisherName, PostKindVariant)
+	if err != nil {
+		pkgLogger.Error("Failed to get posted variants", "error", err)
+		return nil
+	}
+	postedVariants := make(map[int]bool)
+	for _, p := range posted {
+		postedVariants[p.Seq] = true
+	}
+	for i, variant := range summary.Variants {
+		if postedVariants[i] {
+			continue
+		}
+		if _, err := c.PostVariant(ctx, task, i, variant, s.ID, options); err != nil {
+			pkgLogger.Error("Failed to post summary variant to Mastodon", "name", variant.Name, "error", err)
+		}
+	}
 	return nil
 }
 
-// PostNoValue posts a predefined message for items deemed not valuable.
-func (c *MastodonClient) PostNoValue(ctx context.Context, item Item) error {
+// postThread posts the statuses as a thread and returns the first status.
+// thread gives the kind of the statuses, and the digest ID for a digest; the sequence numbers are set for each status.
+// Statuses already recorded in the posts table are skipped, and the rest are posted as replies to the last one.
+// The post options are applied to all the statuses in the thread.
+func (c *MastodonClient) postThread(ctx context.Context, task Item, thread OutboxEntry, statuses []string, options mastodonPostOptions) (*mastodon.Status, error) {
+	var posted []*PostRecord
+	var err error
+	if thread.DigestID != 0 {
+		posted, err = c.repository.GetDigestPosts(ctx, thread.DigestID, mastodonPublisherName)
+	} else {
+		posted, err = c.repository.GetPosts(ctx, task.ID, mastodonPublisherName, thread.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	postedBySeq := make(map[int]*PostRecord)
+	for _, p := range posted {
+		postedBySeq[p.Seq] = p
+	}
+
+	var first *mastodon.Status
+	var replyTo mastodon.ID
+	for seq, text := range statuses {
+		if p, ok := postedBySeq[seq]; ok {
+			pkgLogger.Debug("Skipping already posted status", "seq", seq, "status_id", p.StatusID)
+			replyTo = mastodon.ID(p.StatusID)
+			if seq == 0 {
+				first = &mastodon.Status{ID: replyTo, URL: p.URL}
+			}
+			continue
+		}
+
+		entry := thread
+		entry.Seq = seq
+		s, err := c.postOutboxEntry(ctx, c.client, task, &entry, options.toot(text, replyTo))
+		if err != nil {
+			return nil, fmt.Errorf("failed to post status %d/%d: %w", seq+1, len(statuses), err)
+		}
+		if seq == 0 {
+			first = s
+		}
+		replyTo = s.ID
+	}
+	return first, nil
+}
+
+// PostVariant posts a summary variant according to its post mode. index is the index of the variant in the summary.
+// In the reply mode the variant is posted as a reply to inReplyTo.
+// The visibility and the content warning of the main post are applied, but not the language and the hashtags.
+func (c *MastodonClient) PostVariant(ctx context.Context, task Item, index int, variant SummaryVariant, inReplyTo mastodon.ID, options mastodonPostOptions) (*mastodon.Status, error) {
+	text, v, err := c.renderVariant(task, variant)
+	if err != nil {
+		return nil, err
+	}
+
+	options.language = ""
+	toot := options.toot(text, "")
+	if v.config.PostMode == VariantPostReply {
+		toot.InReplyToID = inReplyTo
+	}
+	s, err := c.postOutboxEntry(ctx, v.client, task, &OutboxEntry{Kind: PostKindVariant, Seq: index, Variant: v.config.Name}, toot)
+	if err != nil {
+		return nil, err
+	}
+	pkgLogger.Info("Successfully posted summary variant to Mastodon", "name", variant.Name, "url", s.URL)
+	return s, nil
+}
+
+// renderVariant renders the status for the summary variant.
+func (c *MastodonClient) renderVariant(task Item, variant SummaryVariant) (string, *mastodonVariant, error) {
+	var v *mastodonVariant
+	for i := range c.variants {
+		if c.variants[i].config.Name == variant.Name {
+			v = &c.variants[i]
+			break
+		}
+	}
+	if v == nil {
+		return "", nil, fmt.Errorf("variant %s is not configured", variant.Name)
+	}
+
 	var buf strings.Builder
-	err := c.noValueTemplate.Execute(&buf, PostInfo{
-		Title: item.Title,
-		URL:   item.URL,
+	err := v.template.Execute(&buf, PostInfo{
+		Title:       task.Title,
+		Summary:     variant.Summary,
+		URL:         task.URL,
+		PublishedAt: task.PublishedAt,
+		FeedName:    c.feedName,
 	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to execute post template for variant %s: %w", variant.Name, err)
+	}
+	return buf.String(), v, nil
+}
+
+// renderNoValue renders the status for an item deemed not valuable.
+func (c *MastodonClient) renderNoValue(item Item, screening *ScreeningResult) (string, error) {
+	text, err := executeTemplate(c.noValueTemplate, newNoValuePostInfo(c.feedName, item, screening))
+	if err != nil {
+		return "", err
+	}
+	return appendHashtags(text, hashtagLine(c.postOptionsFor(item).hashtags)), nil
+}
+
+// renderSummaryPosts renders the summary statuses followed by the variants.
+func (c *MastodonClient) renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error) {
+	statuses, err := c.renderSummary(item, summary)
+	if err != nil {
+		return nil, err
+	}
+	for _, variant := range summary.Variants {
+		text, _, err := c.renderVariant(item, variant)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, text)
+	}
+	return statuses, nil
+}
+
+func (c *MastodonClient) renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error) {
+	text, err := c.renderNoValue(item, screening)
+	return []string{text}, err
+}
+
+// PostNoValue posts a predefined message for items deemed not valuable.
+func (c *MastodonClient) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	status, err := c.renderNoValue(item, screening)
 	if err != nil {
 		pkgLogger.Error("Failed to execute no value template", "error", err)
 		return err
 	}
-	status := buf.String()
+	options := c.postOptionsFor(item)
 
-	s, err := c.client.PostStatus(ctx, &mastodon.Toot{Status: status, Visibility: mastodon.VisibilityUnlisted})
+	s, err := c.postStatus(ctx, c.client, item, PostKindNoValue, 0, options.toot(status, ""))
 	if err != nil {
 		pkgLogger.Error("Failed to post no value message to Mastodon", "error", err)
 		return err
@@ -94,3 +715,285 @@ func (c *MastodonClient) PostNoValue(ctx context.Context, item Item) error {
 	pkgLogger.Info("Successfully posted no value message to Mastodon", "url", s.URL)
 	return nil
 }
+
+// PostFallback posts the text for an item given up without a summary, with the post options and hashtags of the item.
+func (c *MastodonClient) PostFallback(ctx context.Context, item Item, text string) error {
+	options := c.postOptionsFor(item)
+	status := appendHashtags(text, hashtagLine(options.hashtags))
+	s, err := c.postStatus(ctx, c.client, item, PostKindFallback, 0, options.toot(status, ""))
+	if err != nil {
+		pkgLogger.Error("Failed to post fallback message to Mastodon", "error", err)
+		return err
+	}
+	pkgLogger.Info("Successfully posted fallback message to Mastodon", "url", s.URL)
+	return nil
+}
+
+// renderDigestPosts renders the digest statuses, split at item boundaries to fit in the character limit.
+func (c *MastodonClient) renderDigestPosts(digest Digest) ([]string, error) {
+	return c.digestTemplate.render(digest, c.fits)
+}
+
+// PostDigest posts the digest as a thread with the default post options.
+// The statuses are recorded with the digest ID, so that an interrupted thread is resumed.
+func (c *MastodonClient) PostDigest(ctx context.Context, digest Digest) error {
+	if len(digest.Items) == 0 {
+		return nil
+	}
+	statuses, err := c.renderDigestPosts(digest)
+	if err != nil {
+		return err
+	}
+	s, err := c.postThread(ctx, digest.postItem(), OutboxEntry{Kind: PostKindDigest, DigestID: digest.ID}, statuses, c.postOptions)
+	if err != nil {
+		pkgLogger.Error("Failed to post digest to Mastodon", "error", err)
+		return err
+	}
+	pkgLogger.Info("Successfully posted digest to Mastodon", "url", s.URL, "items", len(digest.Items), "statuses", len(statuses))
+	return nil
+}
+
+// mastodonMinScheduleDelay is the minimum delay of a scheduled status accepted by Mastodon.
+const mastodonMinScheduleDelay = 5 * time.Minute
+
+// ScheduleSummary posts the summary as a scheduled status published at the time and records the scheduled status.
+// Summaries that need replies, such as threads and variants, can't be scheduled.
+// Like the other statuses, the request is sent through the outbox with an idempotency key,
+// so that a retry after an interruption does not schedule the summary twice.
+func (c *MastodonClient) ScheduleSummary(ctx context.Context, item Item, summary SummarizeResult, at time.Time) error {
+	statuses, err := c.renderSummary(item, summary)
+	if err != nil {
+		return err
+	}
+	if len(statuses) > 1 || len(summary.Variants) > 0 {
+		return errCannotSchedule
+	}
+	if time.Until(at) < mastodonMinScheduleDelay {
+		return fmt.Errorf("%w: scheduled time %s is too soon", errCannotSchedule, at)
+	}
+
+	toot := c.postOptionsFor(item).toot(statuses[0], "")
+	toot.ScheduledAt = &at
+	if c.repository == nil {
+		if _, err := c.client.PostStatus(ctx, toot); err != nil {
+			return fmt.Errorf("failed to schedule status: %w", err)
+		}
+		return nil
+	}
+
+	entry := &OutboxEntry{ItemID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindScheduled}
+	if err := c.repository.PrepareOutbox(ctx, entry); err != nil {
+		return err
+	}
+	if time.Since(entry.CreatedAt) >= mastodonIdempotencyWindow {
+		resolved, err := c.resolveOutbox(ctx, item, entry)
+		if err != nil || resolved {
+			return err
+		}
+	}
+
+	// The response is a scheduled status, whose ID is not a status ID
+	s, err := c.client.PostStatus(withIdempotencyKey(ctx, entry.IdempotencyKey), toot)
+	if err != nil {
+		// The status may have been scheduled even if the request failed, so the entry is kept for the retry.
+		return fmt.Errorf("failed to schedule status: %w", err)
+	}
+	err = c.repository.CompleteScheduledOutbox(ctx, entry.ID, &ScheduledPost{
+		ItemID:      item.ID,
+		Publisher:   mastodonPublisherName,
+		ScheduledID: string(s.ID),
+		ScheduledAt: at,
+	})
+	if err != nil {
+		return err
+	}
+	pkgLogger.Info("Successfully scheduled summary on Mastodon", "url", item.URL, "scheduled_id", s.ID, "scheduled_at", at)
+	return nil
+}
+
+// mastodonScheduledResolveWindow is how long after the scheduled time the published status is searched for.
+const mastodonScheduledResolveWindow = 24 * time.Hour
+
+// ResolveScheduledPosts records the statuses published from the scheduled statuses as the summary statuses.
+// The published status is searched for in the recent statuses of the account by the item URL,
+// the same way as a status left in the outbox. A scheduled status not found within
+// mastodonScheduledResolveWindow after the scheduled time is no longer searched for.
+func (c *MastodonClient) ResolveScheduledPosts(ctx context.Context) error {
+	now := time.Now()
+	scheduledPosts, err := c.repository.GetUnresolvedScheduledPosts(ctx, mastodonPublisherName, now.Add(-mastodonScheduledResolveWindow), now)
+	if err != nil {
+		return err
+	}
+	for _, scheduled := range scheduledPosts {
+		item, err := c.repository.GetItemByID(ctx, scheduled.ItemID)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			continue
+		}
+		s, err := c.findPostedStatus(ctx, c.client, *item, &OutboxEntry{Kind: PostKindSummary, CreatedAt: scheduled.ScheduledAt})
+		if err != nil {
+			return fmt.Errorf("failed to find published status for item ID %d: %w", item.ID, err)
+		}
+		if s == nil {
+			pkgLogger.Warn("Published status of scheduled summary is not found yet", "url", item.URL, "scheduled_id", scheduled.ScheduledID, "scheduled_at", scheduled.ScheduledAt)
+			continue
+		}
+		err = c.repository.ResolveScheduledPost(ctx, scheduled, &PostRecord{
+			ItemID:    item.ID,
+			Publisher: mastodonPublisherName,
+			Kind:      PostKindSummary,
+			StatusID:  string(s.ID),
+			URL:       s.URL,
+		})
+		if err != nil {
+			return err
+		}
+		pkgLogger.Info("Recorded status published from scheduled summary", "url", s.URL, "scheduled_id", scheduled.ScheduledID)
+	}
+	return nil
+}
+
+// EditSummary edits the posted summary statuses to show the new summary.
+// Statuses no longer needed are deleted and additional ones are posted as replies.
+// The visibility can't be changed by editing, and the variants are left as they are.
+func (c *MastodonClient) EditSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	statuses, err := c.renderSummary(item, summary)
+	if err != nil {
+		return err
+	}
+	posted, err := c.repository.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindSummary)
+	if err != nil {
+		return err
+	}
+	if len(posted) == 0 {
+		return fmt.Errorf("no posted summary to edit for item ID %d", item.ID)
+	}
+
+	options := c.postOptionsFor(item)
+	editOptions := options
+	editOptions.visibility = ""
+	for _, p := range posted {
+		if p.Seq >= len(statuses) {
+			if err := c.deletePost(ctx, c.client, p); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := c.client.UpdateStatus(ctx, editOptions.toot(statuses[p.Seq], ""), mastodon.ID(p.StatusID)); err != nil {
+			return fmt.Errorf("failed to edit status %d/%d: %w", p.Seq+1, len(statuses), err)
+		}
+		pkgLogger.Debug("Edited status", "url", p.URL, "seq", p.Seq)
+	}
+	if _, err := c.postThread(ctx, item, OutboxEntry{Kind: PostKindSummary}, statuses, options); err != nil {
+		return err
+	}
+	pkgLogger.Info("Successfully edited summary on Mastodon", "url", posted[0].URL, "statuses", len(statuses))
+	return nil
+}
+
+// DeletePosts deletes all the statuses posted for the item, including the variants and the replies.
+// The digests are recorded with the digest ID, so they are not deleted.
+func (c *MastodonClient) DeletePosts(ctx context.Context, item Item) error {
+	posted, err := c.repository.GetPostsByPublisher(ctx, item.ID, mastodonPublisherName)
+	if err != nil {
+		return err
+	}
+	for _, p := range posted {
+		client, err := c.postClient(ctx, item, p.Kind, p.Seq, p.Variant)
+		if err != nil {
+			return err
+		}
+		if err := c.deletePost(ctx, client, p); err != nil {
+			return err
+		}
+	}
+	pkgLogger.Info("Successfully deleted posts on Mastodon", "url", item.URL, "statuses", len(posted))
+	return nil
+}
+
+// Reply posts the text as a reply to the first summary status, or to the no value or fallback status if there is no summary.
+func (c *MastodonClient) Reply(ctx context.Context, item Item, text string) error {
+	var parent *PostRecord
+	for _, kind := range []PostKind{PostKindSummary, PostKindNoValue, PostKindFallback} {
+		posted, err := c.repository.GetPosts(ctx, item.ID, mastodonPublisherName, kind)
+		if err != nil {
+			return err
+		}
+		if len(posted) > 0 {
+			parent = posted[0]
+			break
+		}
+	}
+	if parent == nil {
+		return fmt.Errorf("no posted status to reply to for item ID %d", item.ID)
+	}
+	replies, err := c.repository.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindReply)
+	if err != nil {
+		return err
+	}
+	seq := 0
+	if len(replies) > 0 {
+		seq = replies[len(replies)-1].Seq + 1
+	}
+
+	s, err := c.postStatus(ctx, c.client, item, PostKindReply, seq, c.postOptionsFor(item).toot(text, mastodon.ID(parent.StatusID)))
+	if err != nil {
+		return fmt.Errorf("failed to post reply: %w", err)
+	}
+	pkgLogger.Info("Successfully posted reply to Mastodon", "url", s.URL, "in_reply_to", parent.URL)
+	return nil
+}
+
+// deletePost deletes the status and its record. A status already deleted on the instance is treated as deleted.
+func (c *MastodonClient) deletePost(ctx context.Context, client *mastodon.Client, p *PostRecord) error {
+	err := client.DeleteStatus(ctx, mastodon.ID(p.StatusID))
+	var apiErr *mastodon.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		pkgLogger.Warn("Status is already deleted", "url", p.URL)
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete status %s: %w", p.StatusID, err)
+	}
+	pkgLogger.Debug("Deleted status", "url", p.URL, "kind", p.Kind, "seq", p.Seq)
+	return c.repository.DeletePost(ctx, p.ID)
+}
+
+// postClient returns the account that posted the status of the kind.
+// A variant is posted by the account of the variant recorded with the status.
+// If the variant is no longer configured, an error is returned instead of using another account,
+// because the status would not be found and be treated as deleted.
+func (c *MastodonClient) postClient(ctx context.Context, item Item, kind PostKind, seq int, variant string) (*mastodon.Client, error) {
+	if kind != PostKindVariant {
+		return c.client, nil
+	}
+	if variant == "" {
+		// The variant was not recorded before the variant column was added
+		return c.legacyVariantClient(ctx, item, seq)
+	}
+	for _, v := range c.variants {
+		if v.config.Name == variant {
+			return v.client, nil
+		}
+	}
+	return nil, fmt.Errorf("variant %s of item ID %d is not configured", variant, item.ID)
+}
+
+// legacyVariantClient returns the account that posted the variant with the index in the stored summary.
+func (c *MastodonClient) legacyVariantClient(ctx context.Context, item Item, index int) (*mastodon.Client, error) {
+	summary, err := c.repository.GetLatestSummary(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil || index >= len(summary.Variants) {
+		return c.client, nil
+	}
+	for _, v := range c.variants {
+		if v.config.Name == summary.Variants[index].Name {
+			return v.client, nil
+		}
+	}
+	return c.client, nil
+}
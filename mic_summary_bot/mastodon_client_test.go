@@ -0,0 +1,434 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMastodonClient_renderSummary(t *testing.T) {
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	summary := SummarizeResult{
+		FinalSummary: "最終要約。",
+		Documents: []DocumentSummary{
+			{URL: "https://www.soumu.go.jp/main_content/1.pdf", Label: "資料1", KeyPoints: []string{"要点A", "要点B"}},
+			{KeyPoints: []string{"要点C"}},
+		},
+	}
+
+	t.Run("single status", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 500
+		client, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+
+		statuses, err := client.renderSummary(item, summary)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"会議の開催\n最終要約。\nhttps://www.soumu.go.jp/menu_news/s-news/example.html\n"}, statuses)
+	})
+
+	t.Run("thread mode", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 500
+		config.Mastodon.ThreadMode = true
+		client, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+
+		statuses, err := client.renderSummary(item, summary)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"会議の開催\n最終要約。\nhttps://www.soumu.go.jp/menu_news/s-news/example.html",
+			"[1/2] 資料1\n・要点A\n・要点B\nhttps://www.soumu.go.jp/main_content/1.pdf",
+			// Falls back to the item URL when the document could not be matched
+			"[2/2] \n・要点C\nhttps://www.soumu.go.jp/menu_news/s-news/example.html",
+		}, statuses)
+	})
+}
+
+func TestMastodonClient_renderSummary_overflow(t *testing.T) {
+	item := Item{ID: 1, Title: "会議", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	summary := SummarizeResult{FinalSummary: "一文目です。二文目です。三文目です。"}
+
+	// "会議\n" (3) + URL (23) + "\n" * 2 leaves 12 characters for the summary
+	newClient := func(t *testing.T, overflow MastodonOverflowMode) *MastodonClient {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 40
+		config.Mastodon.Overflow = overflow
+		client, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("truncate", func(t *testing.T) {
+		statuses, err := newClient(t, MastodonOverflowTruncate).renderSummary(item, summary)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"会議\n一文目です。二文目です。\n" + item.URL + "\n"}, statuses)
+	})
+
+	t.Run("thread", func(t *testing.T) {
+		statuses, err := newClient(t, MastodonOverflowThread).renderSummary(item, summary)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"会議\n一文目です。二文目です。\n" + item.URL + "\n", "三文目です。"}, statuses)
+	})
+
+	t.Run("truncate in the middle of a sentence", func(t *testing.T) {
+		long := SummarizeResult{FinalSummary: "とても長い一文目の要約です。二文目。"}
+		statuses, err := newClient(t, MastodonOverflowTruncate).renderSummary(item, long)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "会議\nとても長い一文目の要約…\n"+item.URL+"\n", statuses[0])
+		assert.LessOrEqual(t, mastodonTextLength(statuses[0], 23), 40)
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 40
+		config.Mastodon.Overflow = "drop"
+		_, err := NewMastodonClient(config, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewMastodonClient_instanceLimits(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api/v1/instance", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"uri":"example.com","configuration":{"statuses":{"max_characters":1000,"characters_reserved_per_url":30}}}`)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	client, err := NewMastodonClient(config, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests, "the instance is read at startup")
+	assert.Equal(t, mastodonLimits{maxCharacters: 1000, charactersPerURL: 30}, client.limits)
+
+	t.Run("configured limit", func(t *testing.T) {
+		requests = 0
+		config.Mastodon.MaxCharacters = 400
+		client, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+		assert.Zero(t, requests)
+		assert.Equal(t, mastodonLimits{maxCharacters: 400, charactersPerURL: mastodonDefaultCharactersPerURL}, client.limits)
+	})
+
+	t.Run("not reported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"uri":"example.com"}`)
+		}))
+		defer server.Close()
+
+		config := DefaultConfig()
+		config.Mastodon.InstanceURL = server.URL
+		client, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+		assert.Equal(t, mastodonLimits{maxCharacters: mastodonDefaultMaxCharacters, charactersPerURL: mastodonDefaultCharactersPerURL}, client.limits)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		config := DefaultConfig()
+		config.Mastodon.InstanceURL = server.URL
+		_, err := NewMastodonClient(config, nil)
+		assert.ErrorContains(t, err, "max_characters")
+	})
+}
+
+func TestMastodonClient_postOptions(t *testing.T) {
+	sensitive := true
+	config := DefaultConfig()
+	config.Mastodon.MaxCharacters = 500
+	config.Mastodon.Visibility = "public"
+	config.Mastodon.Hashtags = HashtagConfig{
+		Static:             []string{"総務省"},
+		FromMeetingName:    true,
+		MeetingNamePattern: DefaultConfig().Mastodon.Hashtags.MeetingNamePattern,
+		Keywords:           []string{"５Ｇ"},
+	}
+	config.Mastodon.Rules = []PostRuleConfig{
+		{Name: "personnel", TitlePattern: "人事", Visibility: "private", SpoilerText: "人事", Sensitive: &sensitive},
+		{Name: "radio", URLPattern: "/01kiban", Hashtags: []string{"電波"}},
+	}
+	client, err := NewMastodonClient(config, nil)
+	require.NoError(t, err)
+
+	t.Run("default", func(t *testing.T) {
+		options := client.postOptionsFor(Item{Title: "報道資料", URL: "https://www.soumu.go.jp/menu_news/s-news/02.html"})
+		assert.Equal(t, mastodonPostOptions{visibility: "public", language: "ja", hashtags: []string{"総務省"}}, options)
+	})
+
+	t.Run("first matching rule", func(t *testing.T) {
+		options := client.postOptionsFor(Item{Title: "人事異動", URL: "https://www.soumu.go.jp/menu_news/s-news/01kiban.html"})
+		assert.Equal(t, mastodonPostOptions{visibility: "private", language: "ja", spoilerText: "人事", sensitive: true, hashtags: []string{"総務省"}}, options)
+	})
+
+	t.Run("hashtags in summary", func(t *testing.T) {
+		item := Item{Title: "電波政策部会（第3回）の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/01kiban.html"}
+		statuses, err := client.renderSummary(item, SummarizeResult{FinalSummary: "5Gの周波数を検討。"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{item.Title + "\n5Gの周波数を検討。\n" + item.URL + "\n#総務省 #電波 #電波政策部会 #5G"}, statuses)
+		// The rules are not changed by rendering
+		assert.Equal(t, []string{"総務省"}, client.postOptions.hashtags)
+	})
+
+	t.Run("invalid visibility", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 500
+		config.Mastodon.Rules = []PostRuleConfig{{Visibility: "everyone"}}
+		_, err := NewMastodonClient(config, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestMastodonClient_editDeleteReply(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	nextID := 100
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			nextID++
+			fmt.Fprintf(w, `{"id":"%d","url":"https://example.com/@bot/%d"}`, nextID, nextID)
+		case http.MethodPut:
+			fmt.Fprint(w, `{"id":"1"}`)
+		case http.MethodDelete:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	config.Mastodon.ThreadMode = true
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	summary := SummarizeResult{
+		FinalSummary: "最終要約。",
+		Documents:    []DocumentSummary{{URL: "https://www.soumu.go.jp/main_content/1.pdf", KeyPoints: []string{"要点"}}},
+	}
+	require.NoError(t, client.PostSummary(ctx, item, summary))
+	assert.Equal(t, []string{"POST /api/v1/statuses", "POST /api/v1/statuses"}, requests)
+
+	t.Run("edit", func(t *testing.T) {
+		requests = nil
+		// The new summary has no documents, so the reply is deleted
+		require.NoError(t, client.EditSummary(ctx, item, SummarizeResult{FinalSummary: "新しい要約。"}))
+		assert.Equal(t, []string{"PUT /api/v1/statuses/101", "DELETE /api/v1/statuses/102"}, requests)
+
+		posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindSummary)
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		assert.Equal(t, "101", posts[0].StatusID)
+	})
+
+	t.Run("reply", func(t *testing.T) {
+		requests = nil
+		require.NoError(t, client.Reply(ctx, item, "続報です。"))
+		require.NoError(t, client.Reply(ctx, item, "訂正です。"))
+		assert.Equal(t, []string{"POST /api/v1/statuses", "POST /api/v1/statuses"}, requests)
+
+		replies, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindReply)
+		require.NoError(t, err)
+		require.Len(t, replies, 2)
+		assert.Equal(t, []int{0, 1}, []int{replies[0].Seq, replies[1].Seq})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		requests = nil
+		require.NoError(t, client.DeletePosts(ctx, item))
+		assert.ElementsMatch(t, []string{"DELETE /api/v1/statuses/101", "DELETE /api/v1/statuses/103", "DELETE /api/v1/statuses/104"}, requests)
+
+		posts, err := repo.GetPostsByPublisher(ctx, item.ID, mastodonPublisherName)
+		require.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+}
+
+func TestMastodonClient_ScheduleSummary(t *testing.T) {
+	ctx := context.Background()
+	var scheduledAt, idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		scheduledAt = r.PostForm.Get("scheduled_at")
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"42","scheduled_at":"2025-06-02T07:00:00.000Z","params":{}}`)
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, client.ScheduleSummary(ctx, item, SummarizeResult{FinalSummary: "要約。"}, at))
+	assert.Equal(t, at.Format(time.RFC3339), scheduledAt)
+	assert.NotEmpty(t, idempotencyKey, "scheduled statuses are sent through the outbox")
+
+	scheduledPosts, err := repo.GetScheduledPosts(ctx, item.ID)
+	require.NoError(t, err)
+	require.Len(t, scheduledPosts, 1)
+	assert.Equal(t, "42", scheduledPosts[0].ScheduledID)
+	assert.True(t, at.Equal(scheduledPosts[0].ScheduledAt))
+	entries, err := repo.GetOutbox(ctx, mastodonPublisherName)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	t.Run("too soon", func(t *testing.T) {
+		err := client.ScheduleSummary(ctx, item, SummarizeResult{FinalSummary: "要約。"}, time.Now().Add(time.Minute))
+		assert.ErrorIs(t, err, errCannotSchedule)
+	})
+
+	t.Run("variants", func(t *testing.T) {
+		summary := SummarizeResult{FinalSummary: "要約。", Variants: []SummaryVariant{{Name: "en", Summary: "Summary."}}}
+		err := client.ScheduleSummary(ctx, item, summary, at)
+		assert.ErrorIs(t, err, errCannotSchedule)
+	})
+}
+
+func TestMastodonClient_DeletePosts_variantAccount(t *testing.T) {
+	ctx := context.Background()
+	newServer := func(requests *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests = append(*requests, r.Method+" "+r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"201","url":"https://example.com/@en/201"}`)
+		}))
+	}
+	var mainRequests, variantRequests []string
+	mainServer := newServer(&mainRequests)
+	defer mainServer.Close()
+	variantServer := newServer(&variantRequests)
+	defer variantServer.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = mainServer.URL
+	config.Mastodon.MaxCharacters = 500
+	config.Variants = []VariantConfig{{Name: "en", PostTemplate: "{{.Summary}}", PostMode: VariantPostAccount, Mastodon: &MastodonConfig{InstanceURL: variantServer.URL}}}
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	_, err = client.PostVariant(ctx, item, 0, SummaryVariant{Name: "en", Summary: "Summary."}, "", client.postOptionsFor(item))
+	require.NoError(t, err)
+	posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindVariant)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "en", posts[0].Variant)
+
+	// 保存した要約に別版がなくても、投稿時の別版のアカウントで削除する
+	require.NoError(t, client.DeletePosts(ctx, item))
+	assert.Equal(t, []string{"POST /api/v1/statuses", "DELETE /api/v1/statuses/201"}, variantRequests)
+	assert.Empty(t, mainRequests)
+
+	t.Run("variant no longer configured", func(t *testing.T) {
+		require.NoError(t, repo.AddPost(ctx, &PostRecord{ItemID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindVariant, Variant: "removed", StatusID: "202"}))
+		assert.ErrorContains(t, client.DeletePosts(ctx, item), "variant removed")
+		posts, err := repo.GetPostsByPublisher(ctx, item.ID, mastodonPublisherName)
+		require.NoError(t, err)
+		assert.Len(t, posts, 1, "the record is kept")
+	})
+}
+
+func TestMastodonClient_PostDigest_recordsDigestID(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"%d","url":"https://example.com/@bot/%d"}`, 300+len(requests), 300+len(requests))
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html", PublishedAt: time.Now().UTC()}
+	require.NoError(t, client.PostDigest(ctx, Digest{ID: 7, Items: []DigestItem{{Item: item, Kind: PostKindNoValue}}}))
+	posts, err := repo.GetDigestPosts(ctx, 7, mastodonPublisherName)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	posts, err = repo.GetPostsByPublisher(ctx, item.ID, mastodonPublisherName)
+	require.NoError(t, err)
+	assert.Empty(t, posts, "the digest is not recorded on its first item")
+
+	// アイテムの投稿を削除しても、ダイジェストは削除しない
+	require.NoError(t, client.DeletePosts(ctx, item))
+	assert.Equal(t, []string{"POST /api/v1/statuses"}, requests)
+}
+
+func TestMastodonClient_ResolveScheduledPosts(t *testing.T) {
+	ctx := context.Background()
+	item := &Item{URL: "https://www.soumu.go.jp/menu_news/s-news/example.html", Title: "会議の開催", Status: StatusProcessed}
+	scheduledAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts/verify_credentials":
+			fmt.Fprint(w, `{"id":"1"}`)
+		case "/api/v1/accounts/1/statuses":
+			fmt.Fprintf(w, `[{"id":"301","url":"https://example.com/@bot/301","in_reply_to_id":null,"created_at":%q,"content":"<p>会議の開催<br>%s</p>"}]`,
+				scheduledAt.Format(time.RFC3339), item.URL)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, repo.insert(ctx, item))
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.AddScheduledPost(ctx, &ScheduledPost{ItemID: item.ID, Publisher: mastodonPublisherName, ScheduledID: "42", ScheduledAt: scheduledAt}))
+	require.NoError(t, client.ResolveScheduledPosts(ctx))
+
+	// 公開されたステータスを要約の投稿として記録し、編集や削除の対象にする
+	posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "301", posts[0].StatusID)
+	scheduled, err := repo.GetScheduledPosts(ctx, item.ID)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, "301", scheduled[0].StatusID)
+
+	unresolved, err := repo.GetUnresolvedScheduledPosts(ctx, mastodonPublisherName, scheduledAt.Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, unresolved)
+}
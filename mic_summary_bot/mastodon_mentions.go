@@ -0,0 +1,186 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/net/html"
+)
+
+// mastodonNotificationsLimit is the number of notifications fetched at once.
+const mastodonNotificationsLimit = 40
+
+// IncomingMention is a status mentioning the bot account, received as a notification.
+type IncomingMention struct {
+	NotificationID string
+	StatusID       string
+	// AccountID is the ID of the sender on the bot's instance. Unlike the acct, it can't be changed or reused.
+	AccountID string
+	// Account is the acct of the sender: "user" for a local account, "user@example.com" for a remote one.
+	Account     string
+	InReplyToID string
+	// Text is the plain text of the status without the leading mentions.
+	Text       string
+	Visibility string
+	CreatedAt  time.Time
+}
+
+// GetMentions returns the mentions received after the notification sinceID, oldest first.
+// The notifications are paged with the min_id of the Link header until no more are returned,
+// so a backlog after downtime is read in full. If sinceID is empty, only the latest page is returned.
+func (c *MastodonClient) GetMentions(ctx context.Context, sinceID string) ([]*IncomingMention, error) {
+	var notifications []*mastodon.Notification
+	pg := &mastodon.Pagination{MinID: mastodon.ID(sinceID), Limit: mastodonNotificationsLimit}
+	for {
+		minID := pg.MinID
+		page, err := c.client.GetNotifications(ctx, pg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notifications: %w", err)
+		}
+		notifications = append(notifications, page...)
+		if len(page) == 0 || sinceID == "" || pg.MinID == "" || pg.MinID == minID {
+			break
+		}
+		// The Link header also sets max_id for the older page, which would limit the next request
+		pg = &mastodon.Pagination{MinID: pg.MinID, Limit: mastodonNotificationsLimit}
+	}
+
+	var mentions []*IncomingMention
+	for _, n := range notifications {
+		// go-mastodon can't filter the notification types, so the other types are skipped here
+		if n.Type != "mention" || n.Status == nil {
+			continue
+		}
+		inReplyTo := ""
+		if n.Status.InReplyToID != nil {
+			inReplyTo = fmt.Sprint(n.Status.InReplyToID)
+		}
+		mentions = append(mentions, &IncomingMention{
+			NotificationID: string(n.ID),
+			StatusID:       string(n.Status.ID),
+			AccountID:      string(n.Account.ID),
+			Account:        n.Account.Acct,
+			InReplyToID:    inReplyTo,
+			Text:           mentionText(n.Status.Content),
+			Visibility:     n.Status.Visibility,
+			CreatedAt:      n.CreatedAt,
+		})
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].CreatedAt.Before(mentions[j].CreatedAt)
+	})
+	return mentions, nil
+}
+
+// ReplyToMention posts the text as a reply to the mention, split into a thread if it exceeds the character limit.
+// Each status starts with the mention of the sender. A public mention is answered as unlisted
+// so that the answers do not fill the public timelines. It returns the ID of the first status.
+func (c *MastodonClient) ReplyToMention(ctx context.Context, item Item, mention *IncomingMention, text string) (string, error) {
+	answers, err := c.repository.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindAnswer)
+	if err != nil {
+		return "", err
+	}
+	seq := 0
+	if len(answers) > 0 {
+		seq = answers[len(answers)-1].Seq + 1
+	}
+
+	visibility := mention.Visibility
+	if visibility == "" || visibility == "public" {
+		visibility = "unlisted"
+	}
+	prefix := "@" + mention.Account + " "
+	chunks := splitText(text, c.limits.maxCharacters-c.textLength(prefix), c.textLength)
+
+	firstID := ""
+	replyTo := mastodon.ID(mention.StatusID)
+	for i, chunk := range chunks {
+		toot := &mastodon.Toot{
+			Status:      prefix + chunk,
+			InReplyToID: replyTo,
+			Visibility:  visibility,
+			Language:    c.postOptions.language,
+		}
+		s, err := c.postStatus(ctx, c.client, item, PostKindAnswer, seq+i, toot)
+		if err != nil {
+			return firstID, fmt.Errorf("failed to post answer %d/%d: %w", i+1, len(chunks), err)
+		}
+		if firstID == "" {
+			firstID = string(s.ID)
+		}
+		replyTo = s.ID
+	}
+	pkgLogger.Info("Successfully answered mention on Mastodon", "account", mention.Account, "item_url", item.URL, "statuses", len(chunks))
+	return firstID, nil
+}
+
+// SendDirectMessage posts the text as a direct reply to the mention, split into a thread if it exceeds the character limit.
+// The statuses are not related to any item, so they are not recorded and are posted without the outbox:
+// a message lost or duplicated after a failure is not resent or reconciled. The messages only report
+// the result of a command or a notification, so this is acceptable, unlike for the item posts.
+func (c *MastodonClient) SendDirectMessage(ctx context.Context, mention *IncomingMention, text string) error {
+	prefix := "@" + mention.Account + " "
+	chunks := splitText(text, c.limits.maxCharacters-c.textLength(prefix), c.textLength)
+
+	replyTo := mastodon.ID(mention.StatusID)
+	for i, chunk := range chunks {
+		s, err := c.client.PostStatus(ctx, &mastodon.Toot{
+			Status:      prefix + chunk,
+			InReplyToID: replyTo,
+			Visibility:  "direct",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to post direct message %d/%d: %w", i+1, len(chunks), err)
+		}
+		replyTo = s.ID
+	}
+	return nil
+}
+
+// SendDirectMessageToAccount sends the text as a direct message to the account with the ID on the bot's instance.
+// Unlike SendDirectMessage, the message is not a reply to a status.
+func (c *MastodonClient) SendDirectMessageToAccount(ctx context.Context, accountID string, text string) error {
+	account, err := c.client.GetAccount(ctx, mastodon.ID(accountID))
+	if err != nil {
+		return fmt.Errorf("failed to get account %s: %w", accountID, err)
+	}
+	return c.SendDirectMessage(ctx, &IncomingMention{AccountID: accountID, Account: account.Acct}, text)
+}
+
+// mentionText returns the plain text of the status content without the leading mentions.
+func mentionText(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			buf.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			buf.WriteString("\n")
+		case n.Type == html.ElementNode && n.Data == "p" && buf.Len() > 0:
+			buf.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	// Keep the line breaks of the question while dropping the leading mentions
+	text := strings.TrimSpace(buf.String())
+	for _, field := range strings.Fields(text) {
+		if !strings.HasPrefix(field, "@") {
+			break
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, field))
+	}
+	return text
+}
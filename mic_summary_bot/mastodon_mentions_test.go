@@ -0,0 +1,140 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMastodonClient_mentions(t *testing.T) {
+	ctx := context.Background()
+	var notificationQueries []url.Values
+	var toots []url.Values
+	nextID := 300
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/notifications":
+			notificationQueries = append(notificationQueries, r.URL.Query())
+			// Linkヘッダーの min_id で次のページをたどり、最後は空のページを返す
+			link := func(minID string) {
+				base := "http://" + r.Host + r.URL.Path
+				w.Header().Set("Link", fmt.Sprintf(`<%s?max_id=1>; rel="next", <%s?min_id=%s>; rel="prev"`, base, base, minID))
+			}
+			switch r.URL.Query().Get("min_id") {
+			case "9":
+				link("11")
+				fmt.Fprint(w, `[
+					{"id": "11", "type": "favourite", "created_at": "2026-10-18T01:01:00Z", "account": {"acct": "carol"}},
+					{"id": "10", "type": "mention", "created_at": "2026-10-18T01:00:00Z", "account": {"acct": "alice"},
+					 "status": {"id": "201", "in_reply_to_id": null, "visibility": "direct", "content": "<p>@bot こんにちは</p>"}}
+				]`)
+			case "11":
+				link("12")
+				fmt.Fprint(w, `[
+					{"id": "12", "type": "mention", "created_at": "2026-10-18T01:02:00Z", "account": {"id": "42", "acct": "bob@example.com"},
+					 "status": {"id": "202", "in_reply_to_id": "101", "visibility": "public", "content": "<p><span class=\"h-card\"><a href=\"https://example.com/@bot\">@<span>bot</span></a></span> 電波利用料は<br>どうなりましたか？</p>"}}
+				]`)
+			default:
+				fmt.Fprint(w, `[]`)
+			}
+		case "/api/v1/statuses":
+			body, _ := io.ReadAll(r.Body)
+			values, _ := url.ParseQuery(string(body))
+			toots = append(toots, values)
+			nextID++
+			fmt.Fprintf(w, `{"id":"%d","url":"https://example.com/@bot/%d"}`, nextID, nextID)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 50
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	mentions, err := client.GetMentions(ctx, "9")
+	require.NoError(t, err)
+	require.Len(t, notificationQueries, 3, "notifications are paged until an empty page")
+	assert.Equal(t, "11", notificationQueries[1].Get("min_id"), "the next page follows the Link header")
+	assert.Equal(t, "12", notificationQueries[2].Get("min_id"))
+	for _, query := range notificationQueries {
+		assert.Empty(t, query.Get("max_id"), "the next link of the Link header is not followed")
+	}
+	require.Len(t, mentions, 2)
+	assert.Equal(t, "10", mentions[0].NotificationID, "mentions are returned oldest first")
+	assert.Empty(t, mentions[0].InReplyToID)
+	assert.Equal(t, "こんにちは", mentions[0].Text)
+	mention := mentions[1]
+	assert.Equal(t, "bob@example.com", mention.Account)
+	assert.Equal(t, "42", mention.AccountID)
+	assert.Equal(t, "202", mention.StatusID)
+	assert.Equal(t, "101", mention.InReplyToID)
+	assert.Equal(t, "電波利用料は\nどうなりましたか？", mention.Text)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	answer := strings.Repeat("回答です。", 10)
+	firstID, err := client.ReplyToMention(ctx, item, mention, answer)
+	require.NoError(t, err)
+	assert.Equal(t, "301", firstID)
+
+	require.Len(t, toots, 2, "the answer exceeding the limit is split into a thread")
+	assert.Equal(t, "202", toots[0].Get("in_reply_to_id"))
+	assert.Equal(t, "301", toots[1].Get("in_reply_to_id"))
+	for _, toot := range toots {
+		assert.True(t, strings.HasPrefix(toot.Get("status"), "@bob@example.com "))
+		assert.Equal(t, "unlisted", toot.Get("visibility"), "public mentions are answered as unlisted")
+	}
+
+	posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindAnswer)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	post, err := repo.GetPostByStatusID(ctx, mastodonPublisherName, "302")
+	require.NoError(t, err)
+	require.NotNil(t, post, "a follow-up question to the answer resolves to the same item")
+	assert.Equal(t, item.ID, post.ItemID)
+
+	t.Run("direct message", func(t *testing.T) {
+		toots = nil
+		require.NoError(t, client.SendDirectMessage(ctx, mention, "稼働中"))
+		require.Len(t, toots, 1)
+		assert.Equal(t, "@bob@example.com 稼働中", toots[0].Get("status"))
+		assert.Equal(t, "direct", toots[0].Get("visibility"))
+		assert.Equal(t, "202", toots[0].Get("in_reply_to_id"))
+	})
+}
+
+func TestMentionBlocked(t *testing.T) {
+	config := &ResponderConfig{
+		BlockedAccounts: []string{"@Spammer@example.com", "troll"},
+		BlockedDomains:  []string{"spam.example"},
+	}
+	tests := []struct {
+		account string
+		want    bool
+	}{
+		{"spammer@example.com", true},
+		{"troll", true},
+		{"troll@example.com", false},
+		{"someone@spam.example", true},
+		{"someone@sub.spam.example", true},
+		{"someone@notspam.example", false},
+		{"alice", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, mentionBlocked(config, tt.account), tt.account)
+	}
+}
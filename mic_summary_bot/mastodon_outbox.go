@@ -0,0 +1,287 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+const (
+	// mastodonIdempotencyWindow is how long Mastodon remembers an Idempotency-Key.
+	// Retrying with the same key within this window returns the original status instead of posting it again.
+	mastodonIdempotencyWindow = time.Hour
+	// mastodonReconcileStatuses is the number of recent statuses searched for a status posted before an interruption.
+	mastodonReconcileStatuses = 40
+)
+
+type idempotencyKeyContextKey struct{}
+
+// withIdempotencyKey returns a context that makes idempotencyTransport send the key.
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// idempotencyTransport sets the Idempotency-Key header of POST requests from the request context.
+// go-mastodon does not support the header, so it is added at the transport.
+type idempotencyTransport struct {
+	base http.RoundTripper
+}
+
+func (t *idempotencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if key, ok := req.Context().Value(idempotencyKeyContextKey{}).(string); ok && key != "" && req.Method == http.MethodPost {
+		req = req.Clone(req.Context())
+		req.Header.Set("Idempotency-Key", key)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// postStatus posts the status through the outbox.
+// The status is recorded in the outbox before sending and moved to the posts table after sending,
+// so that a status whose result is unknown because of an interruption is not posted twice.
+// A retry within the idempotency window reuses the key; after that, recent statuses are searched instead.
+func (c *MastodonClient) postStatus(ctx context.Context, client *mastodon.Client, item Item, kind PostKind, seq int, toot *mastodon.Toot) (*mastodon.Status, error) {
+	return c.postOutboxEntry(ctx, client, item, &OutboxEntry{Kind: kind, Seq: seq}, toot)
+}
+
+// postOutboxEntry posts the status for the outbox entry. The kind, the sequence number and the variant are taken from entry.
+func (c *MastodonClient) postOutboxEntry(ctx context.Context, client *mastodon.Client, item Item, entry *OutboxEntry, toot *mastodon.Toot) (*mastodon.Status, error) {
+	if c.repository == nil {
+		return client.PostStatus(ctx, toot)
+	}
+
+	entry.ItemID = item.ID
+	entry.Publisher = mastodonPublisherName
+	entry.InReplyTo = string(toot.InReplyToID)
+	if err := c.repository.PrepareOutbox(ctx, entry); err != nil {
+		return nil, err
+	}
+	if time.Since(entry.CreatedAt) >= mastodonIdempotencyWindow {
+		s, err := c.findPostedStatus(ctx, client, item, entry)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			c.completeOutbox(ctx, item, entry, s)
+			return s, nil
+		}
+	}
+
+	s, err := client.PostStatus(withIdempotencyKey(ctx, entry.IdempotencyKey), toot)
+	if err != nil {
+		// The status may have been created even if the request failed, so the entry is kept for the retry.
+		return nil, err
+	}
+	c.completeOutbox(ctx, item, entry, s)
+	return s, nil
+}
+
+// completeOutbox records the posted status. Failures are only logged because the status is already public.
+// The entry left in the outbox is resolved by the next retry or reconciliation.
+func (c *MastodonClient) completeOutbox(ctx context.Context, item Item, entry *OutboxEntry, s *mastodon.Status) {
+	err := c.repository.CompleteOutbox(ctx, entry.ID, &PostRecord{
+		ItemID:    entry.ItemID,
+		DigestID:  entry.DigestID,
+		Publisher: mastodonPublisherName,
+		Kind:      entry.Kind,
+		Seq:       entry.Seq,
+		Variant:   entry.Variant,
+		StatusID:  string(s.ID),
+		URL:       s.URL,
+	})
+	if err != nil {
+		pkgLogger.Error("Failed to record published post", "publisher", mastodonPublisherName, "url", item.URL, "status_id", s.ID, "error", err)
+	}
+}
+
+// ReconcileOutbox resolves the statuses left in the outbox by an interrupted run.
+// A status found in the recent statuses of the account is recorded as posted.
+// An entry older than the idempotency window without a matching status is removed so that it is posted again;
+// newer entries are kept so that the retry reuses their idempotency key.
+func (c *MastodonClient) ReconcileOutbox(ctx context.Context) error {
+	entries, err := c.repository.GetOutbox(ctx, mastodonPublisherName)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		item, err := c.outboxItem(ctx, entry)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			pkgLogger.Warn("Removing outbox entry for unknown item", "item_id", entry.ItemID, "digest_id", entry.DigestID)
+			if err := c.repository.DeleteOutbox(ctx, entry.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resolved, err := c.resolveOutbox(ctx, *item, entry)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile outbox for item ID %d: %w", entry.ItemID, err)
+		}
+		switch {
+		case resolved:
+		case time.Since(entry.CreatedAt) >= mastodonIdempotencyWindow:
+			pkgLogger.Info("Status was not posted before interruption", "url", item.URL, "kind", entry.Kind, "seq", entry.Seq)
+			if err := c.repository.DeleteOutbox(ctx, entry.ID); err != nil {
+				return err
+			}
+		default:
+			pkgLogger.Info("Keeping outbox entry for retry with idempotency key", "url", item.URL, "kind", entry.Kind, "seq", entry.Seq)
+		}
+	}
+	return nil
+}
+
+// resolveOutbox looks for the status of the outbox entry sent before an interruption and records it if it is found.
+// For a scheduled summary, the scheduled statuses are searched first, and then the statuses already published from them.
+func (c *MastodonClient) resolveOutbox(ctx context.Context, item Item, entry *OutboxEntry) (bool, error) {
+	if entry.Kind == PostKindScheduled {
+		scheduled, err := c.findScheduledStatus(ctx, item)
+		if err != nil {
+			return false, err
+		}
+		if scheduled != nil {
+			pkgLogger.Info("Recovered status scheduled before interruption", "url", item.URL, "scheduled_id", scheduled.ID, "scheduled_at", scheduled.ScheduledAt)
+			err := c.repository.CompleteScheduledOutbox(ctx, entry.ID, &ScheduledPost{
+				ItemID:      item.ID,
+				Publisher:   mastodonPublisherName,
+				ScheduledID: string(scheduled.ID),
+				ScheduledAt: scheduled.ScheduledAt,
+			})
+			return err == nil, err
+		}
+	}
+
+	client, err := c.postClient(ctx, item, entry.Kind, entry.Seq, entry.Variant)
+	if err != nil {
+		return false, err
+	}
+	s, err := c.findPostedStatus(ctx, client, item, entry)
+	if err != nil || s == nil {
+		return false, err
+	}
+	pkgLogger.Info("Recovered status posted before interruption", "url", s.URL, "kind", entry.Kind, "seq", entry.Seq)
+	if entry.Kind == PostKindScheduled {
+		// The scheduled status has already been published, so it is recorded as the summary
+		published := *entry
+		published.Kind = PostKindSummary
+		entry = &published
+	}
+	c.completeOutbox(ctx, item, entry, s)
+	return true, nil
+}
+
+// mastodonScheduledStatus is a scheduled status returned by the scheduled statuses API.
+type mastodonScheduledStatus struct {
+	ID          mastodon.ID              `json:"id"`
+	ScheduledAt time.Time                `json:"scheduled_at"`
+	Params      mastodon.ScheduledParams `json:"params"`
+}
+
+// findScheduledStatus searches the scheduled statuses of the account for the summary of the item
+// that is not recorded in the scheduled_posts table yet.
+// go-mastodon does not support the scheduled statuses API, so the request is built here.
+func (c *MastodonClient) findScheduledStatus(ctx context.Context, item Item) (*mastodonScheduledStatus, error) {
+	u, err := url.Parse(c.client.Config.Server)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "/api/v1/scheduled_statuses")
+	u.RawQuery = url.Values{"limit": {strconv.Itoa(mastodonReconcileStatuses)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.client.Config.AccessToken)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled statuses: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get scheduled statuses: unexpected status %s", resp.Status)
+	}
+	var scheduledStatuses []*mastodonScheduledStatus
+	if err := json.NewDecoder(resp.Body).Decode(&scheduledStatuses); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled statuses: %w", err)
+	}
+
+	recorded, err := c.repository.GetScheduledPosts(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, scheduled := range scheduledStatuses {
+		if !strings.Contains(scheduled.Params.Text, item.URL) {
+			continue
+		}
+		if !slices.ContainsFunc(recorded, func(p *ScheduledPost) bool { return p.ScheduledID == string(scheduled.ID) }) {
+			return scheduled, nil
+		}
+	}
+	return nil, nil
+}
+
+// outboxItem returns the item of the outbox entry. For a digest, the item built from the digest is returned.
+func (c *MastodonClient) outboxItem(ctx context.Context, entry *OutboxEntry) (*Item, error) {
+	if entry.DigestID == 0 {
+		return c.repository.GetItemByID(ctx, entry.ItemID)
+	}
+	digest, err := c.repository.GetDigest(ctx, entry.DigestID)
+	if err != nil || digest == nil {
+		return nil, err
+	}
+	item := digest.postItem()
+	return &item, nil
+}
+
+// findPostedStatus searches the recent statuses of the account for the status of the outbox entry.
+// A reply is matched by the status it replies to, and other statuses by the item URL in the content.
+// Statuses already recorded in the posts table for any item or digest are ignored, and the oldest match is returned.
+func (c *MastodonClient) findPostedStatus(ctx context.Context, client *mastodon.Client, item Item, entry *OutboxEntry) (*mastodon.Status, error) {
+	account, err := client.GetAccountCurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	statuses, err := client.GetAccountStatuses(ctx, account.ID, &mastodon.Pagination{Limit: mastodonReconcileStatuses})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent statuses: %w", err)
+	}
+
+	var found *mastodon.Status
+	for _, s := range statuses {
+		if s.CreatedAt.Before(entry.CreatedAt.Add(-time.Minute)) {
+			continue
+		}
+		inReplyTo := ""
+		if s.InReplyToID != nil {
+			inReplyTo = fmt.Sprint(s.InReplyToID)
+		}
+		if inReplyTo != entry.InReplyTo {
+			continue
+		}
+		if entry.InReplyTo == "" && !strings.Contains(s.Content, item.URL) && !strings.Contains(s.Content, html.EscapeString(item.URL)) {
+			continue
+		}
+		recorded, err := c.repository.GetPostByStatusID(ctx, mastodonPublisherName, string(s.ID))
+		if err != nil {
+			return nil, err
+		}
+		if recorded != nil {
+			continue
+		}
+		// Statuses are returned newest first
+		found = s
+	}
+	return found, nil
+}
@@ -0,0 +1,182 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMastodonClient_postStatusIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	var keys []string
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if fail {
+			// The status is created but the response is lost
+			fail = false
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"101","url":"https://example.com/@bot/101"}`)
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	item := Item{ID: 1, Title: "会議の開催", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+	require.Error(t, client.PostNoValue(ctx, item, nil))
+	entries, err := repo.GetOutbox(ctx, mastodonPublisherName)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, client.PostNoValue(ctx, item, nil))
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+
+	entries, err = repo.GetOutbox(ctx, mastodonPublisherName)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindNoValue)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "101", posts[0].StatusID)
+}
+
+func TestMastodonClient_ReconcileOutbox(t *testing.T) {
+	ctx := context.Background()
+	item := &Item{URL: "https://www.soumu.go.jp/menu_news/s-news/example.html", Title: "会議の開催", Status: StatusPending}
+	now := time.Now().UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts/verify_credentials":
+			fmt.Fprint(w, `{"id":"1"}`)
+		case "/api/v1/accounts/1/statuses":
+			// Newest first. Only the summary was posted before the interruption.
+			fmt.Fprintf(w, `[{"id":"201","url":"https://example.com/@bot/201","in_reply_to_id":null,"created_at":%q,"content":"<p>会議の開催<br>%s</p>"},
+				{"id":"200","url":"https://example.com/@bot/200","in_reply_to_id":null,"created_at":%q,"content":"<p>別の投稿</p>"}]`,
+				now.Format(time.RFC3339), item.URL, now.Add(-time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, repo.insert(ctx, item))
+	item, err := repo.GetItemByURL(ctx, item.URL)
+	require.NoError(t, err)
+
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	summary := &OutboxEntry{ItemID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindSummary, Seq: 0}
+	require.NoError(t, repo.PrepareOutbox(ctx, summary))
+	reply := &OutboxEntry{ItemID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindSummary, Seq: 1, InReplyTo: "201"}
+	require.NoError(t, repo.PrepareOutbox(ctx, reply))
+
+	require.NoError(t, client.ReconcileOutbox(ctx))
+
+	posts, err := repo.GetPosts(ctx, item.ID, mastodonPublisherName, PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "201", posts[0].StatusID)
+
+	// The reply was not found but is kept so that the retry reuses the idempotency key
+	entries, err := repo.GetOutbox(ctx, mastodonPublisherName)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, reply.IdempotencyKey, entries[0].IdempotencyKey)
+}
+
+func TestMastodonClient_ReconcileOutbox_scheduled(t *testing.T) {
+	ctx := context.Background()
+	item := &Item{URL: "https://www.soumu.go.jp/menu_news/s-news/example.html", Title: "会議の開催", Status: StatusPending}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/scheduled_statuses":
+			fmt.Fprintf(w, `[{"id":"8","scheduled_at":"2025-06-02T07:00:00.000Z","params":{"text":"別の投稿"}},
+				{"id":"9","scheduled_at":"2025-06-02T08:00:00.000Z","params":{"text":"会議の開催\n%s"}}]`, item.URL)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, repo.insert(ctx, item))
+	item, err := repo.GetItemByURL(ctx, item.URL)
+	require.NoError(t, err)
+
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.PrepareOutbox(ctx, &OutboxEntry{ItemID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindScheduled}))
+	require.NoError(t, client.ReconcileOutbox(ctx))
+
+	scheduledPosts, err := repo.GetScheduledPosts(ctx, item.ID)
+	require.NoError(t, err)
+	require.Len(t, scheduledPosts, 1)
+	assert.Equal(t, "9", scheduledPosts[0].ScheduledID)
+	assert.Equal(t, time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), scheduledPosts[0].ScheduledAt.UTC())
+	entries, err := repo.GetOutbox(ctx, mastodonPublisherName)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMastodonClient_findPostedStatus_recordedForDigest(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	item := Item{ID: 1, URL: "https://www.soumu.go.jp/menu_news/s-news/example.html", Title: "会議の開催"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts/verify_credentials":
+			fmt.Fprint(w, `{"id":"1"}`)
+		case "/api/v1/accounts/1/statuses":
+			fmt.Fprintf(w, `[{"id":"201","url":"https://example.com/@bot/201","in_reply_to_id":null,"created_at":%q,"content":"<p>会議の開催<br>%s</p>"}]`,
+				now.Format(time.RFC3339), item.URL)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	client, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+
+	// The status is the digest with the same ID as the item, which also contains the item URL
+	require.NoError(t, repo.AddPost(ctx, &PostRecord{DigestID: item.ID, Publisher: mastodonPublisherName, Kind: PostKindDigest, StatusID: "201"}))
+	s, err := client.findPostedStatus(ctx, client.client, item, &OutboxEntry{ItemID: item.ID, Kind: PostKindSummary, CreatedAt: now})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,153 @@
+package micsummarybot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// mastodonAppName is the application name shown on the posts and in the authorized apps of the account.
+	mastodonAppName = "mic-summary-bot"
+	// mastodonAppWebsite is the website of the application.
+	mastodonAppWebsite = "https://github.com/kotet/mic-summary-bot"
+	// mastodonScopes are the scopes requested by the bot.
+	// Reading the account and its statuses is needed to reconcile the outbox after an interruption.
+	mastodonScopes = "read:accounts read:notifications read:statuses write:statuses"
+	// mastodonOOBRedirectURI makes the instance show the authorization code instead of redirecting.
+	mastodonOOBRedirectURI = "urn:ietf:wg:oauth:2.0:oob"
+)
+
+// MastodonApp is an application registered on a Mastodon instance.
+type MastodonApp struct {
+	InstanceURL  string
+	ClientID     string
+	ClientSecret string
+	// AuthorizationURL is the page where the user authorizes the application and gets the authorization code.
+	AuthorizationURL string
+}
+
+// RegisterMastodonApp registers the bot as an application on the instance.
+func RegisterMastodonApp(ctx context.Context, instanceURL string) (*MastodonApp, error) {
+	instanceURL = strings.TrimRight(instanceURL, "/")
+	app, err := mastodon.RegisterApp(ctx, &mastodon.AppConfig{
+		Server:       instanceURL,
+		ClientName:   mastodonAppName,
+		RedirectURIs: mastodonOOBRedirectURI,
+		Scopes:       mastodonScopes,
+		Website:      mastodonAppWebsite,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register application on %s: %w", instanceURL, err)
+	}
+	return &MastodonApp{
+		InstanceURL:      instanceURL,
+		ClientID:         app.ClientID,
+		ClientSecret:     app.ClientSecret,
+		AuthorizationURL: app.AuthURI,
+	}, nil
+}
+
+// AuthorizeMastodonApp exchanges the authorization code for an access token and verifies it.
+// It returns the access token and the account name of the authorized user.
+func AuthorizeMastodonApp(ctx context.Context, app *MastodonApp, code string) (accessToken string, account string, err error) {
+	client := mastodon.NewClient(&mastodon.Config{
+		Server:       app.InstanceURL,
+		ClientID:     app.ClientID,
+		ClientSecret: app.ClientSecret,
+	})
+	if err := client.AuthenticateToken(ctx, strings.TrimSpace(code), mastodonOOBRedirectURI); err != nil {
+		return "", "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	user, err := client.GetAccountCurrentUser(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to verify credentials: %w", err)
+	}
+	return client.Config.AccessToken, user.Acct, nil
+}
+
+// SaveMastodonCredentials writes the instance URL and the credentials into the mastodon section of the config file.
+// The rest of the file, including the comments, is kept as is. If the file does not exist, it is created from the example config.
+func SaveMastodonCredentials(configPath string, app *MastodonApp, accessToken string) error {
+	configYAML, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		configYAML = []byte(exampleConfig)
+	} else if err != nil {
+		return err
+	}
+
+	updated := setMastodonConfigValues(string(configYAML), [][2]string{
+		{"instance_url", app.InstanceURL},
+		{"access_token", accessToken},
+		{"client_id", app.ClientID},
+		{"client_secret", app.ClientSecret},
+	})
+
+	// Make sure that the edited file is still a valid config with the new values
+	config := DefaultConfig()
+	if err := yaml.UnmarshalStrict([]byte(updated), config); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	m := config.Mastodon
+	if m.InstanceURL != app.InstanceURL || m.AccessToken != accessToken || m.ClientID != app.ClientID || m.ClientSecret != app.ClientSecret {
+		return fmt.Errorf("failed to update config: mastodon credentials are not set as expected")
+	}
+
+	if err := os.WriteFile(configPath, []byte(updated), 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// setMastodonConfigValues sets the keys in the top-level mastodon section of the YAML text.
+// An existing line for the key, commented out or not, is replaced; otherwise the key is added at the top of the section.
+func setMastodonConfigValues(configYAML string, values [][2]string) string {
+	lines := strings.Split(configYAML, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, " ") == "mastodon:" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, "mastodon:", "")
+		start = len(lines) - 2
+	}
+
+	insertAt := start + 1
+	for _, kv := range values {
+		end := len(lines)
+		for i := start + 1; i < len(lines); i++ {
+			if lines[i] != "" && lines[i][0] != ' ' {
+				end = i
+				break
+			}
+		}
+
+		line := fmt.Sprintf("  %s: %q", kv[0], kv[1])
+		keyPattern := regexp.MustCompile(`^  #?\s*` + regexp.QuoteMeta(kv[0]) + `:`)
+		replaced := false
+		for i := start + 1; i < end; i++ {
+			if keyPattern.MatchString(lines[i]) {
+				lines[i] = line
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			lines = append(lines[:insertAt], append([]string{line}, lines[insertAt:]...)...)
+			insertAt++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,77 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMastodonSetup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/apps":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, mastodonScopes, r.PostForm.Get("scopes"))
+			assert.Equal(t, mastodonOOBRedirectURI, r.PostForm.Get("redirect_uris"))
+			fmt.Fprintf(w, `{"id":"1","client_id":"cid","client_secret":"csecret","redirect_uri":%q}`, mastodonOOBRedirectURI)
+		case "/oauth/token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "the-code", r.PostForm.Get("code"))
+			assert.Equal(t, "authorization_code", r.PostForm.Get("grant_type"))
+			fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer"}`)
+		case "/api/v1/accounts/verify_credentials":
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"id":"9","acct":"bot"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	app, err := RegisterMastodonApp(ctx, server.URL+"/")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, app.InstanceURL)
+	assert.Equal(t, "cid", app.ClientID)
+	assert.Contains(t, app.AuthorizationURL, server.URL+"/oauth/authorize?")
+	assert.Contains(t, app.AuthorizationURL, "client_id=cid")
+
+	token, account, err := AuthorizeMastodonApp(ctx, app, " the-code\n")
+	require.NoError(t, err)
+	assert.Equal(t, "token", token)
+	assert.Equal(t, "bot", account)
+
+	t.Run("update existing config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		original := "rss:\n  url: \"https://www.soumu.go.jp/news.rdf\"\nmastodon:\n  # 投稿先\n  instance_url: \"https://old.example.com\"\n  # access_token: \"\"\n  max_characters: 500\ngemini:\n  api_key: \"key\"\n"
+		require.NoError(t, os.WriteFile(path, []byte(original), 0600))
+
+		require.NoError(t, SaveMastodonCredentials(path, app, token))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "rss:\n  url: \"https://www.soumu.go.jp/news.rdf\"\nmastodon:\n"+
+			"  client_id: \"cid\"\n  client_secret: \"csecret\"\n"+
+			"  # 投稿先\n  instance_url: \""+server.URL+"\"\n  access_token: \"token\"\n  max_characters: 500\n"+
+			"gemini:\n  api_key: \"key\"\n", string(data))
+	})
+
+	t.Run("create config from example", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, SaveMastodonCredentials(path, app, token))
+		config, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, server.URL, config.Mastodon.InstanceURL)
+		assert.Equal(t, "token", config.Mastodon.AccessToken)
+		assert.Equal(t, "cid", config.Mastodon.ClientID)
+		assert.Equal(t, "csecret", config.Mastodon.ClientSecret)
+		assert.Equal(t, DefaultConfig().Mastodon.PostTemplate, config.Mastodon.PostTemplate)
+	})
+}
@@ -0,0 +1,105 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MentionResult はメンションへの対応結果を表す
+type MentionResult string
+
+const (
+	MentionAnswered    MentionResult = "answered"     // 回答した
+	MentionIgnored     MentionResult = "ignored"      // 投稿した要約への返信でない、または古いため回答しなかった
+	MentionBlocked     MentionResult = "blocked"      // ブロックリストに含まれるアカウントのため回答しなかった
+	MentionRateLimited MentionResult = "rate_limited" // 回答数の上限に達したため回答しなかった
+	MentionCommand     MentionResult = "command"      // 管理者のコマンドを実行した
+)
+
+// MentionRecord は mentions テーブルのレコードを表す構造体。
+// 受け取ったメンションは失敗した場合を除いて対応結果によらず記録し、同じメンションに2回対応しないようにする
+type MentionRecord struct {
+	NotificationID string
+	StatusID       string
+	// Account はメンションを送ったアカウント。ローカルのアカウントは "user"、リモートのアカウントは "user@example.com"
+	Account string
+	// ItemID は返信先の投稿のアイテム。投稿したアイテムへの返信でない場合は0
+	ItemID int
+	Result MentionResult
+	// ReplyStatusID は回答として投稿した最初のステータスのID。回答していない場合は空
+	ReplyStatusID string
+	CreatedAt     time.Time
+}
+
+// AddMention はメンションへの対応結果を記録します。記録済みの場合は何もしません。
+func (r *ItemRepository) AddMention(ctx context.Context, mention *MentionRecord) error {
+	insertSQL := formatQuery(`
+	INSERT INTO mentions (notification_id, status_id, account, item_id, result, reply_status_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (notification_id) DO NOTHING;
+	`)
+	if mention.CreatedAt.IsZero() {
+		mention.CreatedAt = time.Now().UTC()
+	}
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, insertSQL, mention.NotificationID, mention.StatusID, mention.Account, mention.ItemID, mention.Result, mention.ReplyStatusID, mention.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add mention %s: %w", mention.NotificationID, err)
+	}
+	return nil
+}
+
+// GetMention は通知IDからメンションの記録を返します。見つからない場合はnilを返します。
+func (r *ItemRepository) GetMention(ctx context.Context, notificationID string) (*MentionRecord, error) {
+	query := formatQuery(`
+	SELECT notification_id, status_id, account, item_id, result, reply_status_id, created_at
+	FROM mentions
+	WHERE notification_id = ?;
+	`)
+	m := &MentionRecord{}
+	err := r.db.QueryRowContext(ctx, query, notificationID).Scan(&m.NotificationID, &m.StatusID, &m.Account, &m.ItemID, &m.Result, &m.ReplyStatusID, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mention %s: %w", notificationID, err)
+	}
+	return m, nil
+}
+
+// GetLatestMentionNotificationID は最後に記録したメンションの通知IDを返します。記録がない場合は空文字列を返します。
+func (r *ItemRepository) GetLatestMentionNotificationID(ctx context.Context) (string, error) {
+	query := formatQuery(`
+	SELECT notification_id
+	FROM mentions
+	ORDER BY LENGTH(notification_id) DESC, notification_id DESC
+	LIMIT 1;
+	`)
+	var id string
+	err := r.db.QueryRowContext(ctx, query).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get latest mention: %w", err)
+	}
+	return id, nil
+}
+
+// CountAnsweredMentions は since 以降に回答したメンションの数を返します。account が空でない場合はそのアカウントへの回答のみ数えます。
+func (r *ItemRepository) CountAnsweredMentions(ctx context.Context, account string, since time.Time) (int, error) {
+	query := formatQuery(`
+	SELECT COUNT(*)
+	FROM mentions
+	WHERE result = ? AND created_at >= ? AND (? = '' OR account = ?);
+	`)
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, MentionAnswered, since.UTC(), account, account).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count answered mentions: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,54 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_Mentions(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	id, err := repo.GetLatestMentionNotificationID(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+
+	now := time.Now().UTC()
+	mentions := []*MentionRecord{
+		{NotificationID: "10", StatusID: "110", Account: "alice", ItemID: 1, Result: MentionAnswered, ReplyStatusID: "210", CreatedAt: now.Add(-2 * time.Hour)},
+		{NotificationID: "11", StatusID: "111", Account: "alice", ItemID: 1, Result: MentionAnswered, ReplyStatusID: "211", CreatedAt: now.Add(-time.Minute)},
+		{NotificationID: "12", StatusID: "112", Account: "bob@example.com", ItemID: 1, Result: MentionAnswered, ReplyStatusID: "212", CreatedAt: now},
+		{NotificationID: "13", StatusID: "113", Account: "alice", Result: MentionRateLimited, CreatedAt: now},
+	}
+	for _, m := range mentions {
+		require.NoError(t, repo.AddMention(ctx, m))
+	}
+	require.NoError(t, repo.AddMention(ctx, &MentionRecord{NotificationID: "10", Result: MentionIgnored}), "adding a mention twice is ignored")
+
+	m, err := repo.GetMention(ctx, "10")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, MentionAnswered, m.Result)
+	assert.Equal(t, "210", m.ReplyStatusID)
+
+	m, err = repo.GetMention(ctx, "99")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	id, err = repo.GetLatestMentionNotificationID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "13", id)
+
+	since := now.Add(-time.Hour)
+	count, err := repo.CountAnsweredMentions(ctx, "", since)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = repo.CountAnsweredMentions(ctx, "alice", since)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,199 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mentionMaxAge より古いメンションには回答しない。初回の実行や長く停止していた後に、古い質問にまとめて回答しないようにする
+const mentionMaxAge = time.Hour
+
+// RespondToMentions はMastodonのメンションを取得し、要約の投稿へのリプライで受けた質問に回答します。
+// 管理者からのDMはコマンドとして実行します。外部のスケジューラから数分おきに呼び出すことを想定しています。
+// 回答はアイテムの要約と、アーカイブした資料をもとに生成し、質問へのリプライとして投稿します。
+func (b *MICSummaryBot) RespondToMentions(ctx context.Context) (err error) {
+	defer func() {
+		if panicErr := handlePanic("RespondToMentions"); panicErr != nil {
+			err = panicErr
+		}
+	}()
+
+	if !(b.config.Responder.Enabled || b.config.Admin.Enabled) || !b.config.Mastodon.Enabled {
+		pkgLogger.Info("Responder is disabled")
+		return nil
+	}
+
+	sinceID, err := b.itemRepository.GetLatestMentionNotificationID(ctx)
+	if err != nil {
+		return err
+	}
+	mentions, err := b.mastodonClient.GetMentions(ctx, sinceID)
+	if err != nil {
+		return err
+	}
+	pkgLogger.Info("Fetched mentions", "count", len(mentions))
+
+	for _, mention := range mentions {
+		recorded, err := b.itemRepository.GetMention(ctx, mention.NotificationID)
+		if err != nil {
+			return err
+		}
+		if recorded != nil {
+			continue
+		}
+		// 失敗したメンションは記録せず、次回の実行でやり直す。以降のメンションを記録すると
+		// 通知の取得位置が失敗したメンションを越えるため、ここで処理を止める。
+		// 失敗が続いても mentionMaxAge を過ぎれば回答せずに記録するため、取得が止まり続けることはない
+		record, err := b.handleMention(ctx, mention)
+		if err != nil {
+			return fmt.Errorf("failed to respond to mention %s from %s: %w", mention.StatusID, mention.Account, err)
+		}
+		// ドライランでステータスを更新しない場合は記録せず、同じメンションで繰り返し試せるようにする
+		if b.config.DryRun.Enabled && !b.config.DryRun.UpdateStatus {
+			continue
+		}
+		if err := b.itemRepository.AddMention(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleMention は管理者からのDMをコマンドとして実行し、それ以外のメンションには質問として回答します。
+// 一時停止中は質問に回答しません。
+func (b *MICSummaryBot) handleMention(ctx context.Context, mention *IncomingMention) (*MentionRecord, error) {
+	if b.isAdminMessage(mention) {
+		return b.handleAdminMessage(ctx, mention)
+	}
+	if !b.config.Responder.Enabled || b.isPaused(ctx) {
+		return &MentionRecord{
+			NotificationID: mention.NotificationID,
+			StatusID:       mention.StatusID,
+			Account:        mention.Account,
+			Result:         MentionIgnored,
+		}, nil
+	}
+	return b.respondToMention(ctx, mention)
+}
+
+// respondToMention は1件のメンションに回答し、記録する内容を返します。
+// 回答しない場合も、その理由を Result に設定して返します。
+func (b *MICSummaryBot) respondToMention(ctx context.Context, mention *IncomingMention) (*MentionRecord, error) {
+	record := &MentionRecord{
+		NotificationID: mention.NotificationID,
+		StatusID:       mention.StatusID,
+		Account:        mention.Account,
+		Result:         MentionIgnored,
+	}
+
+	if mention.InReplyToID == "" || mention.Text == "" || time.Since(mention.CreatedAt) > mentionMaxAge {
+		return record, nil
+	}
+	if mentionBlocked(&b.config.Responder, mention.Account) {
+		pkgLogger.Info("Mention from blocked account", "account", mention.Account)
+		record.Result = MentionBlocked
+		return record, nil
+	}
+
+	post, err := b.itemRepository.GetPostByStatusID(ctx, mastodonPublisherName, mention.InReplyToID)
+	if err != nil {
+		return record, err
+	}
+	// ダイジェストは複数のアイテムをまとめた投稿のため回答しない
+	if post == nil || post.DigestID != 0 {
+		return record, nil
+	}
+	record.ItemID = post.ItemID
+
+	limited, err := b.mentionRateLimited(ctx, mention.Account)
+	if err != nil {
+		return record, err
+	}
+	if limited {
+		pkgLogger.Info("Mention rate limit reached", "account", mention.Account)
+		record.Result = MentionRateLimited
+		return record, nil
+	}
+
+	item, err := b.getItem(ctx, post.ItemID)
+	if err != nil {
+		return record, err
+	}
+	summary, err := b.itemRepository.GetLatestSummary(ctx, item.ID)
+	if err != nil {
+		return record, err
+	}
+	if summary == nil {
+		// 要約対象外のアイテムには資料の要約がないため回答しない
+		return record, nil
+	}
+
+	pkgLogger.Info("Start answering mention", "account", mention.Account, "url", item.URL)
+	answer, err := b.genAIClient.AnswerQuestion(ctx, *summary, mention.Text, b.config.Responder.Prompt)
+	if err != nil {
+		return record, err
+	}
+
+	if b.config.DryRun.Enabled {
+		pkgLogger.Info("Dry run: answer is not posted", "account", mention.Account, "question", mention.Text, "answer", answer)
+		record.Result = MentionAnswered
+		return record, nil
+	}
+	replyID, err := b.mastodonClient.ReplyToMention(ctx, *item, mention, answer)
+	if err != nil {
+		return record, err
+	}
+	record.Result = MentionAnswered
+	record.ReplyStatusID = replyID
+	return record, nil
+}
+
+// mentionRateLimited は直近1時間の回答数が、全体またはアカウントごとの上限に達しているかを返します。
+func (b *MICSummaryBot) mentionRateLimited(ctx context.Context, account string) (bool, error) {
+	since := time.Now().Add(-time.Hour)
+	limits := []struct {
+		account string
+		max     int
+	}{
+		{"", b.config.Responder.MaxRepliesPerHour},
+		{account, b.config.Responder.MaxRepliesPerAccountPerHour},
+	}
+	for _, limit := range limits {
+		if limit.max <= 0 {
+			continue
+		}
+		count, err := b.itemRepository.CountAnsweredMentions(ctx, limit.account, since)
+		if err != nil {
+			return false, fmt.Errorf("failed to check mention rate limit: %w", err)
+		}
+		if count >= limit.max {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mentionBlocked はアカウントがブロックリストに含まれるかを返します。
+// アカウントとドメインは大文字と小文字を区別せずに比較し、ドメインはサブドメインにも一致します。
+func mentionBlocked(config *ResponderConfig, account string) bool {
+	account = strings.ToLower(strings.TrimPrefix(account, "@"))
+	for _, blocked := range config.BlockedAccounts {
+		if account == strings.ToLower(strings.TrimPrefix(blocked, "@")) {
+			return true
+		}
+	}
+
+	_, domain, ok := strings.Cut(account, "@")
+	if !ok {
+		return false
+	}
+	for _, blocked := range config.BlockedDomains {
+		blocked = strings.ToLower(blocked)
+		if domain == blocked || strings.HasSuffix(domain, "."+blocked) {
+			return true
+		}
+	}
+	return false
+}
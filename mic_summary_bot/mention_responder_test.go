@@ -0,0 +1,61 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMICSummaryBot_RespondToMentions_failed(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/notifications" {
+			http.NotFound(w, r)
+			return
+		}
+		mention := func(id string, minutes int, inReplyTo string) string {
+			return fmt.Sprintf(`{"id": "%s", "type": "mention", "created_at": %q, "account": {"acct": "alice"},
+				"status": {"id": "2%s", "in_reply_to_id": %s, "visibility": "public", "content": "<p>@bot 質問です</p>"}}`,
+				id, now.Add(-time.Duration(minutes)*time.Minute).Format(time.RFC3339), id, inReplyTo)
+		}
+		fmt.Fprintf(w, `[%s, %s, %s]`, mention("12", 1, "null"), mention("11", 2, `"500"`), mention("10", 3, "null"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Mastodon.InstanceURL = server.URL
+	config.Mastodon.MaxCharacters = 500
+	config.Responder.Enabled = true
+	mastodonClient, err := NewMastodonClient(config, repo)
+	require.NoError(t, err)
+	bot := &MICSummaryBot{itemRepository: repo, mastodonClient: mastodonClient, config: config}
+
+	// 返信先の投稿のアイテムがないため、2件目のメンションへの回答に失敗する
+	require.NoError(t, repo.AddPost(ctx, &PostRecord{ItemID: 999, Publisher: mastodonPublisherName, Kind: PostKindSummary, StatusID: "500"}))
+
+	require.Error(t, bot.RespondToMentions(ctx))
+	recorded, err := repo.GetMention(ctx, "10")
+	require.NoError(t, err)
+	require.NotNil(t, recorded)
+	assert.Equal(t, MentionIgnored, recorded.Result)
+	// 失敗したメンションと以降のメンションは記録せず、次回の実行で通知の取得位置から取得し直す
+	for _, id := range []string{"11", "12"} {
+		recorded, err := repo.GetMention(ctx, id)
+		require.NoError(t, err)
+		assert.Nil(t, recorded, "mention %s is not recorded", id)
+	}
+	latest, err := repo.GetLatestMentionNotificationID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "10", latest)
+}
@@ -0,0 +1,231 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFS embed.FS
+
+// migration はデータベースのスキーマを1段階更新するマイグレーションを表す
+type migration struct {
+	Version int
+	Name    string
+	// up はマイグレーションを適用する。schema_migrations への記録と同じトランザクションで実行される
+	up func(ctx context.Context, tx *sql.Tx) error
+}
+
+// goMigrations はSQLでは書けない、データの変換などを行うマイグレーション。SQLiteとPostgreSQLの両方に適用されるため、共通のSQLで書く。
+// バージョンは migrations ディレクトリのSQLファイルと重複しないようにする
+var goMigrations []migration
+
+// MigrationStatus はマイグレーションの適用状況を表す
+type MigrationStatus struct {
+	Version int
+	Name    string
+	Applied bool
+	// AppliedAt は適用した時刻。適用していない場合はゼロ値
+	AppliedAt time.Time
+}
+
+const createSchemaMigrationsSQL = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+);`
+
+// loadMigrations はデータベースに対応する埋め込んだSQLファイルと goMigrations をバージョン順に並べて返します。
+// SQLファイルは migrations/<sqlite または postgres>/<バージョン>_<名前>.sql に置き、同じバージョンは同じ変更を表すようにします。
+func loadMigrations(dialect *sqlDialect) ([]migration, error) {
+	files, err := fs.Glob(migrationFS, path.Join("migrations", string(dialect.driver), "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := append([]migration(nil), goMigrations...)
+	for _, file := range files {
+		versionText, name, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+		version, err := strconv.Atoi(versionText)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+		content, err := migrationFS.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		migrations = append(migrations, sqlMigration(version, name, string(content)))
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// sqlMigration はSQLファイルの内容を実行するマイグレーションを返します。
+// SQLファイルは分割せずに1回の Exec で実行します。SQLiteのドライバーと lib/pq はどちらも、1回の Exec で複数の文を順に実行します。
+func sqlMigration(version int, name string, query string) migration {
+	return migration{
+		Version: version,
+		Name:    name,
+		up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, query)
+			return err
+		},
+	}
+}
+
+// appliedMigrations は適用済みのマイグレーションのバージョンと適用した時刻を返します。
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// migrate は未適用のマイグレーションをバージョン順に適用します。
+// マイグレーションごとにトランザクションを分け、失敗した場合はそのマイグレーションを適用せずにエラーを返します。
+// 複数のインスタンスが同時に起動した場合も、同じマイグレーションを2回適用しないよう、トランザクション内で適用済みかを確認します。
+func migrate(ctx context.Context, db *sql.DB, dialect *sqlDialect) error {
+	if _, err := db.ExecContext(ctx, formatQuery(createSchemaMigrationsSQL)); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		skipped := false
+		err := withTransaction(ctx, db, func(tx *sql.Tx) error {
+			if dialect.lockMigrationsSQL != "" {
+				if _, err := tx.ExecContext(ctx, dialect.lockMigrationsSQL); err != nil {
+					return err
+				}
+			}
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?;", m.Version).Scan(&count); err != nil {
+				return err
+			}
+			if count > 0 {
+				skipped = true
+				return nil
+			}
+			if err := m.up(ctx, tx); err != nil {
+				return err
+			}
+			insertSQL := "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);"
+			_, err := tx.ExecContext(ctx, insertSQL, m.Version, m.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if !skipped {
+			pkgLogger.Info("Applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+
+	if len(migrations) > 0 {
+		latest := migrations[len(migrations)-1].Version
+		for version := range applied {
+			if version > latest {
+				pkgLogger.Warn("Database has migrations unknown to this version", "version", version, "latest_known", latest)
+			}
+		}
+	}
+	return nil
+}
+
+// GetMigrationStatus はデータベースに対するマイグレーションの適用状況をバージョン順に返します。
+// マイグレーションは適用しません。SQLiteのデータベースが存在しない場合は、すべてのマイグレーションを未適用として返します。
+func GetMigrationStatus(ctx context.Context, config *DatabaseConfig) ([]MigrationStatus, error) {
+	dialect, err := databaseDialect(config)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	exists := true
+	if dialect == sqliteDialect {
+		if exists, err = databaseExists(config.Path); err != nil {
+			return nil, err
+		}
+	}
+	if exists {
+		db, err := openDatabase(config)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+
+		var count int
+		if err := db.QueryRowContext(ctx, dialect.tableExistsSQL, "schema_migrations").Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to check schema_migrations table: %w", err)
+		}
+		if count > 0 {
+			if applied, err = appliedMigrations(ctx, db); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// databaseExists はデータベースのファイルが存在するかを返します。インメモリのデータベースは常に存在しないものとします。
+func databaseExists(dbPath string) (bool, error) {
+	if dbPath == ":memory:" {
+		return false, nil
+	}
+	_, err := os.Stat(dbPath)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
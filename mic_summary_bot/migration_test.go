@@ -0,0 +1,151 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLMigration(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "database.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// トリガーの本体や文字列リテラルの中の ; を含むファイルも、分割せずにそのまま実行する
+	m := sqlMigration(1, "trigger", `-- comment;
+CREATE TABLE a (id INTEGER, b TEXT);
+CREATE TABLE log (b TEXT);
+CREATE TRIGGER t AFTER INSERT ON a
+BEGIN
+	INSERT INTO log (b) VALUES (new.b);
+	INSERT INTO log (b) VALUES ('after;');
+END;
+INSERT INTO a (id, b) VALUES (1, 'x;
+y');
+`)
+	require.NoError(t, withTransaction(ctx, db, func(tx *sql.Tx) error {
+		return m.up(ctx, tx)
+	}))
+
+	rows, err := db.Query("SELECT b FROM log;")
+	require.NoError(t, err)
+	defer rows.Close()
+	var logs []string
+	for rows.Next() {
+		var b string
+		require.NoError(t, rows.Scan(&b))
+		logs = append(logs, b)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"x;\ny", "after;"}, logs)
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	migrations, err := loadMigrations(sqliteDialect)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	t.Run("same versions for each database", func(t *testing.T) {
+		postgresMigrations, err := loadMigrations(postgresDialect)
+		require.NoError(t, err)
+		require.Len(t, postgresMigrations, len(migrations))
+		for i, m := range postgresMigrations {
+			assert.Equal(t, migrations[i].Version, m.Version)
+			assert.Equal(t, migrations[i].Name, m.Name)
+		}
+	})
+
+	t.Run("new database", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "db", "database.sqlite")
+		statuses, err := GetMigrationStatus(ctx, &DatabaseConfig{Path: dbPath})
+		require.NoError(t, err)
+		require.Len(t, statuses, len(migrations))
+		for _, s := range statuses {
+			assert.False(t, s.Applied)
+		}
+
+		repo, err := NewItemRepository(dbPath, 3)
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		statuses, err = GetMigrationStatus(ctx, &DatabaseConfig{Path: dbPath})
+		require.NoError(t, err)
+		for _, s := range statuses {
+			assert.True(t, s.Applied, "migration %d", s.Version)
+			assert.False(t, s.AppliedAt.IsZero())
+		}
+	})
+
+	t.Run("upgrade in place", func(t *testing.T) {
+		// マイグレーションを導入する前のデータベースには schema_migrations がない
+		dbPath := filepath.Join(t.TempDir(), "database.sqlite")
+		db, err := sql.Open("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`CREATE TABLE items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		published_at TIMESTAMP NOT NULL,
+		status INTEGER NOT NULL,
+		reason INTEGER NOT NULL,
+		retry_count INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		last_checked_at TIMESTAMP NOT NULL
+		);`)
+		require.NoError(t, err)
+		now := time.Now().UTC()
+		_, err = db.Exec("INSERT INTO items (url, title, published_at, status, reason, retry_count, created_at, last_checked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);",
+			"https://www.soumu.go.jp/1.html", "会議の開催", now, StatusProcessed, ReasonNone, 0, now, now)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		repo, err := NewItemRepository(dbPath, 3)
+		require.NoError(t, err)
+		defer repo.Close()
+		item, err := repo.GetItemByURL(ctx, "https://www.soumu.go.jp/1.html")
+		require.NoError(t, err)
+		require.NotNil(t, item, "existing items are kept")
+		assert.Equal(t, StatusProcessed, item.Status)
+	})
+
+	t.Run("failed migration is rolled back", func(t *testing.T) {
+		original := goMigrations
+		defer func() { goMigrations = original }()
+		goMigrations = append(goMigrations, migration{
+			Version: 9999,
+			Name:    "broken",
+			up: func(ctx context.Context, tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, "CREATE TABLE broken (id INTEGER);"); err != nil {
+					return err
+				}
+				return errors.New("broken migration")
+			},
+		})
+
+		dbPath := filepath.Join(t.TempDir(), "database.sqlite")
+		_, err := NewItemRepository(dbPath, 3)
+		require.Error(t, err)
+
+		statuses, err := GetMigrationStatus(ctx, &DatabaseConfig{Path: dbPath})
+		require.NoError(t, err)
+		last := statuses[len(statuses)-1]
+		assert.Equal(t, 9999, last.Version)
+		assert.False(t, last.Applied)
+		assert.True(t, statuses[0].Applied, "earlier migrations stay applied")
+
+		db, err := sql.Open("sqlite", dbPath)
+		require.NoError(t, err)
+		defer db.Close()
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'broken';").Scan(&count))
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,140 @@
+-- 初期のスキーマ。SQLiteの 0001_initial_schema と同じテーブルを、PostgreSQLの型で作成する
+
+CREATE TABLE IF NOT EXISTS items (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	published_at TIMESTAMPTZ NOT NULL,
+	status INTEGER NOT NULL,
+	reason INTEGER NOT NULL,
+	retry_count INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	last_checked_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_items_url ON items(url);
+CREATE INDEX IF NOT EXISTS idx_items_status_published_at ON items(status, published_at);
+CREATE INDEX IF NOT EXISTS idx_items_status_last_checked_at ON items(status, last_checked_at);
+
+CREATE TABLE IF NOT EXISTS downloaded_files (
+	url TEXT PRIMARY KEY,
+	sha256 TEXT NOT NULL,
+	path TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	content_type TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	last_modified TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	last_accessed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_downloaded_files_sha256 ON downloaded_files(sha256);
+CREATE INDEX IF NOT EXISTS idx_downloaded_files_last_accessed_at ON downloaded_files(last_accessed_at);
+
+CREATE TABLE IF NOT EXISTS summaries (
+	id BIGSERIAL PRIMARY KEY,
+	item_id BIGINT NOT NULL,
+	final_summary TEXT NOT NULL,
+	result_json TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_summaries_item_id_created_at ON summaries(item_id, created_at);
+
+CREATE TABLE IF NOT EXISTS summary_variants (
+	summary_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	summary TEXT NOT NULL,
+	PRIMARY KEY (summary_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+	id BIGSERIAL PRIMARY KEY,
+	item_id BIGINT NOT NULL,
+	digest_id BIGINT NOT NULL,
+	publisher TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	variant TEXT NOT NULL,
+	status_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_item_digest_publisher_kind_seq ON posts(item_id, digest_id, publisher, kind, seq);
+CREATE INDEX IF NOT EXISTS idx_posts_publisher_status_id ON posts(publisher, status_id);
+
+CREATE TABLE IF NOT EXISTS screening_results (
+	id BIGSERIAL PRIMARY KEY,
+	item_id BIGINT NOT NULL,
+	final_result TEXT NOT NULL,
+	model TEXT NOT NULL,
+	result_json TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_screening_results_item_id_created_at ON screening_results(item_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_screening_results_final_result_created_at ON screening_results(final_result, created_at);
+
+CREATE TABLE IF NOT EXISTS deliveries (
+	item_id BIGINT NOT NULL,
+	digest_id BIGINT NOT NULL,
+	publisher TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (item_id, digest_id, publisher, kind)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	item_id BIGINT NOT NULL,
+	digest_id BIGINT NOT NULL,
+	publisher TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	variant TEXT NOT NULL,
+	idempotency_key TEXT NOT NULL,
+	in_reply_to TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_item_digest_publisher_kind_seq ON outbox(item_id, digest_id, publisher, kind, seq);
+
+CREATE TABLE IF NOT EXISTS scheduled_posts (
+	item_id BIGINT NOT NULL,
+	publisher TEXT NOT NULL,
+	scheduled_id TEXT NOT NULL,
+	scheduled_at TIMESTAMPTZ NOT NULL,
+	status_id TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (item_id, publisher)
+);
+
+CREATE TABLE IF NOT EXISTS digests (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	posted_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS digest_items (
+	item_id BIGINT NOT NULL,
+	kind TEXT NOT NULL,
+	digest_id BIGINT,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (item_id, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_digest_items_digest_id ON digest_items(digest_id);
+
+CREATE TABLE IF NOT EXISTS mentions (
+	notification_id TEXT PRIMARY KEY,
+	status_id TEXT NOT NULL,
+	account TEXT NOT NULL,
+	item_id BIGINT NOT NULL,
+	result TEXT NOT NULL,
+	reply_status_id TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_account_created_at ON mentions(account, created_at);
+
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
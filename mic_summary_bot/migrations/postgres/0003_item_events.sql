@@ -0,0 +1,14 @@
+-- アイテムのステータスの遷移を、処理の段階と失敗した場合のエラーとともに記録する
+
+CREATE TABLE IF NOT EXISTS item_events (
+	id BIGSERIAL PRIMARY KEY,
+	item_id BIGINT NOT NULL,
+	from_status INTEGER NOT NULL,
+	to_status INTEGER NOT NULL,
+	reason INTEGER NOT NULL,
+	retry_count INTEGER NOT NULL,
+	stage TEXT NOT NULL,
+	error_message TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_item_events_item_id_created_at ON item_events(item_id, created_at);
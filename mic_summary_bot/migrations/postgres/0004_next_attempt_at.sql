@@ -0,0 +1,3 @@
+-- 先送りしたアイテムを、理由と再試行の回数に応じた間隔をあけてから再び処理する
+ALTER TABLE items ADD COLUMN next_attempt_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_items_status_next_attempt_at ON items(status, next_attempt_at);
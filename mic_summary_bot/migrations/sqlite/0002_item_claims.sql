@@ -0,0 +1,2 @@
+-- 処理中のアイテムを他のインスタンスが取得しないよう、取得してから一定時間は取得済みとして扱う
+ALTER TABLE items ADD COLUMN claimed_until TIMESTAMP;
@@ -0,0 +1,107 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// misskeyDefaultMaxCharacters is the default maximum length of a note on Misskey.
+const misskeyDefaultMaxCharacters = 3000
+
+// MisskeyClient is a publisher for Misskey.
+type MisskeyClient struct {
+	name          string
+	instanceURL   string
+	accessToken   string
+	visibility    string
+	maxCharacters int
+	templates     *postTemplates
+	repository    PostStore
+	httpClient    *http.Client
+}
+
+type misskeyCreateNoteRequest struct {
+	I          string `json:"i"`
+	Text       string `json:"text"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type misskeyCreateNoteResponse struct {
+	CreatedNote struct {
+		ID string `json:"id"`
+	} `json:"createdNote"`
+}
+
+func newMisskeyClient(config PublisherConfig, templates *postTemplates, repository PostStore, httpClient *http.Client) *MisskeyClient {
+	maxCharacters := config.MaxCharacters
+	if maxCharacters == 0 {
+		maxCharacters = misskeyDefaultMaxCharacters
+	}
+	return &MisskeyClient{
+		name:          config.Name,
+		instanceURL:   strings.TrimSuffix(config.URL, "/"),
+		accessToken:   config.AccessToken,
+		visibility:    config.Visibility,
+		maxCharacters: maxCharacters,
+		templates:     templates,
+		repository:    repository,
+		httpClient:    httpClient,
+	}
+}
+
+func (c *MisskeyClient) Name() string {
+	return c.name
+}
+
+// PostSummary posts the summary as a note.
+func (c *MisskeyClient) PostSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	text, err := c.templates.renderSummary(item, summary, c.maxCharacters, utf8.RuneCountInString)
+	if err != nil {
+		return err
+	}
+	return c.createNote(ctx, item, PostKindSummary, text)
+}
+
+// PostNoValue posts a predefined message for items deemed not valuable.
+func (c *MisskeyClient) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	text, err := c.templates.renderNoValue(item, screening)
+	if err != nil {
+		return err
+	}
+	return c.createNote(ctx, item, PostKindNoValue, text)
+}
+
+// PostFallback posts the text for an item given up without a summary.
+func (c *MisskeyClient) PostFallback(ctx context.Context, item Item, text string) error {
+	return c.createNote(ctx, item, PostKindFallback, text)
+}
+
+func (c *MisskeyClient) renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error) {
+	text, err := c.templates.renderSummary(item, summary, c.maxCharacters, utf8.RuneCountInString)
+	return []string{text}, err
+}
+
+func (c *MisskeyClient) renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error) {
+	text, err := c.templates.renderNoValue(item, screening)
+	return []string{text}, err
+}
+
+func (c *MisskeyClient) createNote(ctx context.Context, item Item, kind PostKind, text string) error {
+	var resp misskeyCreateNoteResponse
+	err := postJSON(ctx, c.httpClient, c.instanceURL+"/api/notes/create", nil, misskeyCreateNoteRequest{
+		I:          c.accessToken,
+		Text:       text,
+		Visibility: c.visibility,
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("failed to create note on Misskey: %w", err)
+	}
+
+	url := c.instanceURL + "/notes/" + resp.CreatedNote.ID
+	recordPublishedPost(ctx, c.repository, item, c.name, kind, 0, resp.CreatedNote.ID, url)
+	pkgLogger.Info("Successfully posted to Misskey", "publisher", c.name, "url", url)
+	return nil
+}
@@ -0,0 +1,142 @@
+package micsummarybot
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// OutboxEntry は outbox テーブルのレコードを表す構造体。
+// 送信を始めてから投稿を posts テーブルに記録するまでの間だけ存在し、
+// 残っている場合は送信できたかどうか分からない投稿を表す
+type OutboxEntry struct {
+	ID     int64
+	ItemID int
+	// DigestID はダイジェストの投稿の場合にダイジェストのID。ItemID は0になる
+	DigestID  int
+	Publisher string
+	Kind      PostKind
+	Seq       int
+	// Variant は別版の投稿の場合に別版の名前
+	Variant string
+	// IdempotencyKey は送信時に Idempotency-Key ヘッダーとして付ける値。再送しても同じ値を使う
+	IdempotencyKey string
+	// InReplyTo はリプライ先のステータスID。リプライでない場合は空
+	InReplyTo string
+	CreatedAt time.Time
+}
+
+// PrepareOutbox は送信しようとしている投稿を記録します。
+// 同じ投稿の記録が既に残っている場合は、その冪等キーと作成日時を entry に設定して再利用します。
+func (r *ItemRepository) PrepareOutbox(ctx context.Context, entry *OutboxEntry) error {
+	selectSQL := formatQuery(`
+	SELECT id, idempotency_key, created_at
+	FROM outbox
+	WHERE item_id = ? AND digest_id = ? AND publisher = ? AND kind = ? AND seq = ?;
+	`)
+	updateSQL := formatQuery(`
+	UPDATE outbox
+	SET in_reply_to = ?, variant = ?
+	WHERE id = ?;
+	`)
+	insertSQL := formatQuery(`
+	INSERT INTO outbox (item_id, digest_id, publisher, kind, seq, variant, idempotency_key, in_reply_to, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, selectSQL, entry.ItemID, entry.DigestID, entry.Publisher, entry.Kind, entry.Seq).Scan(&entry.ID, &entry.IdempotencyKey, &entry.CreatedAt)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, updateSQL, entry.InReplyTo, entry.Variant, entry.ID)
+			return err
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		entry.IdempotencyKey = key
+		entry.CreatedAt = time.Now().UTC()
+		return tx.QueryRowContext(ctx, insertSQL, entry.ItemID, entry.DigestID, entry.Publisher, entry.Kind, entry.Seq, entry.Variant, entry.IdempotencyKey, entry.InReplyTo, entry.CreatedAt).Scan(&entry.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare outbox for item ID %d: %w", entry.ItemID, err)
+	}
+	return nil
+}
+
+// CompleteOutbox は送信した投稿を posts テーブルに記録し、outbox から削除します。
+// 2つの操作は同じトランザクションで行うため、どちらか一方だけが反映されることはありません。
+func (r *ItemRepository) CompleteOutbox(ctx context.Context, entryID int64, post *PostRecord) error {
+	deleteSQL := formatQuery(`
+	DELETE FROM outbox
+	WHERE id = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		if err := addPost(ctx, tx, post); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, deleteSQL, entryID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete outbox ID %d: %w", entryID, err)
+	}
+	return nil
+}
+
+// DeleteOutbox は outbox の記録を削除します。送信されていないことが確認できた場合に使います。
+func (r *ItemRepository) DeleteOutbox(ctx context.Context, entryID int64) error {
+	deleteSQL := formatQuery(`
+	DELETE FROM outbox
+	WHERE id = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, deleteSQL, entryID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox ID %d: %w", entryID, err)
+	}
+	return nil
+}
+
+// GetOutbox は投稿先の outbox に残っている記録を古い順に返します。
+func (r *ItemRepository) GetOutbox(ctx context.Context, publisher string) ([]*OutboxEntry, error) {
+	query := formatQuery(`
+	SELECT id, item_id, digest_id, publisher, kind, seq, variant, idempotency_key, in_reply_to, created_at
+	FROM outbox
+	WHERE publisher = ?
+	ORDER BY created_at ASC, id ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, publisher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox for %s: %w", publisher, err)
+	}
+	defer rows.Close()
+
+	var entries []*OutboxEntry
+	for rows.Next() {
+		entry := &OutboxEntry{}
+		if err := rows.Scan(&entry.ID, &entry.ItemID, &entry.DigestID, &entry.Publisher, &entry.Kind, &entry.Seq, &entry.Variant, &entry.IdempotencyKey, &entry.InReplyTo, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
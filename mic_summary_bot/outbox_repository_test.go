@@ -0,0 +1,46 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_Outbox(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	entry := &OutboxEntry{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 0}
+	require.NoError(t, repo.PrepareOutbox(ctx, entry))
+	assert.NotEmpty(t, entry.IdempotencyKey)
+	assert.False(t, entry.CreatedAt.IsZero())
+
+	// Preparing the same post again reuses the key
+	retry := &OutboxEntry{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 0, InReplyTo: "5"}
+	require.NoError(t, repo.PrepareOutbox(ctx, retry))
+	assert.Equal(t, entry.ID, retry.ID)
+	assert.Equal(t, entry.IdempotencyKey, retry.IdempotencyKey)
+
+	other := &OutboxEntry{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 1}
+	require.NoError(t, repo.PrepareOutbox(ctx, other))
+	assert.NotEqual(t, entry.IdempotencyKey, other.IdempotencyKey)
+
+	entries, err := repo.GetOutbox(ctx, "mastodon")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "5", entries[0].InReplyTo)
+
+	require.NoError(t, repo.CompleteOutbox(ctx, entry.ID, &PostRecord{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 0, StatusID: "10", URL: "https://example.com/10"}))
+	posts, err := repo.GetPosts(ctx, 1, "mastodon", PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "10", posts[0].StatusID)
+
+	require.NoError(t, repo.DeleteOutbox(ctx, other.ID))
+	entries, err = repo.GetOutbox(ctx, "mastodon")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
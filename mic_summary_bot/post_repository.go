@@ -0,0 +1,165 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostKind は投稿の種類を表す
+type PostKind string
+
+const (
+	PostKindSummary   PostKind = "summary"   // 要約の投稿。スレッドの場合はseqが1以降のものがリプライ
+	PostKindNoValue   PostKind = "no_value"  // 要約対象外の投稿
+	PostKindVariant   PostKind = "variant"   // 別版の要約の投稿。seqは SummarizeResult.Variants のインデックス
+	PostKindReply     PostKind = "reply"     // 投稿後に追加したリプライ。seqは追加した順の番号
+	PostKindDigest    PostKind = "digest"    // ダイジェストの投稿。item_id は0で、digest_id にダイジェストのIDを記録する
+	PostKindAnswer    PostKind = "answer"    // メンションで受けた質問への回答。seqは回答した順の番号
+	PostKindFallback  PostKind = "fallback"  // 再試行をあきらめたアイテムの、要約の代わりの投稿
+	PostKindScheduled PostKind = "scheduled" // 予約投稿として送信する要約。outbox にだけ記録し、予約できたら scheduled_posts に移す
+)
+
+// PostRecord は posts テーブルのレコードを表す構造体
+type PostRecord struct {
+	ID     int
+	ItemID int
+	// DigestID はダイジェストの投稿の場合にダイジェストのID。ItemID は0になる。アイテムの投稿では0
+	DigestID  int
+	Publisher string
+	Kind      PostKind
+	Seq       int
+	// Variant は別版の投稿の場合に別版の名前。削除などで投稿したアカウントを決めるのに使う
+	Variant   string
+	StatusID  string
+	URL       string
+	CreatedAt time.Time
+}
+
+// AddPost は投稿したステータスを記録します。
+func (r *ItemRepository) AddPost(ctx context.Context, post *PostRecord) error {
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		return addPost(ctx, tx, post)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add post for item ID %d: %w", post.ItemID, err)
+	}
+	return nil
+}
+
+func addPost(ctx context.Context, tx *sql.Tx, post *PostRecord) error {
+	insertSQL := formatQuery(`
+	INSERT INTO posts (item_id, digest_id, publisher, kind, seq, variant, status_id, url, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`)
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now().UTC()
+	}
+	_, err := tx.ExecContext(ctx, insertSQL, post.ItemID, post.DigestID, post.Publisher, post.Kind, post.Seq, post.Variant, post.StatusID, post.URL, post.CreatedAt)
+	return err
+}
+
+// GetPosts は指定したアイテムの投稿をseq順に返します。
+func (r *ItemRepository) GetPosts(ctx context.Context, itemID int, publisher string, kind PostKind) ([]*PostRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, digest_id, publisher, kind, seq, variant, status_id, url, created_at
+	FROM posts
+	WHERE item_id = ? AND publisher = ? AND kind = ?
+	ORDER BY seq ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, itemID, publisher, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts for item ID %d: %w", itemID, err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetPostsByPublisher は指定したアイテムの、投稿先ごとのすべての種類の投稿を返します。
+func (r *ItemRepository) GetPostsByPublisher(ctx context.Context, itemID int, publisher string) ([]*PostRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, digest_id, publisher, kind, seq, variant, status_id, url, created_at
+	FROM posts
+	WHERE item_id = ? AND publisher = ?
+	ORDER BY kind ASC, seq ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, itemID, publisher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts for item ID %d: %w", itemID, err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetDigestPosts は指定したダイジェストの投稿をseq順に返します。
+func (r *ItemRepository) GetDigestPosts(ctx context.Context, digestID int, publisher string) ([]*PostRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, digest_id, publisher, kind, seq, variant, status_id, url, created_at
+	FROM posts
+	WHERE digest_id = ? AND publisher = ? AND kind = ?
+	ORDER BY seq ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, digestID, publisher, PostKindDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts for digest ID %d: %w", digestID, err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetPostByStatusID は投稿先のステータスIDから投稿の記録を返します。見つからない場合はnilを返します。
+func (r *ItemRepository) GetPostByStatusID(ctx context.Context, publisher string, statusID string) (*PostRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, digest_id, publisher, kind, seq, variant, status_id, url, created_at
+	FROM posts
+	WHERE publisher = ? AND status_id = ?
+	LIMIT 1;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, publisher, statusID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post by status ID %s: %w", statusID, err)
+	}
+	defer rows.Close()
+
+	posts, err := scanPosts(rows)
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	return posts[0], nil
+}
+
+// DeletePost は投稿の記録を削除します。投稿を削除した場合や、編集で不要になった場合に使います。
+func (r *ItemRepository) DeletePost(ctx context.Context, id int) error {
+	deleteSQL := formatQuery(`
+	DELETE FROM posts
+	WHERE id = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, deleteSQL, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete post ID %d: %w", id, err)
+	}
+	return nil
+}
+
+func scanPosts(rows *sql.Rows) ([]*PostRecord, error) {
+	var posts []*PostRecord
+	for rows.Next() {
+		post := &PostRecord{}
+		if err := rows.Scan(&post.ID, &post.ItemID, &post.DigestID, &post.Publisher, &post.Kind, &post.Seq, &post.Variant, &post.StatusID, &post.URL, &post.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,95 @@
+package micsummarybot
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// splitText はテキストを1件あたりlengthで数えてlimit文字以下の複数のテキストに分割します。
+// できるだけ行単位で分割し、1行がlimit文字を超える場合のみ行の途中で分割します。
+// limitが0以下の場合は分割しません。
+func splitText(text string, limit int, length func(string) int) []string {
+	text = strings.TrimSpace(text)
+	if limit <= 0 || length(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current []string
+	currentLen := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(strings.Join(current, "\n")); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current = nil
+		currentLen = 0
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		lineLen := length(line)
+		// 改行の分を含めて収まるか判定する
+		if len(current) > 0 && currentLen+1+lineLen > limit {
+			flush()
+		}
+		for lineLen > limit {
+			head, rest := cutText(line, limit, length)
+			chunks = append(chunks, head)
+			line = rest
+			lineLen = length(line)
+		}
+		if len(current) > 0 {
+			currentLen++
+		}
+		current = append(current, line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return chunks
+}
+
+// cutText はテキストの先頭からlengthで数えてlimit文字以下に収まる最長の部分と残りを返します。
+// 1文字も収まらない場合も、先頭の1文字は切り出します。
+func cutText(text string, limit int, length func(string) int) (string, string) {
+	runes := []rune(text)
+	n := 1
+	for n < len(runes) && length(string(runes[:n+1])) <= limit {
+		n++
+	}
+	return string(runes[:n]), string(runes[n:])
+}
+
+var (
+	// mastodonURLPattern はMastodonが文字数を固定長で数えるURLにマッチする
+	mastodonURLPattern = regexp.MustCompile(`https?://[^\s]+`)
+	// mastodonMentionPattern はドメイン付きのメンションにマッチする。Mastodonはドメイン部分を文字数に数えない
+	mastodonMentionPattern = regexp.MustCompile(`@(\w+)@[\w.-]+\w`)
+	// sentenceEndPattern は文の終わりにマッチする。出典番号 [1] などは直前の文に含める
+	sentenceEndPattern = regexp.MustCompile(`。(\[[^\]]*\])*`)
+)
+
+// mastodonTextLength はMastodonと同様の方法でテキストの文字数を数えます。
+// URLはcharactersPerURL文字として数え、メンションのドメイン部分は数えません。
+func mastodonTextLength(text string, charactersPerURL int) int {
+	length := 0
+	for _, url := range mastodonURLPattern.FindAllString(text, -1) {
+		length += charactersPerURL - utf8.RuneCountInString(url)
+	}
+	text = mastodonMentionPattern.ReplaceAllString(text, "@$1")
+	return length + utf8.RuneCountInString(text)
+}
+
+// splitSentences はテキストを「。」で文に分割します。区切りの「。」と直後の出典番号は前の文に含めます。
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEndPattern.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, text[start:loc[1]])
+		start = loc[1]
+	}
+	if rest := text[start:]; strings.TrimSpace(rest) != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
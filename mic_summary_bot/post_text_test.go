@@ -0,0 +1,89 @@
+package micsummarybot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "fits in one status",
+			text:     "タイトル\n要約\nhttps://example.com",
+			limit:    500,
+			expected: []string{"タイトル\n要約\nhttps://example.com"},
+		},
+		{
+			name:     "no limit",
+			text:     strings.Repeat("あ", 1000),
+			limit:    0,
+			expected: []string{strings.Repeat("あ", 1000)},
+		},
+		{
+			name:     "split at line boundaries",
+			text:     "あいう\nえおか\nきくけ",
+			limit:    7,
+			expected: []string{"あいう\nえおか", "きくけ"},
+		},
+		{
+			name:     "split long line",
+			text:     "あいうえおかきくけこ\nさし",
+			limit:    4,
+			expected: []string{"あいうえ", "おかきく", "けこ", "さし"},
+		},
+		{
+			name:     "drop empty chunks",
+			text:     "あいう\n\n\nえおか",
+			limit:    3,
+			expected: []string{"あいう", "えおか"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := splitText(tc.text, tc.limit, utf8.RuneCountInString)
+			assert.Equal(t, tc.expected, chunks)
+			if tc.limit > 0 {
+				for _, chunk := range chunks {
+					assert.LessOrEqual(t, utf8.RuneCountInString(chunk), tc.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitText_urlWeighted(t *testing.T) {
+	// URLは実際の長さにかかわらず23文字として数える
+	length := func(text string) int { return mastodonTextLength(text, 23) }
+	url := "https://www.soumu.go.jp/menu_news/s-news/example.html"
+
+	chunks := splitText("あいう\n"+url+"\nえお", 28, length)
+	assert.Equal(t, []string{"あいう\n" + url, "えお"}, chunks)
+
+	chunks = splitText("あいう\n"+url+"\nえお", 26, length)
+	assert.Equal(t, []string{"あいう", url + "\nえお"}, chunks)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, length(chunk), 26)
+	}
+}
+
+func TestMastodonTextLength(t *testing.T) {
+	assert.Equal(t, 3, mastodonTextLength("あいう", 23))
+	assert.Equal(t, 4+23, mastodonTextLength("リンク\nhttps://www.soumu.go.jp/menu_news/s-news/example.html", 23))
+	// Mastodon does not count the domain of a mention
+	assert.Equal(t, 10, mastodonTextLength("@bot@example.com こんにちは", 23))
+}
+
+func TestSplitSentences(t *testing.T) {
+	assert.Equal(t, []string{"一文目。", "二文目。[1]", "三文目"}, splitSentences("一文目。二文目。[1]三文目"))
+	assert.Equal(t, []string{"一文目。[1][2, p.3]", "二文目。"}, splitSentences("一文目。[1][2, p.3]二文目。"))
+	assert.Nil(t, splitSentences(""))
+}
@@ -0,0 +1,138 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// NewPostgresItemRepository はPostgreSQLのデータベースに接続し、未適用のマイグレーションを適用します。
+// 複数のBotのインスタンスが同じデータベースを共有できます。
+func NewPostgresItemRepository(dsn string, maxDeferredRetryCount int) (*ItemRepository, error) {
+	db, err := openPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to verify database connection: %w", err)
+	}
+
+	if err := migrate(context.Background(), db, postgresDialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &ItemRepository{db: db, dialect: postgresDialect, maxDeferredRetryCount: maxDeferredRetryCount}, nil
+}
+
+// openPostgres はPostgreSQLのデータベースを開きます。クエリのプレースホルダー ? は送信時に置き換えます。
+func openPostgres(dsn string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PostgreSQL DSN: %w", err)
+	}
+	return sql.OpenDB(postgresConnector{connector}), nil
+}
+
+// rebindPostgres はクエリのプレースホルダー ? を、PostgreSQLの $1, $2, ... に置き換えます。
+// 文字列リテラル、引用符で囲んだ識別子とコメントの中の ? は置き換えません。
+func rebindPostgres(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var buf strings.Builder
+	n := 0
+	for i := 0; i < len(query); {
+		end := i + 1
+		switch {
+		case query[i] == '\'' || query[i] == '"':
+			end = skipPast(query, i+1, query[i:i+1])
+		case strings.HasPrefix(query[i:], "--"):
+			end = skipPast(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			end = skipPast(query, i+2, "*/")
+		case query[i] == '?':
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			i++
+			continue
+		}
+		buf.WriteString(query[i:end])
+		i = end
+	}
+	return buf.String()
+}
+
+// skipPast は query の start 以降で最初に現れる terminator の直後の位置を返します。見つからない場合は query の長さを返します。
+func skipPast(query string, start int, terminator string) int {
+	if j := strings.Index(query[start:], terminator); j >= 0 {
+		return start + j + len(terminator)
+	}
+	return len(query)
+}
+
+// postgresConnector はリポジトリのクエリをPostgreSQLのプレースホルダーに置き換えて実行する接続を作成する
+type postgresConnector struct {
+	*pq.Connector
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresConn{conn: conn}, nil
+}
+
+// postgresConn は lib/pq の接続に、プレースホルダーの置き換えを加える。
+// lib/pq の接続が実装している database/sql/driver のインターフェースをそのまま委譲する
+type postgresConn struct {
+	conn driver.Conn
+}
+
+func (c *postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(rebindPostgres(query))
+}
+
+func (c *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.(driver.ConnPrepareContext).PrepareContext(ctx, rebindPostgres(query))
+}
+
+func (c *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, rebindPostgres(query), args)
+}
+
+func (c *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, rebindPostgres(query), args)
+}
+
+func (c *postgresConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *postgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *postgresConn) Ping(ctx context.Context) error {
+	return c.conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *postgresConn) ResetSession(ctx context.Context) error {
+	return c.conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *postgresConn) IsValid() bool {
+	return c.conn.(driver.Validator).IsValid()
+}
+
+func (c *postgresConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,401 @@
+package micsummarybot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rivo/uniseg"
+)
+
+// Publisher is a destination for the summaries.
+type Publisher interface {
+	// Name identifies the publisher. It is recorded with the delivery status, so it must be unique.
+	Name() string
+	// PostSummary posts the summary of the item.
+	PostSummary(ctx context.Context, item Item, summary SummarizeResult) error
+	// PostNoValue posts a message for an item deemed not valuable. screening may be nil.
+	PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error
+}
+
+// SummaryEditor is implemented by publishers that can edit a posted summary in place.
+type SummaryEditor interface {
+	// EditSummary replaces the posted summary of the item with the new summary.
+	EditSummary(ctx context.Context, item Item, summary SummarizeResult) error
+}
+
+// PostDeleter is implemented by publishers that can delete their posts.
+type PostDeleter interface {
+	// DeletePosts deletes all the posts of the item.
+	DeletePosts(ctx context.Context, item Item) error
+}
+
+// PostReplier is implemented by publishers that can reply to their posts.
+type PostReplier interface {
+	// Reply posts the text as a reply to the first post of the item.
+	Reply(ctx context.Context, item Item, text string) error
+}
+
+// FallbackPublisher is implemented by publishers that can post a plain text for an item given up without a summary.
+type FallbackPublisher interface {
+	// PostFallback posts the text rendered with the fallback template of the retry config.
+	PostFallback(ctx context.Context, item Item, text string) error
+}
+
+// SummaryScheduler is implemented by publishers that can schedule a post to be published later.
+type SummaryScheduler interface {
+	// ScheduleSummary schedules the summary to be published at the time and records the scheduled post.
+	// It returns errCannotSchedule if the summary can't be published as a scheduled post.
+	ScheduleSummary(ctx context.Context, item Item, summary SummarizeResult, at time.Time) error
+	// ResolveScheduledPosts records the posts published from the scheduled posts,
+	// so that they can be edited, deleted and replied to like the other posts.
+	ResolveScheduledPosts(ctx context.Context) error
+}
+
+// DigestPublisher is implemented by publishers that can post a digest of several items.
+// Items deemed not valuable are gathered into the digest instead of being posted one by one to these publishers.
+type DigestPublisher interface {
+	// PostDigest posts the digest. Posts are recorded with the digest ID.
+	PostDigest(ctx context.Context, digest Digest) error
+}
+
+// errCannotSchedule is returned by ScheduleSummary when the summary needs more than one post,
+// since replies can't be scheduled before the post they reply to is published.
+var errCannotSchedule = errors.New("summary can't be posted as a scheduled post")
+
+// publisherHTTPTimeout is the timeout for the requests sent by the publishers other than Mastodon.
+const publisherHTTPTimeout = 30 * time.Second
+
+// NewPublishers creates the publishers configured in the config, including Mastodon if it is enabled.
+// In the dry-run mode, each publisher is wrapped so that the posts are written instead of sent.
+func NewPublishers(config *Config, repository Storage, mastodonClient *MastodonClient) ([]Publisher, error) {
+	var publishers []Publisher
+	if config.Mastodon.Enabled {
+		publishers = append(publishers, mastodonClient)
+	}
+
+	httpClient := &http.Client{Timeout: publisherHTTPTimeout}
+	for _, pc := range config.Publishers {
+		if pc.Name == "" {
+			pc.Name = string(pc.Type)
+		}
+		templates, err := newPostTemplates(pc, &config.Mastodon, config.RSS.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		var publisher Publisher
+		switch pc.Type {
+		case PublisherMisskey:
+			publisher = newMisskeyClient(pc, templates, repository, httpClient)
+		case PublisherBluesky:
+			publisher = newBlueskyClient(pc, templates, repository, httpClient)
+		case PublisherSlack, PublisherDiscord:
+			publisher = newChatWebhookPublisher(pc, templates, httpClient)
+		case PublisherWebhook:
+			publisher = newWebhookPublisher(pc, templates, httpClient)
+		default:
+			return nil, fmt.Errorf("unknown publisher type %q for publisher %s", pc.Type, pc.Name)
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	if config.DryRun.Enabled {
+		for i, p := range publishers {
+			dryRun, err := newDryRunPublisher(p, &config.DryRun)
+			if err != nil {
+				return nil, err
+			}
+			publishers[i] = dryRun
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, p := range publishers {
+		if names[p.Name()] {
+			return nil, fmt.Errorf("duplicate publisher name %s", p.Name())
+		}
+		names[p.Name()] = true
+	}
+	return publishers, nil
+}
+
+// postTemplates holds the templates for a publisher.
+type postTemplates struct {
+	summary  *template.Template
+	noValue  *template.Template
+	feedName string
+}
+
+// newPostTemplates parses the templates of the publisher, falling back to the Mastodon templates.
+// The templates are executed with sample data to report errors such as unknown fields early.
+func newPostTemplates(pc PublisherConfig, mastodon *MastodonConfig, feedName string) (*postTemplates, error) {
+	summaryTemplate := pc.PostTemplate
+	if summaryTemplate == "" {
+		summaryTemplate = mastodon.PostTemplate
+	}
+	noValueTemplate := pc.NoValuePostTemplate
+	if noValueTemplate == "" {
+		noValueTemplate = mastodon.NoValuePostTemplate
+	}
+
+	t, err := parsePostTemplate("post", summaryTemplate)
+	if err == nil {
+		err = validatePostTemplate(t, samplePostInfo())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid post template for publisher %s: %w", pc.Name, err)
+	}
+	noValueT, err := parsePostTemplate("no_value_post", noValueTemplate)
+	if err == nil {
+		err = validatePostTemplate(noValueT, samplePostInfo())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid no value post template for publisher %s: %w", pc.Name, err)
+	}
+	return &postTemplates{summary: t, noValue: noValueT, feedName: feedName}, nil
+}
+
+// newPostInfo returns the template data for the summary.
+func newPostInfo(feedName string, item Item, summary SummarizeResult) PostInfo {
+	citedSummary, sources := buildCitations(item.URL, summary)
+	info := PostInfo{
+		Title:        item.Title,
+		Summary:      summary.FinalSummary,
+		URL:          item.URL,
+		CitedSummary: citedSummary,
+		Sources:      sources,
+		Extra:        summary.Extra,
+		PublishedAt:  item.PublishedAt,
+		FeedName:     feedName,
+	}
+	for _, doc := range summary.Documents {
+		url := doc.URL
+		if url == "" {
+			url = item.URL
+		}
+		info.Documents = append(info.Documents, PostDocument{
+			Label:     doc.Label,
+			URL:       url,
+			Summary:   doc.Summary,
+			KeyPoints: doc.KeyPoints,
+			Metadata:  doc.Metadata,
+		})
+		info.KeyPoints = append(info.KeyPoints, doc.KeyPoints...)
+		if info.Metadata == "" {
+			info.Metadata = doc.Metadata
+		}
+	}
+	return info
+}
+
+// newNoValuePostInfo returns the template data for an item deemed not valuable.
+// screening may be nil if the screening result is not available.
+func newNoValuePostInfo(feedName string, item Item, screening *ScreeningResult) PostInfo {
+	return PostInfo{
+		Title:           item.Title,
+		URL:             item.URL,
+		PublishedAt:     item.PublishedAt,
+		FeedName:        feedName,
+		ScreeningReason: screeningReason(screening),
+	}
+}
+
+// screeningReason joins the thoughts of the criteria that led to the final result of the screening.
+func screeningReason(screening *ScreeningResult) string {
+	if screening == nil {
+		return ""
+	}
+	var reasons []string
+	for _, criterion := range screening.Criteria {
+		if criterion.Result == screening.FinalResult && criterion.Thoughts != "" {
+			reasons = append(reasons, criterion.Thoughts)
+		}
+	}
+	return strings.Join(reasons, " ")
+}
+
+// renderSummary renders the summary so that it fits in limit characters counted by length.
+// If the rendered text is too long, trailing sentences of the summary are dropped at "。" as in
+// the Mastodon statuses. If even the first sentence does not fit, the summary is truncated with an ellipsis.
+// A limit of 0 or less means no limit.
+func (t *postTemplates) renderSummary(item Item, summary SummarizeResult, limit int, length func(string) int) (string, error) {
+	info := newPostInfo(t.feedName, item, summary)
+	text, err := executeTemplate(t.summary, info)
+	if err != nil || limit <= 0 || length(text) <= limit {
+		return text, err
+	}
+
+	// The text gets shorter as the summary is shortened, so the longest summary that fits is
+	// binary-searched. The text is rendered for each candidate because the template may repeat the summary.
+	var renderErr error
+	fits := func(shortened PostInfo) bool {
+		if renderErr != nil {
+			return false
+		}
+		var candidate string
+		candidate, renderErr = executeTemplate(t.summary, shortened)
+		return renderErr == nil && length(candidate) <= limit
+	}
+
+	summaries := splitSentences(info.Summary)
+	cited := splitSentences(info.CitedSummary)
+	withSentences := func(k int) PostInfo {
+		shortened := info
+		shortened.Summary = strings.Join(summaries[:min(k, len(summaries))], "")
+		shortened.CitedSummary = strings.Join(cited[:min(k, len(cited))], "")
+		return shortened
+	}
+	// k is the number of sentences that fit, searched from 1 to all but the last sentence
+	sentences := max(len(summaries), len(cited))
+	k := sort.Search(max(sentences-1, 0), func(i int) bool { return !fits(withSentences(i + 1)) })
+	if renderErr != nil {
+		return "", renderErr
+	}
+	if k > 0 {
+		return executeTemplate(t.summary, withSentences(k))
+	}
+
+	// Even the first sentence does not fit. The summary is cut at grapheme boundaries
+	// so that emoji and combining characters are not broken.
+	summaryLength := uniseg.GraphemeClusterCount(info.Summary)
+	citedLength := uniseg.GraphemeClusterCount(info.CitedSummary)
+	truncated := func(n int) PostInfo {
+		shortened := info
+		shortened.Summary = truncateGraphemes(info.Summary, summaryLength-n)
+		shortened.CitedSummary = truncateGraphemes(info.CitedSummary, citedLength-n)
+		return shortened
+	}
+	// n is the number of graphemes removed, searched from the overflow until only the ellipsis is left
+	over := length(text) - limit
+	candidates := max(summaryLength, citedLength) - over
+	n := sort.Search(max(candidates, 0), func(i int) bool { return fits(truncated(over + i)) })
+	if renderErr != nil {
+		return "", renderErr
+	}
+	if n >= candidates {
+		pkgLogger.Warn("Post exceeds character limit even without summary", "max_characters", limit)
+		if candidates <= 0 {
+			return text, nil
+		}
+		n = candidates - 1
+	}
+	return executeTemplate(t.summary, truncated(over+n))
+}
+
+// renderNoValue renders the message for an item deemed not valuable.
+func (t *postTemplates) renderNoValue(item Item, screening *ScreeningResult) (string, error) {
+	return executeTemplate(t.noValue, newNoValuePostInfo(t.feedName, item, screening))
+}
+
+func executeTemplate(t *template.Template, data any) (string, error) {
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// truncateRunes truncates s to n runes including a trailing ellipsis.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 1 {
+		return "…"
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// truncateGraphemes truncates s to n grapheme clusters including a trailing ellipsis.
+func truncateGraphemes(s string, n int) string {
+	if uniseg.GraphemeClusterCount(s) <= n {
+		return s
+	}
+	if n <= 1 {
+		return "…"
+	}
+	var b strings.Builder
+	graphemes := uniseg.NewGraphemes(s)
+	for i := 0; i < n-1 && graphemes.Next(); i++ {
+		b.WriteString(graphemes.Str())
+	}
+	return b.String() + "…"
+}
+
+// httpStatusError is returned when a publisher endpoint responds with a non-2xx status.
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// postJSON sends the value as JSON and decodes the response into out if it is not nil.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, value any, out any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return postBody(ctx, client, url, header, body, out)
+}
+
+func postBody(ctx context.Context, client *http.Client, url string, header http.Header, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordPublishedPost records the published post. Failures are only logged because the post is already public.
+func recordPublishedPost(ctx context.Context, repository PostStore, item Item, publisher string, kind PostKind, seq int, statusID string, url string) {
+	if repository == nil {
+		return
+	}
+	err := repository.AddPost(ctx, &PostRecord{
+		ItemID:    item.ID,
+		Publisher: publisher,
+		Kind:      kind,
+		Seq:       seq,
+		StatusID:  statusID,
+		URL:       url,
+	})
+	if err != nil {
+		pkgLogger.Error("Failed to record published post", "publisher", publisher, "url", item.URL, "status_id", statusID, "error", err)
+	}
+}
@@ -0,0 +1,260 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedHTTPRequest は httptest サーバーが受け取ったリクエストを保持する
+type recordedHTTPRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newPublisherTestServer はリクエストを記録し、パスごとに指定したレスポンスを返すサーバーを起動します。
+func newPublisherTestServer(t *testing.T, responses map[string]func(w http.ResponseWriter)) (*httptest.Server, *[]recordedHTTPRequest) {
+	t.Helper()
+	var requests []recordedHTTPRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, recordedHTTPRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		if respond, ok := responses[r.URL.Path]; ok {
+			respond(w)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestPostTemplates(t *testing.T) *postTemplates {
+	t.Helper()
+	templates, err := newPostTemplates(PublisherConfig{}, &DefaultConfig().Mastodon, "")
+	require.NoError(t, err)
+	return templates
+}
+
+var publisherTestItem = Item{ID: 1, Title: "テスト会議", URL: "https://www.soumu.go.jp/menu_news/s-news/example.html"}
+
+func TestNewPublishers(t *testing.T) {
+	t.Run("default name and mastodon", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.MaxCharacters = 500
+		config.Publishers = []PublisherConfig{{Type: PublisherSlack, URL: "https://hooks.slack.com/services/x"}}
+		mastodonClient, err := NewMastodonClient(config, nil)
+		require.NoError(t, err)
+		publishers, err := NewPublishers(config, nil, mastodonClient)
+		require.NoError(t, err)
+		require.Len(t, publishers, 2)
+		assert.Equal(t, "mastodon", publishers[0].Name())
+		assert.Equal(t, "slack", publishers[1].Name())
+	})
+
+	t.Run("mastodon disabled", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.Enabled = false
+		publishers, err := NewPublishers(config, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, publishers)
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Mastodon.Enabled = false
+		config.Publishers = []PublisherConfig{{Type: PublisherWebhook}, {Type: PublisherWebhook}}
+		_, err := NewPublishers(config, nil, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Publishers = []PublisherConfig{{Type: "unknown"}}
+		_, err := NewPublishers(config, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestPostTemplates_renderSummary(t *testing.T) {
+	templates := newTestPostTemplates(t)
+	summary := SummarizeResult{FinalSummary: "あいうえおかきくけこ"}
+
+	text, err := templates.renderSummary(publisherTestItem, summary, 0, utf8.RuneCountInString)
+	require.NoError(t, err)
+	assert.Equal(t, "テスト会議\nあいうえおかきくけこ\n"+publisherTestItem.URL+"\n", text)
+
+	// タイトルとURLは残し、要約を切り詰める
+	limit := len([]rune(text)) - 4
+	text, err = templates.renderSummary(publisherTestItem, summary, limit, utf8.RuneCountInString)
+	require.NoError(t, err)
+	assert.Equal(t, "テスト会議\nあいうえお…\n"+publisherTestItem.URL+"\n", text)
+	assert.Equal(t, limit, len([]rune(text)))
+
+	t.Run("graphemes", func(t *testing.T) {
+		// 絵文字の結合を途中で切らず、書記素の数で制限する
+		summary := SummarizeResult{FinalSummary: "家族👨‍👩‍👧で🇯🇵の会議に参加"}
+		full, err := templates.renderSummary(publisherTestItem, summary, 0, uniseg.GraphemeClusterCount)
+		require.NoError(t, err)
+		limit := uniseg.GraphemeClusterCount(full) - 5
+		text, err := templates.renderSummary(publisherTestItem, summary, limit, uniseg.GraphemeClusterCount)
+		require.NoError(t, err)
+		assert.Equal(t, "テスト会議\n家族👨‍👩‍👧で🇯🇵…\n"+publisherTestItem.URL+"\n", text)
+		assert.Equal(t, limit, uniseg.GraphemeClusterCount(text))
+	})
+
+	t.Run("sentences", func(t *testing.T) {
+		// Mastodonの投稿と同じく、収まらない文を「。」の区切りで落とす
+		summary := SummarizeResult{FinalSummary: "電波法を改正する。免許の手続を簡素化する。施行は来年度。"}
+		full, err := templates.renderSummary(publisherTestItem, summary, 0, utf8.RuneCountInString)
+		require.NoError(t, err)
+		limit := utf8.RuneCountInString(full) - 3
+		text, err := templates.renderSummary(publisherTestItem, summary, limit, utf8.RuneCountInString)
+		require.NoError(t, err)
+		assert.Equal(t, "テスト会議\n電波法を改正する。免許の手続を簡素化する。\n"+publisherTestItem.URL+"\n", text)
+	})
+
+	t.Run("renders a long summary a few times", func(t *testing.T) {
+		// 文の数によらず、二分探索で少ない回数だけ描画する
+		summary := SummarizeResult{FinalSummary: strings.Repeat("電波法を改正する。", 100)}
+		renders := 0
+		length := func(s string) int {
+			renders++
+			return utf8.RuneCountInString(s)
+		}
+		text, err := templates.renderSummary(publisherTestItem, summary, 100, length)
+		require.NoError(t, err)
+		assert.Equal(t, "テスト会議\n"+strings.Repeat("電波法を改正する。", 4)+"\n"+publisherTestItem.URL+"\n", text)
+		assert.LessOrEqual(t, renders, 10)
+	})
+
+	t.Run("summary used twice", func(t *testing.T) {
+		// 要約を2回使うテンプレートでは、切り詰めた後の文字数も制限を超えうる
+		templates, err := newPostTemplates(PublisherConfig{PostTemplate: "{{.Summary}}\n{{.Summary}}"}, &DefaultConfig().Mastodon, "")
+		require.NoError(t, err)
+		text, err := templates.renderSummary(publisherTestItem, summary, 15, utf8.RuneCountInString)
+		require.NoError(t, err)
+		assert.Equal(t, "あいう…\nあいう…", text)
+		assert.LessOrEqual(t, utf8.RuneCountInString(text), 15)
+	})
+}
+
+func TestMisskeyClient(t *testing.T) {
+	server, requests := newPublisherTestServer(t, map[string]func(w http.ResponseWriter){
+		"/api/notes/create": func(w http.ResponseWriter) {
+			w.Write([]byte(`{"createdNote": {"id": "9abc"}}`))
+		},
+	})
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	client := newMisskeyClient(PublisherConfig{Name: "misskey", URL: server.URL + "/", AccessToken: "token", Visibility: "home"}, newTestPostTemplates(t), repo, server.Client())
+	require.NoError(t, client.PostSummary(context.Background(), publisherTestItem, SummarizeResult{FinalSummary: "要約"}))
+
+	require.Len(t, *requests, 1)
+	var body misskeyCreateNoteRequest
+	require.NoError(t, json.Unmarshal((*requests)[0].Body, &body))
+	assert.Equal(t, "token", body.I)
+	assert.Equal(t, "home", body.Visibility)
+	assert.Contains(t, body.Text, "要約")
+
+	posts, err := repo.GetPosts(context.Background(), publisherTestItem.ID, "misskey", PostKindSummary)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "9abc", posts[0].StatusID)
+	assert.Equal(t, server.URL+"/notes/9abc", posts[0].URL)
+}
+
+func TestBlueskyClient(t *testing.T) {
+	sessions := 0
+	expired := true
+	server, requests := newPublisherTestServer(t, map[string]func(w http.ResponseWriter){
+		"/xrpc/com.atproto.server.createSession": func(w http.ResponseWriter) {
+			sessions++
+			w.Write([]byte(`{"accessJwt": "jwt", "did": "did:plc:abc"}`))
+		},
+		"/xrpc/com.atproto.repo.createRecord": func(w http.ResponseWriter) {
+			if expired {
+				expired = false
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "ExpiredToken", "message": "Token has expired"}`))
+				return
+			}
+			w.Write([]byte(`{"uri": "at://did:plc:abc/app.bsky.feed.post/3kxyz", "cid": "cid"}`))
+		},
+	})
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	client := newBlueskyClient(PublisherConfig{Name: "bluesky", URL: server.URL, Identifier: "bot.example.com", Password: "password"}, newTestPostTemplates(t), repo, server.Client())
+	require.NoError(t, client.PostNoValue(context.Background(), publisherTestItem, nil))
+	assert.Equal(t, 2, sessions, "session should be recreated after the token expired")
+
+	last := (*requests)[len(*requests)-1]
+	assert.Equal(t, "Bearer jwt", last.Header.Get("Authorization"))
+	var body blueskyCreateRecordRequest
+	require.NoError(t, json.Unmarshal(last.Body, &body))
+	assert.Equal(t, "did:plc:abc", body.Repo)
+	require.Len(t, body.Record.Facets, 1)
+	facet := body.Record.Facets[0]
+	assert.Equal(t, publisherTestItem.URL, body.Record.Text[facet.Index.ByteStart:facet.Index.ByteEnd])
+
+	posts, err := repo.GetPosts(context.Background(), publisherTestItem.ID, "bluesky", PostKindNoValue)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "https://bsky.app/profile/did:plc:abc/post/3kxyz", posts[0].URL)
+}
+
+func TestChatWebhookPublisher(t *testing.T) {
+	server, requests := newPublisherTestServer(t, nil)
+	templates := newTestPostTemplates(t)
+
+	slack := newChatWebhookPublisher(PublisherConfig{Name: "slack", Type: PublisherSlack, URL: server.URL + "/slack"}, templates, server.Client())
+	require.NoError(t, slack.PostSummary(context.Background(), publisherTestItem, SummarizeResult{FinalSummary: "要約"}))
+	discord := newChatWebhookPublisher(PublisherConfig{Name: "discord", Type: PublisherDiscord, URL: server.URL + "/discord"}, templates, server.Client())
+	require.NoError(t, discord.PostNoValue(context.Background(), publisherTestItem, nil))
+
+	require.Len(t, *requests, 2)
+	var slackBody, discordBody map[string]string
+	require.NoError(t, json.Unmarshal((*requests)[0].Body, &slackBody))
+	require.NoError(t, json.Unmarshal((*requests)[1].Body, &discordBody))
+	assert.Contains(t, slackBody["text"], "要約")
+	assert.Contains(t, discordBody["content"], "【要約対象外】")
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	server, requests := newPublisherTestServer(t, nil)
+	publisher := newWebhookPublisher(PublisherConfig{Name: "webhook", URL: server.URL, Secret: "secret"}, newTestPostTemplates(t), server.Client())
+
+	summary := SummarizeResult{FinalSummary: "要約", Documents: []DocumentSummary{{Summary: "資料の要約"}}}
+	require.NoError(t, publisher.PostSummary(context.Background(), publisherTestItem, summary))
+
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, signWebhookBody("secret", req.Body), req.Header.Get("X-Signature-256"))
+
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(req.Body, &payload))
+	assert.Equal(t, WebhookEventSummary, payload.Event)
+	assert.Equal(t, publisherTestItem.URL, payload.Item.URL)
+	require.NotNil(t, payload.Summary)
+	assert.Equal(t, summary.Documents, payload.Summary.Documents)
+
+	t.Run("error status", func(t *testing.T) {
+		server, _ := newPublisherTestServer(t, map[string]func(w http.ResponseWriter){
+			"/": func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+		})
+		publisher := newWebhookPublisher(PublisherConfig{Name: "webhook", URL: server.URL + "/"}, newTestPostTemplates(t), server.Client())
+		assert.Error(t, publisher.PostNoValue(context.Background(), publisherTestItem, nil))
+	})
+}
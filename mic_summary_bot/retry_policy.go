@@ -0,0 +1,98 @@
+package micsummarybot
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// retryPolicy は先送りしたアイテムを次に処理する時刻を、先送りの理由と回数から求めます。
+type retryPolicy struct {
+	backoff        map[ItemReasonCode][]time.Duration
+	defaultBackoff []time.Duration
+	// maxAge はアイテムを追加してから再試行をあきらめるまでの時間。0の場合は制限しない
+	maxAge time.Duration
+	// fallbackTemplate は再試行をあきらめたアイテムについて投稿するテキストのテンプレート。投稿しない場合はnil
+	fallbackTemplate *template.Template
+}
+
+func newRetryPolicy(config *RetryConfig) (*retryPolicy, error) {
+	p := &retryPolicy{backoff: make(map[ItemReasonCode][]time.Duration)}
+	backoffs := []struct {
+		name   string
+		reason ItemReasonCode
+		values []string
+	}{
+		{"page_not_ready", ReasonGeminiPageNotReady, config.Backoff.PageNotReady},
+		{"download_failed", ReasonDownloadFailed, config.Backoff.DownloadFailed},
+		{"api_failed", ReasonAPIFailed, config.Backoff.APIFailed},
+	}
+	for _, b := range backoffs {
+		durations, err := parseBackoff(b.values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry.backoff.%s: %w", b.name, err)
+		}
+		p.backoff[b.reason] = durations
+	}
+	var err error
+	if p.defaultBackoff, err = parseBackoff(config.Backoff.Default); err != nil {
+		return nil, fmt.Errorf("invalid retry.backoff.default: %w", err)
+	}
+
+	if config.MaxAge != "" {
+		if p.maxAge, err = time.ParseDuration(config.MaxAge); err != nil {
+			return nil, fmt.Errorf("invalid retry.max_age: %w", err)
+		}
+	}
+
+	if config.FallbackPost {
+		t, err := parsePostTemplate("fallback_post", config.FallbackPostTemplate)
+		if err == nil {
+			err = validatePostTemplate(t, samplePostInfo())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry.fallback_post_template: %w", err)
+		}
+		p.fallbackTemplate = t
+	}
+	return p, nil
+}
+
+func parseBackoff(values []string) ([]time.Duration, error) {
+	durations := make([]time.Duration, 0, len(values))
+	for _, v := range values {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("negative duration %s", v)
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// nextAttempt は先送りしたアイテムを次に処理する時刻を返します。item.RetryCount は先送りした回数を表します。
+// 理由ごとの間隔がない場合は default の間隔を使い、それもない場合はすぐに処理できる時刻を返します。
+// 次の時刻がアイテムを追加してから retry.max_age を過ぎる場合は、再試行をあきらめるため false を返します。
+func (p *retryPolicy) nextAttempt(item *Item, now time.Time) (time.Time, bool) {
+	backoff, ok := p.backoff[item.Reason]
+	if !ok || len(backoff) == 0 {
+		backoff = p.defaultBackoff
+	}
+	next := now
+	if len(backoff) > 0 {
+		i := min(max(item.RetryCount-1, 0), len(backoff)-1)
+		next = now.Add(backoff[i])
+	}
+	if p.maxAge > 0 && next.After(item.CreatedAt.Add(p.maxAge)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// renderFallback は再試行をあきらめたアイテムについて、要約の代わりに投稿するテキストを返します。
+func (p *retryPolicy) renderFallback(item *Item, feedName string) (string, error) {
+	return executeTemplate(p.fallbackTemplate, newNoValuePostInfo(feedName, *item, nil))
+}
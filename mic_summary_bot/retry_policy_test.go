@@ -0,0 +1,71 @@
+package micsummarybot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_nextAttempt(t *testing.T) {
+	config := DefaultConfig().Retry
+	config.MaxAge = "168h"
+	policy, err := newRetryPolicy(&config)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	item := &Item{CreatedAt: now.Add(-time.Hour), Reason: ReasonGeminiPageNotReady}
+	for retryCount, want := range map[int]time.Duration{1: 6 * time.Hour, 2: 12 * time.Hour, 3: 24 * time.Hour, 5: 24 * time.Hour} {
+		item.RetryCount = retryCount
+		next, ok := policy.nextAttempt(item, now)
+		assert.True(t, ok)
+		assert.Equal(t, now.Add(want), next, "retry %d", retryCount)
+	}
+
+	item.Reason = ReasonLargeFileSkipped
+	item.RetryCount = 1
+	next, ok := policy.nextAttempt(item, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Hour), next, "the default backoff is used for other reasons")
+
+	item.CreatedAt = now.Add(-168 * time.Hour)
+	_, ok = policy.nextAttempt(item, now)
+	assert.False(t, ok, "gives up after the max age")
+
+	policy, err = newRetryPolicy(&DefaultConfig().Retry)
+	require.NoError(t, err)
+	_, ok = policy.nextAttempt(item, now)
+	assert.True(t, ok, "the max age is not limited by default")
+
+	policy, err = newRetryPolicy(&RetryConfig{})
+	require.NoError(t, err)
+	next, ok = policy.nextAttempt(item, now)
+	assert.True(t, ok)
+	assert.Equal(t, now, next, "retried immediately without backoff and max age")
+}
+
+func TestNewRetryPolicy_invalid(t *testing.T) {
+	_, err := newRetryPolicy(&RetryConfig{Backoff: RetryBackoffConfig{PageNotReady: []string{"6 hours"}}})
+	assert.ErrorContains(t, err, "page_not_ready")
+	_, err = newRetryPolicy(&RetryConfig{Backoff: RetryBackoffConfig{Default: []string{"-1h"}}})
+	assert.Error(t, err)
+	_, err = newRetryPolicy(&RetryConfig{MaxAge: "1 week"})
+	assert.ErrorContains(t, err, "max_age")
+	_, err = newRetryPolicy(&RetryConfig{FallbackPost: true, FallbackPostTemplate: "{{ .Unknown }}"})
+	assert.ErrorContains(t, err, "fallback_post_template")
+}
+
+func TestRetryPolicy_renderFallback(t *testing.T) {
+	config := DefaultConfig().Retry
+	policy, err := newRetryPolicy(&config)
+	require.NoError(t, err)
+	assert.Nil(t, policy.fallbackTemplate, "nothing is posted by default")
+
+	config.FallbackPost = true
+	policy, err = newRetryPolicy(&config)
+	require.NoError(t, err)
+	text, err := policy.renderFallback(&Item{Title: "会議の開催", URL: "https://www.soumu.go.jp/1.html"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "会議の開催\n要約できませんでした\nhttps://www.soumu.go.jp/1.html\n", text)
+}
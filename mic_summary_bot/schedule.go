@@ -0,0 +1,147 @@
+package micsummarybot
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// postingSchedule は要約を投稿できる時刻を、静穏時間と投稿頻度の制限から求めます。
+type postingSchedule struct {
+	location *time.Location
+	// quietStart と quietEnd は静穏時間の開始と終了。0時からの経過時間で表す
+	quietStart  time.Duration
+	quietEnd    time.Duration
+	hasQuiet    bool
+	maxPerHour  int
+	maxPerDay   int
+	minInterval time.Duration
+	deferral    ScheduleDeferral
+}
+
+// maxScheduleIterations は投稿できる時刻を探す際の繰り返しの上限。設定が矛盾している場合に無限ループを防ぐ
+const maxScheduleIterations = 1000
+
+func newPostingSchedule(config *ScheduleConfig) (*postingSchedule, error) {
+	location := time.UTC
+	if config.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone %s: %w", config.Timezone, err)
+		}
+	}
+
+	s := &postingSchedule{
+		location:    location,
+		maxPerHour:  config.MaxPostsPerHour,
+		maxPerDay:   config.MaxPostsPerDay,
+		minInterval: time.Duration(config.MinIntervalSec) * time.Second,
+		deferral:    config.Deferral,
+	}
+	switch s.deferral {
+	case "":
+		s.deferral = ScheduleDeferralWait
+	case ScheduleDeferralWait, ScheduleDeferralSchedule:
+	default:
+		return nil, fmt.Errorf("unknown schedule deferral %q", config.Deferral)
+	}
+
+	if config.QuietHoursStart != "" || config.QuietHoursEnd != "" {
+		start, err := parseTimeOfDay(config.QuietHoursStart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet_hours_start: %w", err)
+		}
+		end, err := parseTimeOfDay(config.QuietHoursEnd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet_hours_end: %w", err)
+		}
+		s.quietStart, s.quietEnd = start, end
+		s.hasQuiet = start != end
+	}
+	return s, nil
+}
+
+// parseTimeOfDay は "HH:MM" を0時からの経過時間に変換します。
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// nextSlot は now 以降で最初に投稿できる時刻を返します。history は過去の投稿と予約済みの投稿の時刻です。
+func (s *postingSchedule) nextSlot(now time.Time, history []time.Time) time.Time {
+	history = append([]time.Time{}, history...)
+	sort.Slice(history, func(i, j int) bool { return history[i].Before(history[j]) })
+
+	t := now
+	for i := 0; i < maxScheduleIterations; i++ {
+		next := s.adjust(t, history)
+		if next.Equal(t) {
+			return t
+		}
+		t = next
+	}
+	pkgLogger.Warn("Could not find a time slot for posting", "now", now)
+	return t
+}
+
+// adjust は t に投稿できない場合、制限が解ける時刻を返します。投稿できる場合は t をそのまま返します。
+func (s *postingSchedule) adjust(t time.Time, history []time.Time) time.Time {
+	if s.hasQuiet {
+		local := t.In(s.location)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+		sinceMidnight := local.Sub(midnight)
+		if s.quietStart < s.quietEnd {
+			if sinceMidnight >= s.quietStart && sinceMidnight < s.quietEnd {
+				return midnight.Add(s.quietEnd)
+			}
+		} else {
+			// 日付をまたぐ静穏時間
+			if sinceMidnight >= s.quietStart {
+				return midnight.AddDate(0, 0, 1).Add(s.quietEnd)
+			}
+			if sinceMidnight < s.quietEnd {
+				return midnight.Add(s.quietEnd)
+			}
+		}
+	}
+
+	if s.minInterval > 0 {
+		for _, h := range history {
+			if t.Sub(h) < s.minInterval && h.Sub(t) < s.minInterval {
+				return h.Add(s.minInterval)
+			}
+		}
+	}
+
+	if s.maxPerHour > 0 {
+		var inWindow []time.Time
+		for _, h := range history {
+			if h.After(t.Add(-time.Hour)) && !h.After(t) {
+				inWindow = append(inWindow, h)
+			}
+		}
+		if len(inWindow) >= s.maxPerHour {
+			return inWindow[len(inWindow)-s.maxPerHour].Add(time.Hour)
+		}
+	}
+
+	if s.maxPerDay > 0 {
+		local := t.In(s.location)
+		dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		count := 0
+		for _, h := range history {
+			if !h.Before(dayStart) && h.Before(dayEnd) {
+				count++
+			}
+		}
+		if count >= s.maxPerDay {
+			return dayEnd
+		}
+	}
+	return t
+}
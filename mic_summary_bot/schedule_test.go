@@ -0,0 +1,102 @@
+package micsummarybot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostingSchedule_nextSlot(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 6, day, hour, minute, 0, 0, jst)
+	}
+
+	testCases := []struct {
+		name     string
+		config   ScheduleConfig
+		now      time.Time
+		history  []time.Time
+		expected time.Time
+	}{
+		{
+			name:     "no limits",
+			config:   ScheduleConfig{},
+			now:      at(1, 3, 0),
+			history:  []time.Time{at(1, 2, 59)},
+			expected: at(1, 3, 0),
+		},
+		{
+			name:     "quiet hours across midnight, before midnight",
+			config:   ScheduleConfig{QuietHoursStart: "23:00", QuietHoursEnd: "07:00"},
+			now:      at(1, 23, 30),
+			expected: at(2, 7, 0),
+		},
+		{
+			name:     "quiet hours across midnight, after midnight",
+			config:   ScheduleConfig{QuietHoursStart: "23:00", QuietHoursEnd: "07:00"},
+			now:      at(2, 3, 0),
+			expected: at(2, 7, 0),
+		},
+		{
+			name:     "quiet hours within a day",
+			config:   ScheduleConfig{QuietHoursStart: "12:00", QuietHoursEnd: "13:00"},
+			now:      at(1, 12, 30),
+			expected: at(1, 13, 0),
+		},
+		{
+			name:     "outside quiet hours",
+			config:   ScheduleConfig{QuietHoursStart: "23:00", QuietHoursEnd: "07:00"},
+			now:      at(1, 7, 0),
+			expected: at(1, 7, 0),
+		},
+		{
+			name:     "minimum interval",
+			config:   ScheduleConfig{MinIntervalSec: 30 * 60},
+			now:      at(1, 10, 0),
+			history:  []time.Time{at(1, 9, 50)},
+			expected: at(1, 10, 20),
+		},
+		{
+			name:     "minimum interval after a scheduled post",
+			config:   ScheduleConfig{MinIntervalSec: 30 * 60},
+			now:      at(1, 10, 0),
+			history:  []time.Time{at(1, 9, 50), at(1, 10, 20)},
+			expected: at(1, 10, 50),
+		},
+		{
+			name:     "posts per hour",
+			config:   ScheduleConfig{MaxPostsPerHour: 2},
+			now:      at(1, 10, 0),
+			history:  []time.Time{at(1, 9, 10), at(1, 9, 40)},
+			expected: at(1, 10, 10),
+		},
+		{
+			name:     "posts per day moves to the next morning",
+			config:   ScheduleConfig{MaxPostsPerDay: 2, QuietHoursStart: "23:00", QuietHoursEnd: "07:00"},
+			now:      at(1, 20, 0),
+			history:  []time.Time{at(1, 8, 0), at(1, 12, 0)},
+			expected: at(2, 7, 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Timezone = "Asia/Tokyo"
+			s, err := newPostingSchedule(&tc.config)
+			require.NoError(t, err)
+			assert.True(t, tc.expected.Equal(s.nextSlot(tc.now, tc.history)), "expected %s, got %s", tc.expected, s.nextSlot(tc.now, tc.history))
+		})
+	}
+}
+
+func TestNewPostingSchedule_invalid(t *testing.T) {
+	_, err := newPostingSchedule(&ScheduleConfig{QuietHoursStart: "25:00", QuietHoursEnd: "07:00"})
+	assert.Error(t, err)
+	_, err = newPostingSchedule(&ScheduleConfig{Timezone: "Mars/Olympus"})
+	assert.Error(t, err)
+	_, err = newPostingSchedule(&ScheduleConfig{Deferral: "drop"})
+	assert.Error(t, err)
+}
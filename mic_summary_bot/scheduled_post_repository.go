@@ -0,0 +1,199 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ScheduledPost は scheduled_posts テーブルのレコードを表す構造体。
+// Mastodonの予約投稿として送信した要約を、アイテムと投稿先の組ごとに記録する
+type ScheduledPost struct {
+	ItemID    int
+	Publisher string
+	// ScheduledID は予約投稿のID。公開後のステータスIDとは異なる
+	ScheduledID string
+	ScheduledAt time.Time
+	// StatusID は公開されたステータスのID。公開を確認するまでは空
+	StatusID  string
+	CreatedAt time.Time
+}
+
+// AddScheduledPost は予約投稿を記録します。
+func (r *ItemRepository) AddScheduledPost(ctx context.Context, post *ScheduledPost) error {
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		return addScheduledPost(ctx, tx, post)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add scheduled post for item ID %d: %w", post.ItemID, err)
+	}
+	return nil
+}
+
+// CompleteScheduledOutbox は予約した投稿を scheduled_posts テーブルに記録し、outbox から削除します。
+// CompleteOutbox と同じく、2つの操作は同じトランザクションで行います。
+func (r *ItemRepository) CompleteScheduledOutbox(ctx context.Context, entryID int64, post *ScheduledPost) error {
+	deleteSQL := formatQuery(`
+	DELETE FROM outbox
+	WHERE id = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		if err := addScheduledPost(ctx, tx, post); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, deleteSQL, entryID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete scheduled outbox ID %d: %w", entryID, err)
+	}
+	return nil
+}
+
+func addScheduledPost(ctx context.Context, tx *sql.Tx, post *ScheduledPost) error {
+	insertSQL := formatQuery(`
+	INSERT INTO scheduled_posts (item_id, publisher, scheduled_id, scheduled_at, status_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?);
+	`)
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now().UTC()
+	}
+	_, err := tx.ExecContext(ctx, insertSQL, post.ItemID, post.Publisher, post.ScheduledID, post.ScheduledAt.UTC(), post.StatusID, post.CreatedAt)
+	return err
+}
+
+// GetScheduledPosts は指定したアイテムの予約投稿を返します。
+func (r *ItemRepository) GetScheduledPosts(ctx context.Context, itemID int) ([]*ScheduledPost, error) {
+	query := formatQuery(`
+	SELECT item_id, publisher, scheduled_id, scheduled_at, status_id, created_at
+	FROM scheduled_posts
+	WHERE item_id = ?
+	ORDER BY publisher ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled posts for item ID %d: %w", itemID, err)
+	}
+	defer rows.Close()
+
+	return scanScheduledPosts(rows)
+}
+
+// GetUnresolvedScheduledPosts は公開時刻が since から until の間で、公開されたステータスを確認していない予約投稿を公開時刻順に返します。
+func (r *ItemRepository) GetUnresolvedScheduledPosts(ctx context.Context, publisher string, since, until time.Time) ([]*ScheduledPost, error) {
+	query := formatQuery(`
+	SELECT item_id, publisher, scheduled_id, scheduled_at, status_id, created_at
+	FROM scheduled_posts
+	WHERE publisher = ? AND status_id = '' AND scheduled_at >= ? AND scheduled_at <= ?
+	ORDER BY scheduled_at ASC;
+	`)
+
+	rows, err := r.db.QueryContext(ctx, query, publisher, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unresolved scheduled posts for %s: %w", publisher, err)
+	}
+	defer rows.Close()
+
+	return scanScheduledPosts(rows)
+}
+
+// ResolveScheduledPost は予約投稿から公開されたステータスを posts テーブルに記録し、予約投稿にステータスIDを設定します。
+// 2つの操作は同じトランザクションで行います。
+func (r *ItemRepository) ResolveScheduledPost(ctx context.Context, scheduled *ScheduledPost, post *PostRecord) error {
+	updateSQL := formatQuery(`
+	UPDATE scheduled_posts
+	SET status_id = ?
+	WHERE item_id = ? AND publisher = ?;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		if err := addPost(ctx, tx, post); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, updateSQL, post.StatusID, scheduled.ItemID, scheduled.Publisher)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve scheduled post for item ID %d: %w", scheduled.ItemID, err)
+	}
+	scheduled.StatusID = post.StatusID
+	return nil
+}
+
+func scanScheduledPosts(rows *sql.Rows) ([]*ScheduledPost, error) {
+	var posts []*ScheduledPost
+	for rows.Next() {
+		post := &ScheduledPost{}
+		if err := rows.Scan(&post.ItemID, &post.Publisher, &post.ScheduledID, &post.ScheduledAt, &post.StatusID, &post.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetPostTimes は since 以降にアイテムを投稿した時刻と、予約投稿の公開時刻を古い順に返します。
+// 投稿頻度の制限に使うため、同じアイテムを複数の投稿先に配信した場合は最初の1回だけを数えます。
+// 予約投稿したアイテムは、配信を記録した時刻ではなく予約した時刻を使います。
+func (r *ItemRepository) GetPostTimes(ctx context.Context, since time.Time) ([]time.Time, error) {
+	deliveredQuery := formatQuery(`
+	SELECT item_id, updated_at
+	FROM deliveries
+	WHERE status = ? AND kind IN (?, ?) AND updated_at >= ?;
+	`)
+	scheduledQuery := formatQuery(`
+	SELECT item_id, scheduled_at
+	FROM scheduled_posts
+	WHERE scheduled_at >= ? OR created_at >= ?;
+	`)
+
+	times := make(map[int]time.Time)
+	err := queryItemTimes(ctx, r.db, deliveredQuery, func(itemID int, t time.Time) {
+		if earliest, ok := times[itemID]; !ok || t.Before(earliest) {
+			times[itemID] = t
+		}
+	}, DeliverySent, PostKindSummary, PostKindNoValue, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery times: %w", err)
+	}
+	scheduled := make(map[int]time.Time)
+	err = queryItemTimes(ctx, r.db, scheduledQuery, func(itemID int, t time.Time) {
+		if earliest, ok := scheduled[itemID]; !ok || t.Before(earliest) {
+			scheduled[itemID] = t
+		}
+	}, since.UTC(), since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled times: %w", err)
+	}
+	for itemID, t := range scheduled {
+		times[itemID] = t
+	}
+
+	var result []time.Time
+	for _, t := range times {
+		if !t.Before(since) {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result, nil
+}
+
+func queryItemTimes(ctx context.Context, db *sql.DB, query string, add func(itemID int, t time.Time), args ...any) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemID int
+		var t time.Time
+		if err := rows.Scan(&itemID, &t); err != nil {
+			return err
+		}
+		add(itemID, t)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,42 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_GetPostTimes(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	since := time.Now().UTC().Add(-time.Minute)
+	// Item 1 is delivered to two publishers, and counted once
+	for _, publisher := range []string{"mastodon", "slack"} {
+		require.NoError(t, repo.StartDelivery(ctx, 1, publisher, PostKindSummary))
+		require.NoError(t, repo.FinishDelivery(ctx, 1, publisher, PostKindSummary, nil))
+	}
+	// Item 2 is scheduled, and counted at the scheduled time
+	require.NoError(t, repo.StartDelivery(ctx, 2, "mastodon", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 2, "mastodon", PostKindSummary, nil))
+	scheduledAt := time.Now().UTC().Add(2 * time.Hour).Truncate(time.Second)
+	require.NoError(t, repo.AddScheduledPost(ctx, &ScheduledPost{ItemID: 2, Publisher: "mastodon", ScheduledID: "7", ScheduledAt: scheduledAt}))
+	// Failed deliveries are not counted
+	require.NoError(t, repo.StartDelivery(ctx, 3, "mastodon", PostKindSummary))
+	require.NoError(t, repo.FinishDelivery(ctx, 3, "mastodon", PostKindSummary, assert.AnError))
+
+	times, err := repo.GetPostTimes(ctx, since)
+	require.NoError(t, err)
+	require.Len(t, times, 2)
+	assert.True(t, times[0].After(since))
+	assert.True(t, scheduledAt.Equal(times[1]))
+
+	scheduled, err := repo.GetScheduledPosts(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, "7", scheduled[0].ScheduledID)
+}
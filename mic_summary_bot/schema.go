@@ -0,0 +1,156 @@
+package micsummarybot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// schemaFor はGoの構造体からGeminiの構造化出力用のスキーマを生成します。
+//
+// プロパティ名はjsonタグから取得し、フィールドの定義順をPropertyOrderingとします。
+// jsonタグが "-" のフィールドと、genaiタグが "-" のフィールドはスキーマに含めません。
+// genaiタグにはカンマ区切りで以下を指定できます。descriptionは残りのタグ全体を値とするため、最後に指定してください。
+//
+//	required               必須のプロパティとする
+//	enum=YES|NO|WAIT       値を列挙したものに制限する
+//	description=説明文      プロパティの説明
+func schemaFor(v any) (*genai.Schema, error) {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+func schemaForType(t reflect.Type) (*genai.Schema, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaForType(t.Elem())
+	case reflect.String:
+		return &genai.Schema{Type: genai.TypeString}, nil
+	case reflect.Bool:
+		return &genai.Schema{Type: genai.TypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &genai.Schema{Type: genai.TypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return &genai.Schema{Type: genai.TypeNumber}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &genai.Schema{Type: genai.TypeArray, Items: items}, nil
+	case reflect.Struct:
+		return schemaForStruct(t)
+	default:
+		return nil, fmt.Errorf("unsupported type for schema: %s", t)
+	}
+}
+
+func schemaForStruct(t reflect.Type) (*genai.Schema, error) {
+	schema := &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: map[string]*genai.Schema{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || field.Tag.Get("genai") == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaForType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate schema for field %s.%s: %w", t.Name(), field.Name, err)
+		}
+		required, err := applySchemaTag(property, field.Tag.Get("genai"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid genai tag on field %s.%s: %w", t.Name(), field.Name, err)
+		}
+
+		schema.Properties[name] = property
+		schema.PropertyOrdering = append(schema.PropertyOrdering, name)
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
+
+// applySchemaTag はgenaiタグの内容をスキーマに反映し、必須のプロパティかどうかを返します。
+func applySchemaTag(schema *genai.Schema, tag string) (bool, error) {
+	required := false
+	for tag != "" {
+		var option string
+		if strings.HasPrefix(tag, "description=") {
+			option, tag = tag, ""
+		} else {
+			option, tag, _ = strings.Cut(tag, ",")
+		}
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			target := schema
+			if schema.Type == genai.TypeArray {
+				target = schema.Items
+			}
+			target.Enum = strings.Split(value, "|")
+		case "description":
+			schema.Description = value
+		default:
+			return false, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return required, nil
+}
+
+// mustSchemaFor は schemaFor と同様ですが、失敗した場合はpanicします。
+// パッケージ内の固定の構造体からスキーマを生成する場合に使います。
+func mustSchemaFor(v any) *genai.Schema {
+	schema, err := schemaFor(v)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// withExtraOutputFields は設定で追加された出力項目を extra オブジェクトとしてスキーマに追加したコピーを返します。
+func withExtraOutputFields(schema *genai.Schema, fields []ExtraOutputField) *genai.Schema {
+	if len(fields) == 0 {
+		return schema
+	}
+	extra := &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: map[string]*genai.Schema{},
+	}
+	for _, field := range fields {
+		extra.Properties[field.Name] = &genai.Schema{
+			Type:        genai.TypeString,
+			Description: field.Description,
+			Enum:        field.Enum,
+		}
+		extra.PropertyOrdering = append(extra.PropertyOrdering, field.Name)
+		extra.Required = append(extra.Required, field.Name)
+	}
+
+	copied := *schema
+	copied.Properties = make(map[string]*genai.Schema, len(schema.Properties)+1)
+	for name, property := range schema.Properties {
+		copied.Properties[name] = property
+	}
+	copied.Properties[extraOutputFieldsName] = extra
+	copied.PropertyOrdering = append(append([]string{}, schema.PropertyOrdering...), extraOutputFieldsName)
+	copied.Required = append(append([]string{}, schema.Required...), extraOutputFieldsName)
+	return &copied
+}
+
+// extraOutputFieldsName は設定で追加された出力項目をまとめるプロパティ名
+const extraOutputFieldsName = "extra"
@@ -0,0 +1,102 @@
+package micsummarybot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestSchemaFor(t *testing.T) {
+	t.Run("screening result", func(t *testing.T) {
+		expected := &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"criteria": {
+					Type: genai.TypeArray,
+					Items: &genai.Schema{
+						Type: genai.TypeObject,
+						Properties: map[string]*genai.Schema{
+							"name":     {Type: genai.TypeString},
+							"thoughts": {Type: genai.TypeString},
+							"result":   {Type: genai.TypeString, Enum: []string{"YES", "NO", "WAIT"}},
+						},
+						PropertyOrdering: []string{"name", "thoughts", "result"},
+					},
+				},
+				"final_result": {Type: genai.TypeString, Enum: []string{"YES", "NO", "WAIT"}},
+			},
+			PropertyOrdering: []string{"criteria", "final_result"},
+			Required:         []string{"final_result"},
+		}
+		assert.Equal(t, expected, screeningResultSchema)
+	})
+
+	t.Run("summarize result", func(t *testing.T) {
+		assert.Equal(t, []string{"documents", "first_summary", "omissibles", "missed_items", "final_summary", "final_summary_sentences"}, summarizeResultSchema.PropertyOrdering)
+		assert.Equal(t, []string{"final_summary"}, summarizeResultSchema.Required)
+		assert.Equal(t, []string{"metadata", "keyPoints", "summary"}, summarizeResultSchema.Properties["documents"].Items.PropertyOrdering)
+		source := summarizeResultSchema.Properties["final_summary_sentences"].Items.Properties["sources"].Items
+		assert.Equal(t, genai.TypeInteger, source.Properties["document_index"].Type)
+		assert.Equal(t, []string{"document_index"}, source.Required)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		type tagged struct {
+			Decision string   `json:"decision" genai:"required,enum=A|B,description=判定結果。A, Bのいずれか"`
+			Tags     []string `json:"tags,omitempty" genai:"enum=x|y"`
+			Score    float64  `json:"score"`
+			Flag     bool
+			Ignored  string `json:"-"`
+			Internal string `json:"internal" genai:"-"`
+			private  string
+		}
+		schema, err := schemaFor(tagged{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"decision", "tags", "score", "Flag"}, schema.PropertyOrdering)
+		assert.Equal(t, []string{"decision"}, schema.Required)
+		assert.Equal(t, "判定結果。A, Bのいずれか", schema.Properties["decision"].Description)
+		assert.Equal(t, []string{"A", "B"}, schema.Properties["decision"].Enum)
+		assert.Equal(t, []string{"x", "y"}, schema.Properties["tags"].Items.Enum)
+		assert.Equal(t, genai.TypeNumber, schema.Properties["score"].Type)
+		assert.Equal(t, genai.TypeBoolean, schema.Properties["Flag"].Type)
+	})
+
+	t.Run("unknown tag option", func(t *testing.T) {
+		type invalid struct {
+			Value string `json:"value" genai:"requried"`
+		}
+		_, err := schemaFor(invalid{})
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		type invalid struct {
+			Value map[string]string `json:"value"`
+		}
+		_, err := schemaFor(invalid{})
+		assert.Error(t, err)
+	})
+}
+
+func TestWithExtraOutputFields(t *testing.T) {
+	fields := []ExtraOutputField{
+		{Name: "category", Description: "会議の分野", Enum: []string{"情報通信", "その他"}},
+		{Name: "audience", Description: "想定読者"},
+	}
+	schema := withExtraOutputFields(summarizeResultSchema, fields)
+
+	extra := schema.Properties["extra"]
+	require.NotNil(t, extra)
+	assert.Equal(t, []string{"category", "audience"}, extra.PropertyOrdering)
+	assert.Equal(t, []string{"category", "audience"}, extra.Required)
+	assert.Equal(t, []string{"情報通信", "その他"}, extra.Properties["category"].Enum)
+	assert.Equal(t, "extra", schema.PropertyOrdering[len(schema.PropertyOrdering)-1])
+	assert.Contains(t, schema.Required, "extra")
+
+	// 元のスキーマは変更しない
+	assert.NotContains(t, summarizeResultSchema.Properties, "extra")
+	assert.NotContains(t, summarizeResultSchema.PropertyOrdering, "extra")
+	assert.Same(t, summarizeResultSchema, withExtraOutputFields(summarizeResultSchema, nil))
+}
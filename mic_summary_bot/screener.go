@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
-	"time"
 
 	"google.golang.org/genai"
 )
@@ -19,14 +18,23 @@ const (
 	WorthSummarizingWait ScreeningDecision = "WAIT"
 )
 
+// ScreeningCriterion は判定基準ごとの判定結果と、その根拠を表す
+type ScreeningCriterion struct {
+	Name     string            `json:"name"`
+	Thoughts string            `json:"thoughts"`
+	Result   ScreeningDecision `json:"result" genai:"enum=YES|NO|WAIT"`
+}
+
 type ScreeningResult struct {
-	Criteria []struct {
-		Name   string            `json:"name"`
-		Result ScreeningDecision `json:"result"`
-	} `json:"criteria"`
-	FinalResult ScreeningDecision `json:"final_result"`
+	Criteria    []ScreeningCriterion `json:"criteria"`
+	FinalResult ScreeningDecision    `json:"final_result" genai:"required,enum=YES|NO|WAIT"`
+	// Model は判定に使われたモデル名。Geminiの出力ではなくクライアントが設定する
+	Model string `json:"model,omitempty" genai:"-"`
 }
 
+// screeningResultSchema は ScreeningResult から生成した構造化出力のスキーマ
+var screeningResultSchema = mustSchemaFor(ScreeningResult{})
+
 // IsWorthSummarizing はHTMLandDocumentsが要約する価値のあるものか判定します。
 func (client *GenAIClient) IsWorthSummarizing(htmlAndDocs *HTMLandDocuments, promptTemplate string) (*ScreeningResult, error) {
 	ctx := context.Background()
@@ -35,36 +43,7 @@ func (client *GenAIClient) IsWorthSummarizing(htmlAndDocs *HTMLandDocuments, pro
 	modelConfig := &genai.GenerateContentConfig{
 		Temperature:      new(float32), // 0
 		ResponseMIMEType: "application/json",
-		ResponseSchema: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"criteria": {
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"name": {
-								Type: genai.TypeString,
-							},
-							"thoughts": {
-								Type: genai.TypeString,
-							},
-							"result": {
-								Type: genai.TypeString,
-								Enum: []string{"YES", "NO", "WAIT"},
-							},
-						},
-						PropertyOrdering: []string{"name", "thoughts", "result"},
-					},
-				},
-				"final_result": {
-					Type: genai.TypeString,
-					Enum: []string{"YES", "NO", "WAIT"},
-				},
-			},
-			PropertyOrdering: []string{"criteria", "final_result"},
-			Required:         []string{"final_result"},
-		},
+		ResponseSchema:   screeningResultSchema,
 	}
 
 	t, err := template.New("prompt").Parse(promptTemplate)
@@ -89,18 +68,9 @@ func (client *GenAIClient) IsWorthSummarizing(htmlAndDocs *HTMLandDocuments, pro
 	contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
 
 	// LLMへのリクエストとリトライ処理
-	var resp *genai.GenerateContentResponse
-	for i := 0; i < client.MaxRetry+1; i++ {
-		resp, err = client.Client.Models.GenerateContent(ctx, client.ScreeningModel, contents, modelConfig)
-		if err == nil {
-			break // 成功したらループを抜ける
-		}
-		pkgLogger.Warn("Gemini API call failed", "attempt", i+1, "max_retry", client.MaxRetry+1, "error", err, "retrying_in_seconds", client.RetryIntervalSec)
-		time.Sleep(time.Duration(client.RetryIntervalSec) * time.Second)
-	}
-
+	resp, model, err := client.generateContent(ctx, client.ScreeningModel, contents, modelConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get response from Gemini API after %d retries: %w", client.MaxRetry, err)
+		return nil, err
 	}
 
 	// レスポンスのパース
@@ -116,6 +86,7 @@ func (client *GenAIClient) IsWorthSummarizing(htmlAndDocs *HTMLandDocuments, pro
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JSON response from Gemini API: %w", err)
 	}
+	jsonResult.Model = model
 
 	return &jsonResult, nil
 }
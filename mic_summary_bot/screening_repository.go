@@ -0,0 +1,89 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ScreeningRecord は screening_results テーブルのレコードを表す構造体
+type ScreeningRecord struct {
+	ID          int64
+	ItemID      int
+	FinalResult ScreeningDecision
+	Model       string
+	Result      ScreeningResult
+	CreatedAt   time.Time
+}
+
+// AddScreeningResult はスクリーニングの判定結果を判定基準ごとの根拠とともに保存します。
+// 判定のたびに新しいレコードが追加されます。
+func (r *ItemRepository) AddScreeningResult(ctx context.Context, itemID int, result *ScreeningResult) (int64, error) {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal screening result: %w", err)
+	}
+
+	insertSQL := formatQuery(`
+	INSERT INTO screening_results (item_id, final_result, model, result_json, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id;
+	`)
+
+	var id int64
+	err = withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, insertSQL, itemID, result.FinalResult, result.Model, string(resultJSON), time.Now().UTC()).Scan(&id)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add screening result for item ID %d: %w", itemID, err)
+	}
+	return id, nil
+}
+
+// GetScreeningResults は指定したアイテムのスクリーニング結果を古い順に返します。
+func (r *ItemRepository) GetScreeningResults(ctx context.Context, itemID int) ([]*ScreeningRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, final_result, model, result_json, created_at
+	FROM screening_results
+	WHERE item_id = ?
+	ORDER BY created_at ASC, id ASC;
+	`)
+	return r.queryScreeningResults(ctx, query, itemID)
+}
+
+// ListScreeningResultsByDecision は指定した判定結果のスクリーニング結果を新しい順に最大limit件返します。
+// プロンプトの調整のため、NOやWAITと判定された理由を確認する用途を想定しています。
+func (r *ItemRepository) ListScreeningResultsByDecision(ctx context.Context, decision ScreeningDecision, limit int) ([]*ScreeningRecord, error) {
+	query := formatQuery(`
+	SELECT id, item_id, final_result, model, result_json, created_at
+	FROM screening_results
+	WHERE final_result = ?
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?;
+	`)
+	return r.queryScreeningResults(ctx, query, decision, limit)
+}
+
+func (r *ItemRepository) queryScreeningResults(ctx context.Context, query string, args ...any) ([]*ScreeningRecord, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screening results: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*ScreeningRecord
+	for rows.Next() {
+		record := &ScreeningRecord{}
+		var resultJSON string
+		if err := rows.Scan(&record.ID, &record.ItemID, &record.FinalResult, &record.Model, &resultJSON, &record.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan screening result: %w", err)
+		}
+		if err := json.Unmarshal([]byte(resultJSON), &record.Result); err != nil {
+			return nil, fmt.Errorf("failed to parse stored screening result ID %d: %w", record.ID, err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,60 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_ScreeningResults(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	wait := &ScreeningResult{
+		Criteria: []ScreeningCriterion{
+			{Name: "添付資料", Thoughts: "後日掲載と書かれている", Result: WorthSummarizingWait},
+		},
+		FinalResult: WorthSummarizingWait,
+		Model:       "gemini-2.0-flash",
+	}
+	yes := &ScreeningResult{
+		Criteria: []ScreeningCriterion{
+			{Name: "添付資料", Thoughts: "資料が3件添付されている", Result: WorthSummarizingYes},
+		},
+		FinalResult: WorthSummarizingYes,
+		Model:       "gemini-2.5-flash",
+	}
+	_, err := repo.AddScreeningResult(ctx, 1, wait)
+	require.NoError(t, err)
+	_, err = repo.AddScreeningResult(ctx, 1, yes)
+	require.NoError(t, err)
+	_, err = repo.AddScreeningResult(ctx, 2, &ScreeningResult{FinalResult: WorthSummarizingNo, Model: "gemini-2.0-flash"})
+	require.NoError(t, err)
+
+	t.Run("results for item in order", func(t *testing.T) {
+		records, err := repo.GetScreeningResults(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, WorthSummarizingWait, records[0].FinalResult)
+		assert.Equal(t, "gemini-2.0-flash", records[0].Model)
+		assert.Equal(t, *wait, records[0].Result)
+		assert.Equal(t, *yes, records[1].Result)
+		assert.False(t, records[0].CreatedAt.IsZero())
+	})
+
+	t.Run("results by decision", func(t *testing.T) {
+		records, err := repo.ListScreeningResultsByDecision(ctx, WorthSummarizingNo, 10)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, 2, records[0].ItemID)
+	})
+
+	t.Run("no results", func(t *testing.T) {
+		records, err := repo.GetScreeningResults(ctx, 3)
+		require.NoError(t, err)
+		assert.Empty(t, records)
+	})
+}
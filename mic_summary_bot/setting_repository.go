@@ -0,0 +1,46 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SettingPaused は一時停止中かを表す設定のキー。値が "true" の場合、スクリーニングと投稿を行わない
+const SettingPaused = "paused"
+
+// GetSetting は実行中に変更できる設定の値を返します。設定されていない場合は空文字列を返します。
+func (r *ItemRepository) GetSetting(ctx context.Context, key string) (string, error) {
+	query := formatQuery(`
+	SELECT value
+	FROM settings
+	WHERE key = ?;
+	`)
+	var value string
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetSetting は実行中に変更できる設定の値を保存します。
+func (r *ItemRepository) SetSetting(ctx context.Context, key string, value string) error {
+	upsertSQL := formatQuery(`
+	INSERT INTO settings (key, value, updated_at)
+	VALUES (?, ?, ?)
+	ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at;
+	`)
+	err := withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, upsertSQL, key, value, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set setting %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_Settings(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	value, err := repo.GetSetting(ctx, SettingPaused)
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	require.NoError(t, repo.SetSetting(ctx, SettingPaused, "true"))
+	require.NoError(t, repo.SetSetting(ctx, SettingPaused, "false"))
+	value, err = repo.GetSetting(ctx, SettingPaused)
+	require.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
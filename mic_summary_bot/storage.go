@@ -0,0 +1,221 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Storage はBotの状態を保存するデータベースへの操作を提供する。
+// SQLiteとPostgreSQLの実装があり、いずれも ItemRepository が実装する。
+// 一部の操作だけを使う場合は、用途ごとのインターフェースを受け取る
+type Storage interface {
+	ItemStore
+	ResultStore
+	PostStore
+	DeliveryStore
+	OutboxStore
+	ScheduledPostStore
+	DigestStore
+	MentionStore
+	SettingStore
+	DownloadStore
+	Close() error
+}
+
+// ItemStore はフィードのアイテムとその状態遷移を保存する
+type ItemStore interface {
+	AddItems(ctx context.Context, items []*gofeed.Item) (int, error)
+	IsURLExists(ctx context.Context, url string) (bool, error)
+	GetItemByURL(ctx context.Context, url string) (*Item, error)
+	GetItemByID(ctx context.Context, id int) (*Item, error)
+	GetItemForSummarization(ctx context.Context) (*Item, error)
+	GetItemForScreening(ctx context.Context) (*Item, error)
+	GetItemOverRetryLimit(ctx context.Context) (*Item, error)
+	ReleaseItem(ctx context.Context, itemID int, nextAttemptAt time.Time) error
+	Update(ctx context.Context, item *Item, transition ItemTransition) error
+	RestoreItem(ctx context.Context, item *Item) error
+	GetItemEvents(ctx context.Context, itemID int) ([]*ItemEvent, error)
+	CountUnprocessedItems(ctx context.Context) (int, error)
+	CountItemsByStatus(ctx context.Context) (map[ItemStatus]int, error)
+	CountItemsByReason(ctx context.Context, status ItemStatus) (map[ItemReasonCode]int, error)
+}
+
+// ResultStore はスクリーニングと要約の結果を保存する
+type ResultStore interface {
+	AddScreeningResult(ctx context.Context, itemID int, result *ScreeningResult) (int64, error)
+	GetScreeningResults(ctx context.Context, itemID int) ([]*ScreeningRecord, error)
+	ListScreeningResultsByDecision(ctx context.Context, decision ScreeningDecision, limit int) ([]*ScreeningRecord, error)
+	SaveSummary(ctx context.Context, itemID int, summary *SummarizeResult) (int64, error)
+	GetLatestSummary(ctx context.Context, itemID int) (*SummarizeResult, error)
+}
+
+// PostStore は投稿先に投稿したステータスを保存する
+type PostStore interface {
+	AddPost(ctx context.Context, post *PostRecord) error
+	GetPosts(ctx context.Context, itemID int, publisher string, kind PostKind) ([]*PostRecord, error)
+	GetPostsByPublisher(ctx context.Context, itemID int, publisher string) ([]*PostRecord, error)
+	GetDigestPosts(ctx context.Context, digestID int, publisher string) ([]*PostRecord, error)
+	GetPostByStatusID(ctx context.Context, publisher string, statusID string) (*PostRecord, error)
+	DeletePost(ctx context.Context, id int) error
+	GetPostTimes(ctx context.Context, since time.Time) ([]time.Time, error)
+}
+
+// DeliveryStore は投稿先ごとの配信状況を保存する
+type DeliveryStore interface {
+	StartDelivery(ctx context.Context, itemID int, publisher string, kind PostKind) error
+	FinishDelivery(ctx context.Context, itemID int, publisher string, kind PostKind, deliveryErr error) error
+	GetDeliveries(ctx context.Context, itemID int, kind PostKind) ([]*Delivery, error)
+	ReplaceDeliveries(ctx context.Context, itemID int, deliveries []*Delivery) error
+	StartDigestDelivery(ctx context.Context, digestID int, publisher string) error
+	FinishDigestDelivery(ctx context.Context, digestID int, publisher string, deliveryErr error) error
+	GetDigestDeliveries(ctx context.Context, digestID int) ([]*Delivery, error)
+}
+
+// OutboxStore は投稿を送信する前に、送信しようとしている投稿を保存する
+type OutboxStore interface {
+	PrepareOutbox(ctx context.Context, entry *OutboxEntry) error
+	CompleteOutbox(ctx context.Context, entryID int64, post *PostRecord) error
+	DeleteOutbox(ctx context.Context, entryID int64) error
+	GetOutbox(ctx context.Context, publisher string) ([]*OutboxEntry, error)
+}
+
+// ScheduledPostStore は予約投稿と、公開されたステータスとの対応を保存する
+type ScheduledPostStore interface {
+	AddScheduledPost(ctx context.Context, post *ScheduledPost) error
+	CompleteScheduledOutbox(ctx context.Context, entryID int64, post *ScheduledPost) error
+	GetScheduledPosts(ctx context.Context, itemID int) ([]*ScheduledPost, error)
+	GetUnresolvedScheduledPosts(ctx context.Context, publisher string, since, until time.Time) ([]*ScheduledPost, error)
+	ResolveScheduledPost(ctx context.Context, scheduled *ScheduledPost, post *PostRecord) error
+}
+
+// DigestStore はダイジェストとそれに含めるアイテムを保存する
+type DigestStore interface {
+	AddDigestItem(ctx context.Context, itemID int, kind PostKind) error
+	OpenDigest(ctx context.Context) (*Digest, error)
+	GetPendingDigest(ctx context.Context) (*Digest, error)
+	GetDigest(ctx context.Context, digestID int) (*Digest, error)
+	CompleteDigest(ctx context.Context, digestID int) error
+}
+
+// MentionStore は受け取ったメンションと回答を保存する
+type MentionStore interface {
+	AddMention(ctx context.Context, mention *MentionRecord) error
+	GetMention(ctx context.Context, notificationID string) (*MentionRecord, error)
+	GetLatestMentionNotificationID(ctx context.Context) (string, error)
+	CountAnsweredMentions(ctx context.Context, account string, since time.Time) (int, error)
+}
+
+// SettingStore は実行中に変更できる設定の値を保存する
+type SettingStore interface {
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key string, value string) error
+}
+
+// DownloadStore はダウンロードしたファイルの情報を保存する
+type DownloadStore interface {
+	SaveDownloadedFile(ctx context.Context, file *DownloadedFile) error
+	GetDownloadedFileByURL(ctx context.Context, url string) (*DownloadedFile, error)
+	ListDownloadedFilesByLastAccess(ctx context.Context) ([]*DownloadedFile, error)
+	TotalDownloadedFileSize(ctx context.Context) (int64, error)
+	DeleteDownloadedFile(ctx context.Context, url string) (int, error)
+	DeleteDownloadedContent(ctx context.Context, sha256 string) error
+}
+
+var _ Storage = (*ItemRepository)(nil)
+
+// DatabaseDriver はデータベースの種類を表す
+type DatabaseDriver string
+
+const (
+	DatabaseSQLite   DatabaseDriver = "sqlite"
+	DatabasePostgres DatabaseDriver = "postgres"
+)
+
+// sqlDialect はデータベースごとに異なるSQLを保持する。
+// クエリのプレースホルダーはどちらも ? で書き、PostgreSQLでは送信時に $1, $2, ... に置き換える
+type sqlDialect struct {
+	driver DatabaseDriver
+	// claimSuffix は処理するアイテムを取得するSELECTの末尾に付け、他のインスタンスが同時に同じ行を取得しないようにする
+	claimSuffix string
+	// lockSuffix は更新する行を読むSELECTの末尾に付け、トランザクションが終わるまで他の更新を待たせる
+	lockSuffix string
+	// lockMigrationsSQL はマイグレーションを適用するトランザクションの最初に実行し、複数のインスタンスが同時に適用しないようにする
+	lockMigrationsSQL string
+	// tableExistsSQL はテーブルが存在する場合に1以上を返す
+	tableExistsSQL string
+}
+
+var (
+	sqliteDialect = &sqlDialect{
+		driver:         DatabaseSQLite,
+		tableExistsSQL: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;",
+	}
+	postgresDialect = &sqlDialect{
+		driver:            DatabasePostgres,
+		claimSuffix:       " FOR UPDATE SKIP LOCKED",
+		lockSuffix:        " FOR UPDATE",
+		lockMigrationsSQL: "LOCK TABLE schema_migrations IN EXCLUSIVE MODE;",
+		tableExistsSQL:    "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?;",
+	}
+)
+
+// claimQuery は処理するアイテムを取得するSELECTに、データベースに応じた行ロックを付けます。
+func (d *sqlDialect) claimQuery(query string) string {
+	return strings.TrimSuffix(query, ";") + d.claimSuffix + ";"
+}
+
+// lockQuery は更新する行を読むSELECTに、データベースに応じた行ロックを付けます。
+func (d *sqlDialect) lockQuery(query string) string {
+	return strings.TrimSuffix(query, ";") + d.lockSuffix + ";"
+}
+
+// NewStorage は設定に応じたデータベースを開き、未適用のマイグレーションを適用します。
+func NewStorage(config *DatabaseConfig) (Storage, error) {
+	dialect, err := databaseDialect(config)
+	if err != nil {
+		return nil, err
+	}
+	var repository *ItemRepository
+	if dialect == postgresDialect {
+		repository, err = NewPostgresItemRepository(config.DSN, config.MaxDeferredRetryCount)
+	} else {
+		repository, err = NewItemRepository(config.Path, config.MaxDeferredRetryCount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// databaseDialect は設定されたデータベースの種類に対応する sqlDialect を返します。省略時はSQLiteです。
+func databaseDialect(config *DatabaseConfig) (*sqlDialect, error) {
+	switch config.Driver {
+	case "", DatabaseSQLite:
+		return sqliteDialect, nil
+	case DatabasePostgres:
+		return postgresDialect, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", config.Driver)
+	}
+}
+
+// openDatabase は設定に応じたデータベースを開きます。マイグレーションは適用しません。
+func openDatabase(config *DatabaseConfig) (*sql.DB, error) {
+	dialect, err := databaseDialect(config)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == postgresDialect {
+		return openPostgres(config.DSN)
+	}
+	db, err := sql.Open("sqlite", config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
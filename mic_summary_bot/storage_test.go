@@ -0,0 +1,543 @@
+package micsummarybot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresDSNEnv はPostgreSQLのテストに使うデータベースのDSNを指定する環境変数。設定されていない場合はテストをスキップする
+const postgresDSNEnv = "TEST_POSTGRES_DSN"
+
+func TestSQLiteStorage(t *testing.T) {
+	runStorageConformanceTests(t, func(t *testing.T) Storage {
+		storage, err := NewStorage(&DatabaseConfig{
+			Driver:                DatabaseSQLite,
+			Path:                  filepath.Join(t.TempDir(), "database.sqlite"),
+			MaxDeferredRetryCount: 3,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	})
+}
+
+func TestPostgresStorage(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	newStorage := func(t *testing.T) Storage {
+		storage, err := NewStorage(&DatabaseConfig{
+			Driver:                DatabasePostgres,
+			DSN:                   newPostgresTestSchema(t, dsn),
+			MaxDeferredRetryCount: 3,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	}
+	runStorageConformanceTests(t, newStorage)
+
+	t.Run("concurrent claims", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		addTestItems(t, storage, 5)
+
+		var mu sync.Mutex
+		claimed := make(map[int]int)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				item, err := storage.GetItemForScreening(ctx)
+				assert.NoError(t, err)
+				if item != nil {
+					mu.Lock()
+					claimed[item.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, claimed, 5, "every item is claimed")
+		for id, count := range claimed {
+			assert.Equal(t, 1, count, "item %d is claimed once", id)
+		}
+	})
+}
+
+// newPostgresTestSchema はテストごとのスキーマを作成し、そのスキーマを使うDSNを返します。スキーマはテストの終了時に削除します。
+func newPostgresTestSchema(t *testing.T, dsn string) string {
+	db, err := openPostgres(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	schema := fmt.Sprintf("mic_summary_bot_test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema + ";")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db, err := openPostgres(dsn)
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		db.Exec("DROP SCHEMA " + schema + " CASCADE;")
+	})
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		require.NoError(t, err)
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+// addTestItems は未処理のアイテムを count 件追加します。公開日時は1件目が最も古くなります。
+func addTestItems(t *testing.T, storage Storage, count int) {
+	now := time.Now().UTC()
+	var items []*gofeed.Item
+	for i := 1; i <= count; i++ {
+		published := now.Add(time.Duration(i-count) * time.Hour)
+		items = append(items, &gofeed.Item{
+			Link:            fmt.Sprintf("https://www.soumu.go.jp/%d.html", i),
+			Title:           fmt.Sprintf("Article %d", i),
+			PublishedParsed: &published,
+		})
+	}
+	added, err := storage.AddItems(context.Background(), items)
+	require.NoError(t, err)
+	require.Equal(t, count, added)
+}
+
+// runStorageConformanceTests は Storage の実装が満たすべき振る舞いを確認します。
+// newStorage はテストごとに空のデータベースを返します。
+func runStorageConformanceTests(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("items", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 2)
+
+		added, err := storage.AddItems(ctx, []*gofeed.Item{{Link: "https://www.soumu.go.jp/1.html", Title: "Article 1", PublishedParsed: &time.Time{}}})
+		require.NoError(t, err)
+		assert.Zero(t, added, "existing URLs are skipped")
+
+		exists, err := storage.IsURLExists(ctx, "https://www.soumu.go.jp/1.html")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = storage.IsURLExists(ctx, "https://www.soumu.go.jp/3.html")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		item, err := storage.GetItemByURL(ctx, "https://www.soumu.go.jp/2.html")
+		require.NoError(t, err)
+		require.NotNil(t, item)
+		assert.Equal(t, "Article 2", item.Title)
+		assert.Equal(t, StatusUnprocessed, item.Status)
+
+		byID, err := storage.GetItemByID(ctx, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, item.URL, byID.URL)
+		assert.WithinDuration(t, item.PublishedAt, byID.PublishedAt, time.Millisecond)
+
+		missing, err := storage.GetItemByID(ctx, item.ID+100)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+
+		count, err := storage.CountUnprocessedItems(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		item.Status = StatusProcessed
+		item.Reason = ReasonSkippedByAdmin
+		require.NoError(t, storage.Update(ctx, item, ItemTransition{Stage: StageAdmin}))
+		counts, err := storage.CountItemsByStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[ItemStatus]int{StatusUnprocessed: 1, StatusProcessed: 1}, counts)
+
+		item.Status = StatusUnprocessed
+		require.NoError(t, storage.RestoreItem(ctx, item))
+		restored, err := storage.GetItemByID(ctx, item.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnprocessed, restored.Status)
+
+		events, err := storage.GetItemEvents(ctx, item.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, []ItemStage{StageFeed, StageAdmin, StageDryRun}, []ItemStage{events[0].Stage, events[1].Stage, events[2].Stage})
+		assert.Equal(t, StatusProcessed, events[2].FromStatus)
+
+		item.Status = StatusProcessed
+		require.NoError(t, storage.Update(ctx, item, ItemTransition{Stage: StageAdmin}))
+		item.Status = StatusPending
+		require.ErrorIs(t, storage.Update(ctx, item, ItemTransition{Stage: StageScreening}), ErrInvalidTransition)
+	})
+
+	t.Run("claims", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 2)
+
+		first, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, first)
+		assert.Equal(t, "Article 1", first.Title, "the oldest item first")
+
+		second, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, second)
+		assert.Equal(t, "Article 2", second.Title, "claimed items are skipped")
+
+		none, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, none)
+
+		// Releasing makes the item available again
+		require.NoError(t, storage.ReleaseItem(ctx, first.ID, time.Time{}))
+		again, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, again)
+		assert.Equal(t, first.ID, again.ID)
+
+		// Updating the status releases the claim
+		again.Status = StatusPending
+		require.NoError(t, storage.Update(ctx, again, ItemTransition{Stage: StageScreening}))
+		pending, err := storage.GetItemForSummarization(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		assert.Equal(t, first.ID, pending.ID)
+		none, err = storage.GetItemForSummarization(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, none)
+
+		second.Status = StatusDeferred
+		second.RetryCount = 1
+		require.NoError(t, storage.Update(ctx, second, ItemTransition{Stage: StageScreening}))
+		deferred, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, deferred)
+		assert.Equal(t, second.ID, deferred.ID)
+	})
+
+	t.Run("next attempt", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 3)
+
+		// A deferred item is not retried until its next attempt
+		first, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		first.Status = StatusDeferred
+		first.RetryCount = 1
+		require.NoError(t, storage.Update(ctx, first, ItemTransition{Stage: StageScreening, NextAttemptAt: time.Now().Add(time.Hour)}))
+
+		// A pending item released until later is not posted before that
+		second, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		second.Status = StatusPending
+		require.NoError(t, storage.Update(ctx, second, ItemTransition{Stage: StageScreening}))
+		pending, err := storage.GetItemForSummarization(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		require.NoError(t, storage.ReleaseItem(ctx, pending.ID, time.Now().Add(time.Hour)))
+		pending, err = storage.GetItemForSummarization(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+
+		third, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, third)
+		assert.Equal(t, "Article 3", third.Title)
+		third.Status = StatusDeferred
+		third.RetryCount = 1
+		require.NoError(t, storage.Update(ctx, third, ItemTransition{Stage: StageScreening, NextAttemptAt: time.Now().Add(-time.Minute)}))
+
+		due, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, due)
+		assert.Equal(t, third.ID, due.ID, "only the item whose next attempt has passed is retried")
+		none, err := storage.GetItemForScreening(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, none)
+	})
+
+	t.Run("retry limit", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 2)
+
+		// 先送りした回数が上限(3)に達したアイテムは、次に処理する時刻によらず取得する
+		for i, retryCount := range []int{3, 2} {
+			item, err := storage.GetItemForScreening(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, item, "item %d", i)
+			item.Status = StatusDeferred
+			item.RetryCount = retryCount
+			require.NoError(t, storage.Update(ctx, item, ItemTransition{Stage: StageScreening, NextAttemptAt: time.Now().Add(time.Hour)}))
+		}
+		over, err := storage.GetItemOverRetryLimit(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, over)
+		assert.Equal(t, "Article 1", over.Title)
+		none, err := storage.GetItemOverRetryLimit(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, none, "the claimed item and the item under the limit are not returned")
+
+		over.Status = StatusProcessed
+		over.Reason = ReasonRetryLimitExceeded
+		require.NoError(t, storage.Update(ctx, over, ItemTransition{Stage: StageRetryLimit}))
+		counts, err := storage.CountItemsByReason(ctx, StatusProcessed)
+		require.NoError(t, err)
+		assert.Equal(t, map[ItemReasonCode]int{ReasonRetryLimitExceeded: 1}, counts)
+	})
+
+	t.Run("screening and summaries", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 1)
+
+		id, err := storage.AddScreeningResult(ctx, 1, &ScreeningResult{FinalResult: WorthSummarizingYes, Model: "gemini"})
+		require.NoError(t, err)
+		assert.NotZero(t, id)
+		records, err := storage.GetScreeningResults(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, WorthSummarizingYes, records[0].FinalResult)
+		assert.Equal(t, "gemini", records[0].Model)
+		byDecision, err := storage.ListScreeningResultsByDecision(ctx, WorthSummarizingNo, 10)
+		require.NoError(t, err)
+		assert.Empty(t, byDecision)
+
+		latest, err := storage.GetLatestSummary(ctx, 1)
+		require.NoError(t, err)
+		assert.Nil(t, latest)
+		_, err = storage.SaveSummary(ctx, 1, &SummarizeResult{FinalSummary: "first"})
+		require.NoError(t, err)
+		_, err = storage.SaveSummary(ctx, 1, &SummarizeResult{FinalSummary: "second", Variants: []SummaryVariant{{Name: "short", Summary: "short"}}})
+		require.NoError(t, err)
+		latest, err = storage.GetLatestSummary(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, latest)
+		assert.Equal(t, "second", latest.FinalSummary)
+		assert.Equal(t, []SummaryVariant{{Name: "short", Summary: "short"}}, latest.Variants)
+	})
+
+	t.Run("posts and deliveries", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 1)
+
+		require.NoError(t, storage.StartDelivery(ctx, 1, "mastodon", PostKindSummary))
+		require.NoError(t, storage.FinishDelivery(ctx, 1, "mastodon", PostKindSummary, nil))
+		deliveries, err := storage.GetDeliveries(ctx, 1, PostKindSummary)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, DeliverySent, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		require.NoError(t, storage.ReplaceDeliveries(ctx, 1, nil))
+		deliveries, err = storage.GetDeliveries(ctx, 1, PostKindSummary)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+
+		entry := &OutboxEntry{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 0}
+		require.NoError(t, storage.PrepareOutbox(ctx, entry))
+		assert.NotEmpty(t, entry.IdempotencyKey)
+		outbox, err := storage.GetOutbox(ctx, "mastodon")
+		require.NoError(t, err)
+		require.Len(t, outbox, 1)
+		require.NoError(t, storage.CompleteOutbox(ctx, entry.ID, &PostRecord{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 0, StatusID: "100", URL: "https://mastodon.example/@bot/100"}))
+		outbox, err = storage.GetOutbox(ctx, "mastodon")
+		require.NoError(t, err)
+		assert.Empty(t, outbox)
+
+		require.NoError(t, storage.AddPost(ctx, &PostRecord{ItemID: 1, Publisher: "mastodon", Kind: PostKindSummary, Seq: 1, StatusID: "101"}))
+		posts, err := storage.GetPosts(ctx, 1, "mastodon", PostKindSummary)
+		require.NoError(t, err)
+		require.Len(t, posts, 2)
+		assert.Equal(t, []string{"100", "101"}, []string{posts[0].StatusID, posts[1].StatusID})
+		byPublisher, err := storage.GetPostsByPublisher(ctx, 1, "mastodon")
+		require.NoError(t, err)
+		assert.Len(t, byPublisher, 2)
+		post, err := storage.GetPostByStatusID(ctx, "mastodon", "101")
+		require.NoError(t, err)
+		require.NotNil(t, post)
+		assert.Equal(t, 1, post.ItemID)
+		require.NoError(t, storage.DeletePost(ctx, post.ID))
+		post, err = storage.GetPostByStatusID(ctx, "mastodon", "101")
+		require.NoError(t, err)
+		assert.Nil(t, post)
+
+		scheduledAt := time.Now().UTC().Add(time.Hour)
+		require.NoError(t, storage.AddScheduledPost(ctx, &ScheduledPost{ItemID: 1, Publisher: "mastodon", ScheduledID: "1", ScheduledAt: scheduledAt}))
+		scheduled, err := storage.GetScheduledPosts(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, scheduled, 1)
+		assert.WithinDuration(t, scheduledAt, scheduled[0].ScheduledAt, time.Millisecond)
+		times, err := storage.GetPostTimes(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Len(t, times, 1, "the scheduled time is used instead of the delivery")
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		storage := newStorage(t)
+		addTestItems(t, storage, 2)
+
+		digest, err := storage.OpenDigest(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, digest)
+
+		require.NoError(t, storage.AddDigestItem(ctx, 1, PostKindNoValue))
+		require.NoError(t, storage.AddDigestItem(ctx, 2, PostKindSummary))
+		require.NoError(t, storage.AddDigestItem(ctx, 1, PostKindNoValue))
+		pending, err := storage.GetPendingDigest(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		assert.Len(t, pending.Items, 2)
+
+		digest, err = storage.OpenDigest(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, digest)
+		require.Len(t, digest.Items, 2)
+		assert.Equal(t, PostKindSummary, digest.Items[0].Kind)
+
+		// ダイジェストの配信状況と投稿は、同じIDのアイテムとは別に記録する
+		require.NoError(t, storage.StartDigestDelivery(ctx, digest.ID, "mastodon"))
+		require.NoError(t, storage.FinishDigestDelivery(ctx, digest.ID, "mastodon", nil))
+		deliveries, err := storage.GetDigestDeliveries(ctx, digest.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, DeliverySent, deliveries[0].Status)
+		require.NoError(t, storage.AddPost(ctx, &PostRecord{DigestID: digest.ID, Publisher: "mastodon", Kind: PostKindDigest, StatusID: "200"}))
+		posts, err := storage.GetDigestPosts(ctx, digest.ID, "mastodon")
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		itemDeliveries, err := storage.GetDeliveries(ctx, digest.ID, PostKindDigest)
+		require.NoError(t, err)
+		assert.Empty(t, itemDeliveries)
+		itemPosts, err := storage.GetPostsByPublisher(ctx, digest.ID, "mastodon")
+		require.NoError(t, err)
+		assert.Empty(t, itemPosts)
+
+		require.NoError(t, storage.CompleteDigest(ctx, digest.ID))
+		digest, err = storage.OpenDigest(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, digest)
+	})
+
+	t.Run("mentions and settings", func(t *testing.T) {
+		storage := newStorage(t)
+
+		latest, err := storage.GetLatestMentionNotificationID(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, latest)
+		for _, id := range []string{"99", "100", "98"} {
+			require.NoError(t, storage.AddMention(ctx, &MentionRecord{NotificationID: id, Account: "alice@example.com", Result: MentionAnswered}))
+		}
+		require.NoError(t, storage.AddMention(ctx, &MentionRecord{NotificationID: "99", Account: "alice@example.com", Result: MentionIgnored}))
+		latest, err = storage.GetLatestMentionNotificationID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "100", latest, "IDs are compared as numbers")
+		mention, err := storage.GetMention(ctx, "99")
+		require.NoError(t, err)
+		require.NotNil(t, mention)
+		assert.Equal(t, MentionAnswered, mention.Result, "recorded mentions are not overwritten")
+		count, err := storage.CountAnsweredMentions(ctx, "alice@example.com", time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		value, err := storage.GetSetting(ctx, SettingPaused)
+		require.NoError(t, err)
+		assert.Empty(t, value)
+		require.NoError(t, storage.SetSetting(ctx, SettingPaused, "true"))
+		require.NoError(t, storage.SetSetting(ctx, SettingPaused, "false"))
+		value, err = storage.GetSetting(ctx, SettingPaused)
+		require.NoError(t, err)
+		assert.Equal(t, "false", value)
+	})
+
+	t.Run("downloaded files", func(t *testing.T) {
+		storage := newStorage(t)
+		now := time.Now().UTC()
+
+		for i, sha := range []string{"a", "b"} {
+			require.NoError(t, storage.SaveDownloadedFile(ctx, &DownloadedFile{
+				SHA256:         sha,
+				URL:            "https://www.soumu.go.jp/" + sha + ".pdf",
+				Path:           sha + ".pdf",
+				Size:           100,
+				ContentType:    "application/pdf",
+				CreatedAt:      now,
+				LastAccessedAt: now.Add(time.Duration(-i) * time.Hour),
+			}))
+		}
+		file, err := storage.GetDownloadedFileByURL(ctx, "https://www.soumu.go.jp/a.pdf")
+		require.NoError(t, err)
+		require.NotNil(t, file)
+		assert.Equal(t, int64(100), file.Size)
+		files, err := storage.ListDownloadedFilesByLastAccess(ctx)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, "b", files[0].SHA256, "the least recently accessed first")
+		total, err := storage.TotalDownloadedFileSize(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(200), total)
+		// 同じ内容を別のURLからダウンロードした場合は、URLごとに記録して合計サイズには1回だけ数える
+		require.NoError(t, storage.SaveDownloadedFile(ctx, &DownloadedFile{
+			SHA256:         "a",
+			URL:            "https://www.soumu.go.jp/copy-of-a.pdf",
+			Path:           "a.pdf",
+			Size:           100,
+			ContentType:    "application/pdf",
+			CreatedAt:      now,
+			LastAccessedAt: now.Add(-2 * time.Hour),
+		}))
+		file, err = storage.GetDownloadedFileByURL(ctx, "https://www.soumu.go.jp/a.pdf")
+		require.NoError(t, err)
+		require.NotNil(t, file, "the metadata of the first URL is kept")
+		files, err = storage.ListDownloadedFilesByLastAccess(ctx)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, "https://www.soumu.go.jp/a.pdf", files[1].URL, "the most recent access of the content is used")
+		total, err = storage.TotalDownloadedFileSize(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(200), total)
+
+		remaining, err := storage.DeleteDownloadedFile(ctx, "https://www.soumu.go.jp/copy-of-a.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, 1, remaining)
+		require.NoError(t, storage.DeleteDownloadedContent(ctx, "b"))
+		total, err = storage.TotalDownloadedFileSize(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), total)
+	})
+}
+
+func TestRebindPostgres(t *testing.T) {
+	assert.Equal(t, "SELECT 1;", rebindPostgres("SELECT 1;"))
+	assert.Equal(t,
+		"UPDATE items SET title = $1, url = '?' WHERE id = $2 AND \"a?\" = $3;",
+		rebindPostgres("UPDATE items SET title = ?, url = '?' WHERE id = ? AND \"a?\" = ?;"))
+	assert.Equal(t,
+		"SELECT 'it''s?' -- why?\nFROM items /* how? */ WHERE id = $1;",
+		rebindPostgres("SELECT 'it''s?' -- why?\nFROM items /* how? */ WHERE id = ?;"))
+	assert.Equal(t, "SELECT $1; -- trailing?", rebindPostgres("SELECT ?; -- trailing?"))
+}
+
+func TestNewStorage(t *testing.T) {
+	_, err := NewStorage(&DatabaseConfig{Driver: "mysql"})
+	assert.Error(t, err)
+
+	storage, err := NewStorage(&DatabaseConfig{Path: filepath.Join(t.TempDir(), "database.sqlite")})
+	require.NoError(t, err, "SQLite is used by default")
+	require.NoError(t, storage.Close())
+}
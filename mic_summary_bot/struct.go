@@ -2,8 +2,9 @@ package micsummarybot
 
 // Document はHTMLドキュメント内に添付されているドキュメントの情報を保持します。
 type Document struct {
-	URL  string
-	Size int64 // バイト単位
+	URL   string
+	Label string // リンクテキスト
+	Size  int64  // バイト単位
 }
 
 // HTMLandDocuments はHTMLコンテンツとその中に添付されているドキュメントのリストを保持します。
@@ -3,23 +3,22 @@ package micsummarybot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
 	"path"
 	"strings"
 	"text/template"
-	"time"
 
-	"github.com/google/uuid"
 	"google.golang.org/genai"
 )
 
 type DocumentSummary struct {
-	Summary   string   `json:"summary"`
 	Metadata  string   `json:"metadata"`
 	KeyPoints []string `json:"keyPoints"`
+	Summary   string   `json:"summary"`
+	// URL と Label はGeminiの出力ではなく、要約対象として送信したドキュメントから設定される
+	URL   string `json:"url,omitempty" genai:"-"`
+	Label string `json:"label,omitempty" genai:"-"`
 }
 
 type SummarizeResult struct {
@@ -27,9 +26,20 @@ type SummarizeResult struct {
 	FirstSummary string            `json:"first_summary"`
 	Omissibles   []string          `json:"omissibles"`
 	MissedItems  []string          `json:"missed_items"`
-	FinalSummary string            `json:"final_summary"`
+	FinalSummary string            `json:"final_summary" genai:"required"`
+	// FinalSummarySentences は最終要約を文ごとに分け、それぞれの出典を付与したもの
+	FinalSummarySentences []SummarySentence `json:"final_summary_sentences"`
+	// Extra は設定の extra_output_fields で追加された出力項目
+	Extra map[string]string `json:"extra,omitempty" genai:"-"`
+	// Model は要約に使われたモデル名。Geminiの出力ではなくクライアントが設定する
+	Model string `json:"model,omitempty" genai:"-"`
+	// Variants は GenerateVariants で生成された別版の要約。summary_variants テーブルに保存される
+	Variants []SummaryVariant `json:"-"`
 }
 
+// summarizeResultSchema は SummarizeResult から生成した構造化出力のスキーマ
+var summarizeResultSchema = mustSchemaFor(SummarizeResult{})
+
 const (
 	// MaxDocumentSize represents the maximum file size (50MB) for documents
 	// that can be uploaded to Gemini API. This limitation is imposed by
@@ -82,27 +92,6 @@ func getPartMetadata(part *genai.Part) map[string]interface{} {
 	return metadata
 }
 
-// downloadFile は指定されたURLからファイルをダウンロードし、指定されたローカルパスに保存します。
-var downloadFile = func(url string, filepath string) error {
-	// Get the data
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Create the file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
 // SummarizeDocument はHTMLandDocumentsを要約します。
 func (client *GenAIClient) SummarizeDocument(htmlAndDocs *HTMLandDocuments, promptTemplate string) (SummarizeResult, error) {
 	ctx := context.Background()
@@ -113,52 +102,7 @@ func (client *GenAIClient) SummarizeDocument(htmlAndDocs *HTMLandDocuments, prom
 	modelConfig := &genai.GenerateContentConfig{
 		Temperature:      new(float32), // 0
 		ResponseMIMEType: "application/json",
-		ResponseSchema: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"documents": {
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"metadata": {
-								Type: genai.TypeString,
-							},
-							"keyPoints": {
-								Type: genai.TypeArray,
-								Items: &genai.Schema{
-									Type: genai.TypeString,
-								},
-							},
-							"summary": {
-								Type: genai.TypeString,
-							},
-						},
-						PropertyOrdering: []string{"metadata", "keyPoints", "summary"},
-					},
-				},
-				"first_summary": {
-					Type: genai.TypeString,
-				},
-				"omissibles": {
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeString,
-					},
-				},
-				"missed_items": {
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeString,
-					},
-				},
-				"final_summary": {
-					Type: genai.TypeString,
-				},
-			},
-			PropertyOrdering: []string{"documents", "omissibles", "final_summary"},
-			Required:         []string{"final_summary"},
-		},
+		ResponseSchema:   withExtraOutputFields(summarizeResultSchema, client.ExtraOutputFields),
 	}
 
 	pkgLogger.Debug("Parsing prompt template")
@@ -189,45 +133,45 @@ func (client *GenAIClient) SummarizeDocument(htmlAndDocs *HTMLandDocuments, prom
 	})
 	pkgLogger.Debug("Added HTML content to parts")
 
-	pkgLogger.Debug("Creating download directory", "path", client.DownloadDir)
-	err = os.MkdirAll(client.DownloadDir, 0755)
-	if err != nil {
-		pkgLogger.Error("Failed to create download directory", "path", client.DownloadDir, "error", err)
-		return SummarizeResult{}, fmt.Errorf("failed to create download directory: %w", err)
-	}
-
 	pkgLogger.Info("Processing documents for download", "count", len(htmlAndDocs.Documents))
+	var uploadedDocs []Document
 	for i, doc := range htmlAndDocs.Documents {
 		pkgLogger.Debug("Processing document", "index", i, "url", doc.URL, "size", doc.Size)
 		if doc.Size > MaxDocumentSize {
 			pkgLogger.Debug("Skipping document due to size limit", "url", doc.URL, "size", doc.Size, "max_size", MaxDocumentSize)
 			continue
 		}
-		id := uuid.New().String()
 		ext := path.Ext(doc.URL)
 		ext = strings.ToLower(ext)
 		if ext != ".pdf" {
 			pkgLogger.Debug("Skipping non-PDF document", "url", doc.URL, "extension", ext)
 			continue
 		}
-		localPath := path.Join(client.DownloadDir, fmt.Sprintf("%s.pdf", id))
-		pkgLogger.Info("Downloading PDF file", "url", doc.URL, "local_path", localPath)
-		err = downloadFile(doc.URL, localPath)
+		pkgLogger.Info("Downloading PDF file", "url", doc.URL)
+		file, err := client.Downloader.Download(ctx, doc.URL)
+		if errors.Is(err, ErrFileTooLarge) {
+			pkgLogger.Warn("Skipping document exceeding download size limit", "url", doc.URL, "error", err)
+			continue
+		}
+		if errors.Is(err, ErrUnexpectedContentType) {
+			pkgLogger.Warn("Skipping document with unexpected content type", "url", doc.URL, "error", err)
+			continue
+		}
 		if err != nil {
-			pkgLogger.Error("Failed to download file", "url", doc.URL, "local_path", localPath, "error", err)
+			pkgLogger.Error("Failed to download file", "url", doc.URL, "error", err)
 			return SummarizeResult{}, fmt.Errorf("failed to download file: %w", err)
 		}
-		pkgLogger.Debug("Uploading file to Gemini", "local_path", localPath)
-		f, err := client.Client.Files.UploadFromPath(ctx, localPath, &genai.UploadFileConfig{})
+		pkgLogger.Debug("Uploading file to Gemini", "local_path", file.Path, "sha256", file.SHA256)
+		f, err := client.Client.Files.UploadFromPath(ctx, file.Path, &genai.UploadFileConfig{})
 		if err != nil {
-			pkgLogger.Error("Failed to upload file to Gemini", "local_path", localPath, "error", err)
+			pkgLogger.Error("Failed to upload file to Gemini", "local_path", file.Path, "error", err)
 			return SummarizeResult{}, fmt.Errorf("failed to upload file: %w", err)
 		}
 		pkgLogger.Debug("File uploaded to Gemini successfully", "uri", f.URI, "mime_type", f.MIMEType)
 		parts = append(parts, genai.NewPartFromURI(f.URI, f.MIMEType))
-		if !client.KeepLocalCopy {
-			pkgLogger.Debug("Removing local copy", "local_path", localPath)
-			os.Remove(localPath)
+		uploadedDocs = append(uploadedDocs, doc)
+		if err := client.Downloader.Release(ctx, file); err != nil {
+			pkgLogger.Warn("Failed to release downloaded file", "local_path", file.Path, "error", err)
 		}
 	}
 
@@ -242,23 +186,13 @@ func (client *GenAIClient) SummarizeDocument(htmlAndDocs *HTMLandDocuments, prom
 	contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
 
 	// LLMへのリクエストとリトライ処理
-	pkgLogger.Info("Starting Gemini API calls with retry", "max_retry", client.MaxRetry+1)
-	var resp *genai.GenerateContentResponse
-	for i := 0; i < client.MaxRetry+1; i++ {
-		pkgLogger.Debug("Calling Gemini API", "attempt", i+1, "model", client.SummarizingModel)
-		resp, err = client.Client.Models.GenerateContent(ctx, client.SummarizingModel, contents, modelConfig)
-		if err == nil {
-			pkgLogger.Debug("Gemini API call succeeded", "attempt", i+1)
-			break // 成功したらループを抜ける
-		}
-		pkgLogger.Warn("Gemini API call failed", "attempt", i+1, "max_retry", client.MaxRetry+1, "error", err, "retrying_in_seconds", client.RetryIntervalSec)
-		time.Sleep(time.Duration(client.RetryIntervalSec) * time.Second)
-	}
-
+	pkgLogger.Info("Starting Gemini API calls with retry", "max_retry", client.MaxRetry+1, "models", len(client.SummarizingModel))
+	resp, model, err := client.generateContent(ctx, client.SummarizingModel, contents, modelConfig)
 	if err != nil {
-		pkgLogger.Error("All Gemini API calls failed", "total_attempts", client.MaxRetry+1, "error", err)
-		return SummarizeResult{}, fmt.Errorf("failed to get response from Gemini API after %d retries: %w", client.MaxRetry, err)
+		pkgLogger.Error("All Gemini API calls failed", "error", err)
+		return SummarizeResult{}, err
 	}
+	pkgLogger.Info("Summary generated", "model", model)
 
 	// レスポンスのパース
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
@@ -278,6 +212,17 @@ func (client *GenAIClient) SummarizeDocument(htmlAndDocs *HTMLandDocuments, prom
 		pkgLogger.Error("Failed to parse JSON response", "response", responseText, "error", err)
 		return SummarizeResult{}, fmt.Errorf("failed to parse JSON response from Gemini API: %w", err)
 	}
+	jsonResult.Model = model
+
+	// documentsは送信したファイルの順に出力されるため、数が一致する場合のみ対応付ける
+	if len(jsonResult.Documents) == len(uploadedDocs) {
+		for i, doc := range uploadedDocs {
+			jsonResult.Documents[i].URL = doc.URL
+			jsonResult.Documents[i].Label = doc.Label
+		}
+	} else {
+		pkgLogger.Warn("Number of document summaries does not match uploaded documents", "summaries", len(jsonResult.Documents), "uploaded", len(uploadedDocs))
+	}
 
 	pkgLogger.Debug("Document summarization completed successfully")
 	return jsonResult, nil
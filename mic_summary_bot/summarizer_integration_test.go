@@ -5,8 +5,8 @@ package micsummarybot
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
@@ -32,6 +32,20 @@ func TestSummarizeDocument(t *testing.T) {
 	require.NoError(t, err)
 
 	config := DefaultConfig()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	storage := config.Storage
+	storage.DownloadDir = t.TempDir() // Use a temporary directory for downloads
+	storage.KeepLocalCopy = true      // Keep files for inspection
+	downloader, err := NewDownloadManager(&storage, repo)
+	require.NoError(t, err)
+	// Serve documents from local files instead of downloading them.
+	// The path of the request URL is resolved relative to the resources directory.
+	downloader.httpClient = &http.Client{
+		Transport: http.NewFileTransport(http.Dir(filepath.Join("..", "resources"))),
+	}
+
 	// In a real scenario, NewGenAIClient would be used.
 	// For this test, we construct it directly to control dependencies.
 	client := &GenAIClient{
@@ -40,36 +54,8 @@ func TestSummarizeDocument(t *testing.T) {
 		RetryIntervalSec: config.Gemini.RetryIntervalSec,
 		ScreeningModel:   config.Gemini.ScreeningModel,
 		SummarizingModel: config.Gemini.SummarizingModel,
-		DownloadDir:      t.TempDir(), // Use a temporary directory for downloads
-		KeepLocalCopy:    true,        // Keep files for inspection
-	}
-
-	// --- Mock downloadFile function ---
-	// Store original downloadFile function
-	originalDownloadFile := downloadFile
-	// Defer restoration of the original function
-	t.Cleanup(func() {
-		downloadFile = originalDownloadFile
-	})
-	// Define a mocked downloadFile that copies from a local source
-	downloadFile = func(url, localPath string) error {
-		// The "url" in the test case will be the local source file path
-		sourceFile, err := os.Open(url)
-		if err != nil {
-			return fmt.Errorf("failed to open mock source file: %w", err)
-		}
-		defer sourceFile.Close()
-
-		destFile, err := os.Create(localPath)
-		if err != nil {
-			return fmt.Errorf("failed to create mock destination file: %w", err)
-		}
-		defer destFile.Close()
-
-		_, err = io.Copy(destFile, sourceFile)
-		return err
+		Downloader:       downloader,
 	}
-	// --- End Mock ---
 
 	promptTemplate := config.Gemini.SummarizingPrompt
 	require.NotEmpty(t, promptTemplate, "Summarization prompt should not be empty")
@@ -84,8 +70,8 @@ func TestSummarizeDocument(t *testing.T) {
 			name:             "Summarize page with one PDF",
 			htmlBodyFilePath: filepath.Join("..", "resources", "example_for_summerize", "01toukei08_01000325.htm"),
 			documents: []Document{
-				// For the mocked download, URL is the local file path.
-				{URL: filepath.Join("..", "resources", "example_for_summerize", "001034183.pdf"), Size: 100000}, // Size can be arbitrary but > 0
+				// The file transport serves this URL from ../resources/example_for_summerize/001034183.pdf
+				{URL: "http://localhost/example_for_summerize/001034183.pdf", Size: 100000}, // Size can be arbitrary but > 0
 			},
 			expectError: false,
 		},
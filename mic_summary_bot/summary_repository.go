@@ -0,0 +1,88 @@
+package micsummarybot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SaveSummary はアイテムの要約結果を保存します。別版の要約は summary_variants テーブルに保存されます。
+// 保存した要約のIDを返します。
+func (r *ItemRepository) SaveSummary(ctx context.Context, itemID int, summary *SummarizeResult) (int64, error) {
+	resultJSON, err := json.Marshal(summary)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal summary: %w", err)
+	}
+
+	insertSummarySQL := formatQuery(`
+	INSERT INTO summaries (item_id, final_summary, result_json, created_at)
+	VALUES (?, ?, ?, ?)
+	RETURNING id;
+	`)
+	insertVariantSQL := `INSERT INTO summary_variants (summary_id, name, summary) VALUES (?, ?, ?);`
+
+	var summaryID int64
+	err = withTransaction(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, insertSummarySQL, itemID, summary.FinalSummary, string(resultJSON), time.Now().UTC()).Scan(&summaryID)
+		if err != nil {
+			return err
+		}
+		for _, variant := range summary.Variants {
+			if _, err := tx.ExecContext(ctx, insertVariantSQL, summaryID, variant.Name, variant.Summary); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to save summary for item ID %d: %w", itemID, err)
+	}
+	return summaryID, nil
+}
+
+// GetLatestSummary はアイテムの最新の要約結果を別版の要約とともに取得します。
+// 要約が保存されていない場合はnilを返します。
+func (r *ItemRepository) GetLatestSummary(ctx context.Context, itemID int) (*SummarizeResult, error) {
+	query := formatQuery(`
+	SELECT id, result_json
+	FROM summaries
+	WHERE item_id = ?
+	ORDER BY created_at DESC, id DESC
+	LIMIT 1;
+	`)
+
+	var summaryID int64
+	var resultJSON string
+	err := r.db.QueryRowContext(ctx, query, itemID).Scan(&summaryID, &resultJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary for item ID %d: %w", itemID, err)
+	}
+
+	var summary SummarizeResult
+	if err := json.Unmarshal([]byte(resultJSON), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse stored summary for item ID %d: %w", itemID, err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT name, summary FROM summary_variants WHERE summary_id = ? ORDER BY name;`, summaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary variants for item ID %d: %w", itemID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var variant SummaryVariant
+		if err := rows.Scan(&variant.Name, &variant.Summary); err != nil {
+			return nil, fmt.Errorf("failed to scan summary variant: %w", err)
+		}
+		summary.Variants = append(summary.Variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
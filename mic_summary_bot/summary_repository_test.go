@@ -0,0 +1,49 @@
+package micsummarybot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemRepository_SaveSummary(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("no summary", func(t *testing.T) {
+		summary, err := repo.GetLatestSummary(ctx, 1)
+		require.NoError(t, err)
+		assert.Nil(t, summary)
+	})
+
+	t.Run("latest summary with variants", func(t *testing.T) {
+		_, err := repo.SaveSummary(ctx, 1, &SummarizeResult{FinalSummary: "古い要約"})
+		require.NoError(t, err)
+
+		saved := &SummarizeResult{
+			Documents:    []DocumentSummary{{Summary: "資料1の要約", KeyPoints: []string{"決定事項"}}},
+			FinalSummary: "新しい要約",
+			FinalSummarySentences: []SummarySentence{
+				{Text: "新しい要約", Sources: []SummarySource{{DocumentIndex: 1, Page: 2}}},
+			},
+			Variants: []SummaryVariant{
+				{Name: "easy_ja", Summary: "やさしい要約です。"},
+				{Name: "en", Summary: "English summary."},
+			},
+		}
+		id, err := repo.SaveSummary(ctx, 1, saved)
+		require.NoError(t, err)
+		assert.True(t, id > 0)
+
+		summary, err := repo.GetLatestSummary(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, summary)
+		assert.Equal(t, saved.FinalSummary, summary.FinalSummary)
+		assert.Equal(t, saved.Documents, summary.Documents)
+		assert.Equal(t, saved.FinalSummarySentences, summary.FinalSummarySentences)
+		assert.Equal(t, saved.Variants, summary.Variants)
+	})
+}
@@ -0,0 +1,69 @@
+package micsummarybot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// SummaryVariant は英語版ややさしい日本語版など、最終要約の別版を保持します。
+type SummaryVariant struct {
+	Name    string `json:"name" genai:"-"`
+	Summary string `json:"summary" genai:"required"`
+}
+
+// variantPromptPrefix は別版の生成時に、設定されたプロンプトの前に付与される指示です。
+const variantPromptPrefix = `以下は総務省の会議資料を要約した結果(JSON)です。documentsは各資料の要約、final_summaryは会議全体の最終要約です。
+この内容のみに基づき、後に続く指示に従って最終要約の別版を作成し、summaryに出力してください。
+
+`
+
+// GenerateVariants は要約結果をもとに、設定された別版の要約を生成します。
+// 添付資料は再送せず、SummarizeDocumentの結果のみを入力とします。
+// 一部の別版の生成に失敗した場合も、生成できた別版を返し、失敗をまとめたエラーを返します。
+func (client *GenAIClient) GenerateVariants(summary SummarizeResult, variants []VariantConfig) ([]SummaryVariant, error) {
+	ctx := context.Background()
+
+	modelConfig := &genai.GenerateContentConfig{
+		Temperature:      new(float32), // 0
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   mustSchemaFor(SummaryVariant{}),
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal summary: %w", err)
+	}
+
+	var results []SummaryVariant
+	var errs []error
+	for _, variant := range variants {
+		pkgLogger.Debug("Generating summary variant", "name", variant.Name)
+		parts := []*genai.Part{
+			genai.NewPartFromText(variantPromptPrefix + string(summaryJSON)),
+			genai.NewPartFromText(variant.Prompt),
+		}
+		contents := []*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)}
+
+		resp, _, err := client.generateContent(ctx, client.SummarizingModel, contents, modelConfig)
+		if err != nil {
+			pkgLogger.Error("Failed to generate summary variant", "name", variant.Name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to generate variant %s: %w", variant.Name, err))
+			continue
+		}
+
+		var result SummaryVariant
+		if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+			pkgLogger.Error("Failed to parse summary variant", "name", variant.Name, "response", resp.Text(), "error", err)
+			errs = append(errs, fmt.Errorf("failed to parse JSON response for variant %s: %w", variant.Name, err))
+			continue
+		}
+		result.Name = variant.Name
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
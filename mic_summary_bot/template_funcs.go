@@ -0,0 +1,149 @@
+package micsummarybot
+
+import (
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// jstLocation is the time zone used by the jst template function.
+// A fixed zone is used so that the templates do not depend on the time zone database.
+var jstLocation = time.FixedZone("JST", 9*60*60)
+
+// postTemplateFuncs are the functions available in the post templates.
+// The arguments are ordered so that the value can be piped, as in {{ .Title | truncate 30 }}.
+var postTemplateFuncs = template.FuncMap{
+	// jst formats the time in JST with the layout, e.g. {{ .PublishedAt | jst "2006年1月2日" }}.
+	"jst": func(layout string, t time.Time) string {
+		return t.In(jstLocation).Format(layout)
+	},
+	// truncate shortens the text to n characters including a trailing ellipsis.
+	"truncate": func(n int, s string) string {
+		return truncateRunes(s, n)
+	},
+	// join concatenates the elements with the separator, e.g. {{ .KeyPoints | join "、" }}.
+	"join": func(sep string, elems []string) string {
+		return strings.Join(elems, sep)
+	},
+	// hashtag converts the text into a hashtag such as "#電波政策". It returns an empty string if the text can't be a hashtag.
+	"hashtag": func(s string) string {
+		if tag := normalizeHashtag(s); tag != "" {
+			return "#" + tag
+		}
+		return ""
+	},
+	// halfwidth converts full-width alphanumerics and symbols into half-width, and half-width katakana into full-width.
+	"halfwidth": func(s string) string {
+		// Fold leaves the voiced sound marks of half-width katakana as combining characters, so they are composed.
+		return norm.NFC.String(width.Fold.String(s))
+	},
+	// fullwidth converts half-width characters into full-width.
+	"fullwidth": func(s string) string {
+		return width.Widen.String(s)
+	},
+}
+
+// parsePostTemplate parses a post template with the template functions.
+func parsePostTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(postTemplateFuncs).Parse(text)
+}
+
+// validatePostTemplate executes the template with the sample data to find errors such as unknown fields
+// when the config is loaded rather than when posting.
+func validatePostTemplate(t *template.Template, data any) error {
+	_, err := executeTemplate(t, data)
+	return err
+}
+
+// templateUsesField reports whether the template refers to the field of its data, such as {{ .CitedSummary }}.
+// The parsed tree is inspected so that the field name in text or comments is not counted.
+func templateUsesField(t *template.Template, field string) bool {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil && nodeUsesField(tmpl.Tree.Root, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeUsesField(node parse.Node, field string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		return slices.ContainsFunc(n.Nodes, func(child parse.Node) bool { return nodeUsesField(child, field) })
+	case *parse.ActionNode:
+		return nodeUsesField(n.Pipe, field)
+	case *parse.IfNode:
+		return branchUsesField(&n.BranchNode, field)
+	case *parse.RangeNode:
+		return branchUsesField(&n.BranchNode, field)
+	case *parse.WithNode:
+		return branchUsesField(&n.BranchNode, field)
+	case *parse.TemplateNode:
+		return nodeUsesField(n.Pipe, field)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		return slices.ContainsFunc(n.Cmds, func(cmd *parse.CommandNode) bool { return nodeUsesField(cmd, field) })
+	case *parse.CommandNode:
+		return slices.ContainsFunc(n.Args, func(arg parse.Node) bool { return nodeUsesField(arg, field) })
+	case *parse.FieldNode:
+		return slices.Contains(n.Ident, field)
+	case *parse.ChainNode:
+		return slices.Contains(n.Field, field) || nodeUsesField(n.Node, field)
+	case *parse.VariableNode:
+		return slices.Contains(n.Ident[1:], field)
+	}
+	return false
+}
+
+func branchUsesField(n *parse.BranchNode, field string) bool {
+	return nodeUsesField(n.Pipe, field) || nodeUsesField(n.List, field) || nodeUsesField(n.ElseList, field)
+}
+
+// samplePostInfo returns the template data used to validate the post templates.
+func samplePostInfo() PostInfo {
+	documents := []PostDocument{{
+		Label:     "資料1",
+		URL:       "https://www.soumu.go.jp/main_content/000000001.pdf",
+		Summary:   "資料の要約。",
+		KeyPoints: []string{"要点1", "要点2"},
+		Metadata:  "日時: 令和7年4月1日",
+	}}
+	return PostInfo{
+		Title:           "サンプル会議（第1回）",
+		Summary:         "要約。",
+		URL:             "https://www.soumu.go.jp/menu_news/s-news/sample.html",
+		CitedSummary:    "要約。[1]",
+		Sources:         []PostSource{{Number: 1, Label: documents[0].Label, URL: documents[0].URL}},
+		Extra:           map[string]string{},
+		PublishedAt:     time.Date(2025, 4, 1, 10, 0, 0, 0, jstLocation),
+		FeedName:        "総務省",
+		Documents:       documents,
+		KeyPoints:       documents[0].KeyPoints,
+		Metadata:        documents[0].Metadata,
+		ScreeningReason: "添付資料がない。",
+	}
+}
+
+// sampleThreadPostInfo returns the template data used to validate the thread post template.
+func sampleThreadPostInfo() ThreadPostInfo {
+	doc := samplePostInfo().Documents[0]
+	return ThreadPostInfo{
+		Title:     "サンプル会議（第1回）",
+		Label:     doc.Label,
+		URL:       doc.URL,
+		Summary:   doc.Summary,
+		KeyPoints: doc.KeyPoints,
+		Index:     1,
+		Total:     1,
+	}
+}
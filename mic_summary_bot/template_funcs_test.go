@@ -0,0 +1,114 @@
+package micsummarybot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		want     string
+	}{
+		{"jst", `{{ .PublishedAt | jst "2006年1月2日 15:04" }}`, PostInfo{PublishedAt: time.Date(2025, 3, 31, 16, 30, 0, 0, time.UTC)}, "2025年4月1日 01:30"},
+		{"truncate", `{{ .Title | truncate 5 }}`, PostInfo{Title: "情報通信審議会総会"}, "情報通信…"},
+		{"truncate short text", `{{ .Title | truncate 5 }}`, PostInfo{Title: "総会"}, "総会"},
+		{"join", `{{ .KeyPoints | join "、" }}`, PostInfo{KeyPoints: []string{"a", "b"}}, "a、b"},
+		{"hashtag", `{{ hashtag .FeedName }}`, PostInfo{FeedName: "総務省 新着情報"}, "#総務省新着情報"},
+		{"hashtag without letters", `{{ hashtag .FeedName }}`, PostInfo{FeedName: "2025"}, ""},
+		{"halfwidth", `{{ halfwidth .Title }}`, PostInfo{Title: "ＡＩ戦略（第１回）ｶﾞｲﾄﾞ"}, "AI戦略(第1回)ガイド"},
+		{"fullwidth", `{{ fullwidth .Title }}`, PostInfo{Title: "AI 5G"}, "ＡＩ　５Ｇ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parsePostTemplate(tt.name, tt.template)
+			require.NoError(t, err)
+			got, err := executeTemplate(tmpl, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTemplateUsesField(t *testing.T) {
+	tests := []struct {
+		template string
+		want     bool
+	}{
+		{`{{ .Title }}\n{{ .CitedSummary }}`, true},
+		{`{{ if .Summary }}{{ .CitedSummary | truncate 100 }}{{ end }}`, true},
+		{`{{ with $ := . }}{{ $.CitedSummary }}{{ end }}`, true},
+		{`{{ define "body" }}{{ .CitedSummary }}{{ end }}{{ template "body" . }}`, true},
+		{`{{ .Summary }}`, false},
+		{`{{/* .CitedSummary */}}{{ .Summary }} .CitedSummary`, false},
+	}
+	for _, tt := range tests {
+		tmpl, err := parsePostTemplate("post", tt.template)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, templateUsesField(tmpl, "CitedSummary"), tt.template)
+	}
+}
+
+func TestNewPostInfo(t *testing.T) {
+	item := Item{Title: "会議", URL: "https://www.soumu.go.jp/a.html", PublishedAt: time.Date(2025, 4, 1, 1, 0, 0, 0, time.UTC)}
+	summary := SummarizeResult{
+		FinalSummary: "要約。",
+		Documents: []DocumentSummary{
+			{Label: "議事次第", URL: "https://www.soumu.go.jp/1.pdf", Metadata: "日時: 4月1日", KeyPoints: []string{"a"}},
+			{Label: "資料", KeyPoints: []string{"b", "c"}, Metadata: "資料のメタ情報"},
+		},
+	}
+
+	info := newPostInfo("総務省", item, summary)
+	assert.Equal(t, item.PublishedAt, info.PublishedAt)
+	assert.Equal(t, "総務省", info.FeedName)
+	assert.Equal(t, []string{"a", "b", "c"}, info.KeyPoints)
+	assert.Equal(t, "日時: 4月1日", info.Metadata)
+	require.Len(t, info.Documents, 2)
+	assert.Equal(t, "議事次第", info.Documents[0].Label)
+	assert.Equal(t, item.URL, info.Documents[1].URL, "documents without a URL link to the page")
+
+	screening := &ScreeningResult{
+		FinalResult: WorthSummarizingNo,
+		Criteria: []ScreeningCriterion{
+			{Name: "添付資料", Thoughts: "添付資料がない。", Result: WorthSummarizingNo},
+			{Name: "形式", Thoughts: "適切にフォーマットされている。", Result: WorthSummarizingYes},
+		},
+	}
+	assert.Equal(t, "添付資料がない。", newNoValuePostInfo("", item, screening).ScreeningReason)
+	assert.Empty(t, newNoValuePostInfo("", item, nil).ScreeningReason)
+}
+
+func TestNewMastodonClient_validatesTemplates(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"unknown field", func(c *Config) { c.Mastodon.PostTemplate = "{{ .Titel }}" }},
+		{"wrong function argument", func(c *Config) { c.Mastodon.NoValuePostTemplate = `{{ .Title | jst "2006" }}` }},
+		{"thread template", func(c *Config) { c.Mastodon.ThreadPostTemplate = "{{ .Summary.Text }}" }},
+		{"digest template", func(c *Config) { c.Digest.PostTemplate = "{{ range .Items }}{{ end }}" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Mastodon.MaxCharacters = 500
+			tt.modify(config)
+			_, err := NewMastodonClient(config, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	config := DefaultConfig()
+	config.Mastodon.MaxCharacters = 500
+	config.Mastodon.PostTemplate = `{{ .FeedName }} {{ .PublishedAt | jst "1/2" }} {{ .Title | halfwidth | truncate 40 }}
+{{ .Summary }}{{ range .Documents }}
+{{ .Label }} {{ .URL }}{{ end }}`
+	_, err := NewMastodonClient(config, nil)
+	assert.NoError(t, err)
+}
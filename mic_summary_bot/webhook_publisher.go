@@ -0,0 +1,238 @@
+package micsummarybot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// discordMaxCharacters is the maximum length of a message sent through a Discord webhook.
+const discordMaxCharacters = 2000
+
+// ChatWebhookPublisher posts the rendered text to a Slack or Discord incoming webhook.
+type ChatWebhookPublisher struct {
+	name          string
+	publisherType PublisherType
+	url           string
+	maxCharacters int
+	templates     *postTemplates
+	httpClient    *http.Client
+}
+
+func newChatWebhookPublisher(config PublisherConfig, templates *postTemplates, httpClient *http.Client) *ChatWebhookPublisher {
+	maxCharacters := config.MaxCharacters
+	if maxCharacters == 0 && config.Type == PublisherDiscord {
+		maxCharacters = discordMaxCharacters
+	}
+	return &ChatWebhookPublisher{
+		name:          config.Name,
+		publisherType: config.Type,
+		url:           config.URL,
+		maxCharacters: maxCharacters,
+		templates:     templates,
+		httpClient:    httpClient,
+	}
+}
+
+func (p *ChatWebhookPublisher) Name() string {
+	return p.name
+}
+
+// PostSummary sends the summary to the webhook.
+func (p *ChatWebhookPublisher) PostSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	text, err := p.templates.renderSummary(item, summary, p.maxCharacters, utf8.RuneCountInString)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, text)
+}
+
+// PostNoValue sends a predefined message for items deemed not valuable.
+func (p *ChatWebhookPublisher) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	text, err := p.templates.renderNoValue(item, screening)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, text)
+}
+
+// PostFallback sends the text for an item given up without a summary.
+func (p *ChatWebhookPublisher) PostFallback(ctx context.Context, item Item, text string) error {
+	return p.send(ctx, text)
+}
+
+func (p *ChatWebhookPublisher) renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error) {
+	text, err := p.templates.renderSummary(item, summary, p.maxCharacters, utf8.RuneCountInString)
+	return []string{text}, err
+}
+
+func (p *ChatWebhookPublisher) renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error) {
+	text, err := p.templates.renderNoValue(item, screening)
+	return []string{text}, err
+}
+
+func (p *ChatWebhookPublisher) send(ctx context.Context, text string) error {
+	// Slack reads "text" and Discord reads "content".
+	payload := map[string]string{"text": text}
+	if p.publisherType == PublisherDiscord {
+		payload = map[string]string{"content": text}
+	}
+	if err := postJSON(ctx, p.httpClient, p.url, nil, payload, nil); err != nil {
+		return fmt.Errorf("failed to send to %s webhook: %w", p.publisherType, err)
+	}
+	pkgLogger.Info("Successfully sent to webhook", "publisher", p.name, "type", p.publisherType)
+	return nil
+}
+
+// webhookSignatureHeader is the header holding the HMAC-SHA256 signature of the request body.
+const webhookSignatureHeader = "X-Signature-256"
+
+// WebhookEvent is the kind of the payload sent by the generic webhook.
+type WebhookEvent string
+
+const (
+	WebhookEventSummary  WebhookEvent = "summary"
+	WebhookEventNoValue  WebhookEvent = "no_value"
+	WebhookEventFallback WebhookEvent = "fallback"
+)
+
+// WebhookPayload is the JSON body sent by the generic webhook.
+type WebhookPayload struct {
+	Event  WebhookEvent `json:"event"`
+	SentAt time.Time    `json:"sent_at"`
+	Item   WebhookItem  `json:"item"`
+	// Text is the status text rendered with the post template.
+	Text    string           `json:"text"`
+	Summary *SummarizeResult `json:"summary,omitempty"`
+}
+
+// WebhookItem is the feed item in the webhook payload.
+type WebhookItem struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// WebhookPublisher sends the summary as JSON to an arbitrary endpoint.
+// If a secret is configured, the body is signed with HMAC-SHA256 and the signature is sent
+// in the X-Signature-256 header as "sha256=<hex>".
+type WebhookPublisher struct {
+	name       string
+	url        string
+	secret     string
+	templates  *postTemplates
+	httpClient *http.Client
+}
+
+func newWebhookPublisher(config PublisherConfig, templates *postTemplates, httpClient *http.Client) *WebhookPublisher {
+	return &WebhookPublisher{
+		name:       config.Name,
+		url:        config.URL,
+		secret:     config.Secret,
+		templates:  templates,
+		httpClient: httpClient,
+	}
+}
+
+func (p *WebhookPublisher) Name() string {
+	return p.name
+}
+
+// PostSummary sends the summary with the full summarize result.
+func (p *WebhookPublisher) PostSummary(ctx context.Context, item Item, summary SummarizeResult) error {
+	text, err := p.templates.renderSummary(item, summary, 0, utf8.RuneCountInString)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, WebhookEventSummary, item, text, &summary)
+}
+
+// PostNoValue sends the no value event.
+func (p *WebhookPublisher) PostNoValue(ctx context.Context, item Item, screening *ScreeningResult) error {
+	text, err := p.templates.renderNoValue(item, screening)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, WebhookEventNoValue, item, text, nil)
+}
+
+// PostFallback sends the fallback event for an item given up without a summary.
+func (p *WebhookPublisher) PostFallback(ctx context.Context, item Item, text string) error {
+	return p.send(ctx, WebhookEventFallback, item, text, nil)
+}
+
+func (p *WebhookPublisher) renderSummaryPosts(item Item, summary SummarizeResult) ([]string, error) {
+	text, err := p.templates.renderSummary(item, summary, 0, utf8.RuneCountInString)
+	if err != nil {
+		return nil, err
+	}
+	body, err := webhookPayloadJSON(WebhookEventSummary, item, text, &summary, "  ")
+	return []string{string(body)}, err
+}
+
+func (p *WebhookPublisher) renderNoValuePosts(item Item, screening *ScreeningResult) ([]string, error) {
+	text, err := p.templates.renderNoValue(item, screening)
+	if err != nil {
+		return nil, err
+	}
+	body, err := webhookPayloadJSON(WebhookEventNoValue, item, text, nil, "  ")
+	return []string{string(body)}, err
+}
+
+func (p *WebhookPublisher) send(ctx context.Context, event WebhookEvent, item Item, text string, summary *SummarizeResult) error {
+	body, err := webhookPayloadJSON(event, item, text, summary, "")
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if p.secret != "" {
+		header.Set(webhookSignatureHeader, signWebhookBody(p.secret, body))
+	}
+	if err := postBody(ctx, p.httpClient, p.url, header, body, nil); err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	pkgLogger.Info("Successfully sent to webhook", "publisher", p.name, "event", event)
+	return nil
+}
+
+// webhookPayloadJSON returns the payload sent to the webhook, indented if indent is not empty.
+func webhookPayloadJSON(event WebhookEvent, item Item, text string, summary *SummarizeResult, indent string) ([]byte, error) {
+	payload := WebhookPayload{
+		Event:  event,
+		SentAt: time.Now().UTC(),
+		Item: WebhookItem{
+			ID:          item.ID,
+			URL:         item.URL,
+			Title:       item.Title,
+			PublishedAt: item.PublishedAt,
+		},
+		Text:    text,
+		Summary: summary,
+	}
+	var body []byte
+	var err error
+	if indent == "" {
+		body, err = json.Marshal(payload)
+	} else {
+		body, err = json.MarshalIndent(payload, "", indent)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return body, nil
+}
+
+// signWebhookBody returns the signature of the body in the form "sha256=<hex>".
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}